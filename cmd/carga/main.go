@@ -0,0 +1,457 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pdrpinto/tcc-cassandra/internal/auditoria"
+	"github.com/pdrpinto/tcc-cassandra/internal/conteudo"
+	"github.com/pdrpinto/tcc-cassandra/internal/estatisticas"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/adaptadores"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/aplicacao"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Subcomando: alvo (ingestor HTTP ou Cassandra direto) e a mistura de operações.
+type subcomando struct {
+	alvo      string // http | cql; vazio = escolhido por -alvo (mixed)
+	operacoes string // "escrita", "leitura" (tipo em -leitura) ou "mistura" (-mistura)
+	descricao string
+}
+
+var subcomandos = map[string]subcomando{
+	"http-write": {"http", "escrita", "escritas pelo ingestor (POST /ingest)"},
+	"http-read":  {"http", "leitura", "leituras pelo ingestor (/leituras/ultimas ou /leituras/intervalo)"},
+	"cql-write":  {"cql", "escrita", "escritas direto no Cassandra"},
+	"cql-read":   {"cql", "leitura", "leituras direto no Cassandra"},
+	"mixed":      {"", "mistura", "mistura ponderada de escritas e leituras (-alvo http|cql)"},
+}
+
+// Resultado comum a todos os subcomandos.
+type RelatorioDaCarga struct {
+	Subcomando  string                                `json:"subcomando"`
+	Alvo        string                                `json:"alvo"`
+	Escrita     string                                `json:"consistencia_escrita"`
+	Leitura     string                                `json:"consistencia_leitura"`
+	Lote        int                                   `json:"lote,omitempty"` // leituras por escrita em /ingest/lote
+	Mistura     string                                `json:"mistura"`
+	Semente     int64                                 `json:"semente"`
+	Duracao     time.Duration                         `json:"duracao_ns"`
+	Total       int64                                 `json:"total"`
+	Ok          int64                                 `json:"ok"`
+	PorOperacao map[string]estatisticas.ResumoDaCarga `json:"por_operacao"`
+	Conteudo    conteudo.ResumoDoConteudo             `json:"conteudo"` // escritas geradas
+	// Consultas com sucesso por operação e faixa de tamanho do resultado.
+	PorFaixa map[string]map[string]estatisticas.ResumoDaCarga `json:"por_faixa_de_resultado,omitempty"`
+}
+
+// Rodada da execução: um nível de -w e um tamanho de -lote.
+type rodada struct {
+	consistencia string
+	lote         int // 0 = uma leitura por escrita
+}
+
+func (r rodada) rotulo() string {
+	if r.lote == 0 {
+		return "w=" + r.consistencia
+	}
+	return fmt.Sprintf("w=%s lote=%d", r.consistencia, r.lote)
+}
+
+// Ferramenta de carga unificada: um motor (aplicacao.ServicoDeStress), um conjunto de flags
+// e um formato de resultado para os alvos HTTP (ingestor) e CQL (Cassandra).
+func main() {
+	if len(os.Args) < 2 || subcomandos[os.Args[1]] == (subcomando{}) {
+		uso()
+		os.Exit(2)
+	}
+	nome := os.Args[1]
+	sub := subcomandos[nome]
+
+	flags := flag.NewFlagSet(nome, flag.ExitOnError)
+	var (
+		alvo       = flags.String("alvo", "cql", "mixed: alvo das operações (http|cql)")
+		urlBase    = flags.String("url", "http://localhost:8080", "URL base do ingestor (alvo http)")
+		hosts      = flags.String("hosts", valorOu("CASSANDRA_HOSTS", "127.0.0.1:9042"), "Hosts do Cassandra separados por vírgula (alvo cql)")
+		keyspace   = flags.String("keyspace", valorOu("CASSANDRA_KEYSPACE", "tcc"), "Keyspace (alvo cql)")
+		consistW   = flags.String("w", "QUORUM", "Consistência de escrita; lista separada por vírgula executa uma rodada por nível")
+		consistR   = flags.String("r", "QUORUM", "Consistência de leitura")
+		lotes      = flags.String("lote", "0", "Leituras por escrita em /ingest/lote (0 = /ingest; alvo http); lista executa uma rodada por tamanho")
+		concServ   = flags.Int("concorrencia-servidor", 8, "Lote: gravações paralelas no servidor (?concorrencia=, 1 a 128)")
+		leitura    = flags.String("leitura", "ultimas", "Leituras de http-read/cql-read: ultimas|intervalo")
+		mistura    = flags.String("mistura", "escrita=70,ultimas=20,intervalo=10", "mixed: mistura ponderada de operações")
+		duracao    = flags.Duration("duracao", 10*time.Second, "Duração do teste (por rodada)")
+		rps        = flags.Int("rps", 200, "Taxa de requisições por segundo")
+		conc       = flags.Int("conc", runtime.NumCPU(), "Concorrência")
+		sensores   = flags.Int("sensores", 100, "Quantidade de sensores distintos")
+		distSensor = flags.String("dist-sensores", "uniforme", "Distribuição dos sensores: uniforme|zipf|hotspot")
+		limite     = flags.Int("limite", 10, "N das leituras ultimas N")
+		janelas    = flags.String("janelas", "5m", "Larguras das janelas das leituras por intervalo, sorteadas por consulta (ex.: 1m,10m,1h)")
+		limiteInt  = flags.Int("limite-intervalo", 1000, "Limite das leituras por intervalo (servidor: máximo 10000)")
+		semente    = flags.Int64("semente", 0, "Semente aleatória (0 = relógio); a mesma em todas as rodadas")
+		timeout    = flags.Duration("timeout", 5*time.Second, "Timeout por operação")
+		progresso  = flags.Duration("progresso", 5*time.Second, "Intervalo dos logs de progresso (0 = desliga)")
+		etiquetas  = flags.String("etiquetas", "3", "Etiquetas por escrita: n, minimo-maximo ou normal:media,desvio (ex.: 5-30)")
+		tamValor   = flags.String("tamanho-valor", "8", "Caracteres do valor de cada etiqueta (mesma sintaxe de -etiquetas)")
+		unidades   = flags.String("unidades", "C", "Mistura ponderada de unidades (ex.: C=60,%=30,hPa=10)")
+		estados    = flags.String("estados", "0", "Mistura ponderada de status (ex.: 0=97,1=2,2=1)")
+		manifesto  = flags.String("manifesto", "", "Arquivo JSON com as partições escritas (sensor, dia, linhas): as escritas o acumulam e as leituras sorteiam dele o sensor, o dia e a janela")
+		registro   = flags.String("auditoria", "", "Arquivo JSONL com as escritas (sensor_id, ts) para a auditoria de escritas perdidas")
+		saida      = flags.String("out", "", "Arquivo de saída: .json (tudo) ou CSV (um por operação + série por segundo); com várias rodadas, a comparação entre elas")
+	)
+	flags.Parse(os.Args[2:])
+
+	if sub.alvo == "" {
+		sub.alvo = strings.ToLower(*alvo)
+	}
+	textoDaMistura := *mistura
+	switch sub.operacoes {
+	case "escrita":
+		textoDaMistura = "escrita=100"
+	case "leitura":
+		textoDaMistura = strings.ToLower(*leitura) + "=100"
+	}
+	pesos, err := aplicacao.ParsearMisturaDeOperacoes(textoDaMistura)
+	if err != nil {
+		falhar(err)
+	}
+	r := strings.ToUpper(*consistR)
+	var larguras []time.Duration
+	for _, texto := range dividirLista(*janelas) {
+		largura, err := time.ParseDuration(texto)
+		if err != nil || largura <= 0 {
+			falhar(fmt.Errorf("janela invalida: %q", texto))
+		}
+		larguras = append(larguras, largura)
+	}
+	// Leituras não variam entre rodadas: só a lista de -w e, nas escritas, a de -lote
+	var rodadas []rodada
+	for _, w := range dividirLista(*consistW) {
+		tamanhos := []string{"0"}
+		if aplicacao.MisturaContemEscritas(pesos) {
+			tamanhos = dividirLista(*lotes)
+		}
+		for _, texto := range tamanhos {
+			lote, err := strconv.Atoi(texto)
+			if err != nil || lote < 0 {
+				falhar(fmt.Errorf("tamanho de lote invalido: %q", texto))
+			}
+			if lote > 0 && sub.alvo != "http" {
+				falhar(fmt.Errorf("-lote exige alvo http (/ingest/lote)"))
+			}
+			rodadas = append(rodadas, rodada{consistencia: strings.ToUpper(w), lote: lote})
+		}
+	}
+	if len(rodadas) == 0 {
+		falhar(fmt.Errorf("informe ao menos um nivel em -w e um tamanho em -lote"))
+	}
+
+	var servico aplicacao.ServicoDeStress
+	switch sub.alvo {
+	case "http":
+		escrita := adaptadores.NovoRepositorioDeEscritaHTTP(*urlBase, rodadas[0].consistencia, *timeout)
+		escrita.ConcorrenciaDoLote = *concServ
+		servico.Persistencia = escrita
+		servico.Consultas = adaptadores.NovoRepositorioDeLeituraHTTP(*urlBase, r, r, *timeout)
+	case "cql":
+		consistEscrita, err := gocql.ParseConsistencyWrapper(rodadas[0].consistencia)
+		if err != nil {
+			falhar(err)
+		}
+		consistLeitura, err := gocql.ParseConsistencyWrapper(r)
+		if err != nil {
+			falhar(err)
+		}
+		cluster := gocql.NewCluster(dividirLista(*hosts)...)
+		cluster.Keyspace = *keyspace
+		cluster.ProtoVersion = 4
+		cluster.Timeout = *timeout
+		cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+		cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: 1}
+		sessao, err := cluster.CreateSession()
+		if err != nil {
+			falhar(err)
+		}
+		defer sessao.Close()
+		servico.Persistencia = adaptadores.NovoRepositorioDeEscritaCassandra(sessao, consistEscrita, *timeout)
+		servico.Consultas = adaptadores.NovoRepositorioDeLeituraCassandra(sessao, consistLeitura, consistLeitura, *timeout)
+	default:
+		falhar(fmt.Errorf("alvo desconhecido: %s (use http|cql)", sub.alvo))
+	}
+
+	var gravadorDeAuditoria *auditoria.GravadorDeEscritas
+	if *registro != "" {
+		if gravadorDeAuditoria, err = auditoria.NovoGravadorDeEscritas(*registro); err != nil {
+			falhar(err)
+		}
+		servico.Persistencia = adaptadores.NovoRepositorioDeEscritaAuditado(servico.Persistencia, gravadorDeAuditoria, "carga "+nome, rodadas[0].consistencia)
+	}
+
+	sementeEfetiva := aplicacao.SementeOuAleatoria(*semente)
+	// Leituras sorteiam as partições que já existiam no início; escritas desta execução entram no arquivo ao final
+	var manifestoDeParticoes *conteudo.ManifestoDeParticoes
+	var particoesIniciais []conteudo.ParticaoDoManifesto // cópia: as escritas das rodadas não mudam o sorteio
+	if *manifesto != "" {
+		manifestoDeParticoes = conteudo.NovoManifestoDeParticoes()
+		if err := manifestoDeParticoes.Mesclar(*manifesto); err != nil {
+			falhar(err)
+		}
+		if aplicacao.MisturaContemLeituras(pesos) {
+			particoesIniciais = manifestoDeParticoes.Particoes()
+			_, err := aplicacao.NovaFonteDeParticoes(particoesIniciais, sementeEfetiva+4)
+			switch {
+			case err == nil:
+				fmt.Printf("carga %s: leituras sorteadas de %d particoes (%d linhas) de %s\n", nome, len(manifestoDeParticoes.Particoes()), manifestoDeParticoes.Linhas(), *manifesto)
+			case sub.operacoes == "leitura":
+				falhar(fmt.Errorf("%s: %w", *manifesto, err))
+			default:
+				fmt.Printf("carga %s: %s sem particoes; leituras no dia atual\n", nome, *manifesto)
+			}
+		}
+	}
+	if manifestoDeParticoes != nil {
+		servico.Persistencia = adaptadores.NovoRepositorioDeEscritaComManifesto(servico.Persistencia, manifestoDeParticoes)
+	}
+	parametrosDoConteudo := conteudo.ParametrosDoConteudo{QuantidadeDeEtiquetas: *etiquetas, TamanhoDoValor: *tamValor, Unidades: *unidades, Estados: *estados}
+
+	// Cada rodada recomeça as distribuições, o conteúdo e o sorteio de partições com a mesma semente
+	executar := func(rod rodada) RelatorioDaCarga {
+		if ajustavel, ok := servico.Persistencia.(portas.PortaDeConsistenciaAjustavel); ok {
+			if err := ajustavel.DefinirConsistencia(portas.OperacaoEscrita, rod.consistencia); err != nil {
+				falhar(err)
+			}
+		}
+		distribuicao, err := aplicacao.NovaDistribuicaoDeSensores(aplicacao.ParametrosDeDistribuicaoDeSensores{
+			Tipo: *distSensor, ExpoenteZipf: 1.1, FracaoQuente: 0.01, FracaoDoTrafego: 0.9,
+		}, *sensores, sementeEfetiva+1)
+		if err != nil {
+			falhar(err)
+		}
+		geradorDeConteudo, err := conteudo.NovoGeradorDeConteudo(parametrosDoConteudo, sementeEfetiva+3)
+		if err != nil {
+			falhar(err)
+		}
+		particoes, _ := aplicacao.NovaFonteDeParticoes(particoesIniciais, sementeEfetiva+4) // nil sem partições
+		coletor := adaptadores.NovoColetorDeCarga(time.Now())
+		servico.Metricas = coletor
+
+		fmt.Printf("carga %s: alvo=%s mistura=%s %s r=%s rps=%d conc=%d duracao=%s semente=%d\n",
+			nome, sub.alvo, textoDaMistura, rod.rotulo(), r, *rps, *conc, *duracao, sementeEfetiva)
+		cfg := aplicacao.ConfiguracaoDoTesteDeStress{
+			NivelDeConsistenciaTexto:          rod.consistencia,
+			NivelDeConsistenciaUltimasTexto:   r,
+			NivelDeConsistenciaIntervaloTexto: r,
+			DuracaoTotalDoTeste:               *duracao,
+			TaxaDeRequisicoesPorSegundo:       *rps,
+			GrauDeConcorrencia:                *conc,
+			QuantidadeDeSensoresDistintos:     *sensores,
+			MisturaDeOperacoes:                pesos,
+			TamanhoDoLote:                     rod.lote,
+			LimiteDeLeiturasUltimas:           *limite,
+			JanelasDeLeituraPorIntervalo:      larguras,
+			LimiteDeLeiturasPorIntervalo:      *limiteInt,
+			ParticoesDeLeitura:                particoes,
+			DistribuicaoDeSensores:            distribuicao,
+			SementeAleatoria:                  sementeEfetiva,
+			GeradorDeConteudo:                 geradorDeConteudo,
+			IntervaloDeLogDeProgresso:         *progresso,
+		}
+		resultado := servico.Executar(context.Background(), cfg)
+
+		relatorio := RelatorioDaCarga{
+			Subcomando: nome, Alvo: sub.alvo, Escrita: rod.consistencia, Leitura: r, Lote: rod.lote, Mistura: textoDaMistura, Semente: resultado.Semente,
+			Duracao: resultado.Duracao, Total: resultado.Total, Ok: resultado.Ok, PorOperacao: map[string]estatisticas.ResumoDaCarga{},
+		}
+		resumoDoConteudo := geradorDeConteudo.Resumo()
+		relatorio.Conteudo = resumoDoConteudo
+		ferramenta := "carga " + nome + " " + rod.rotulo()
+		for operacao, resumo := range coletor.Resumos(ferramenta, resultado.Duracao) {
+			if operacao == portas.OperacaoEscrita {
+				resumo.BytesPorLinha = resumoDoConteudo.BytesPorLinha
+			}
+			relatorio.PorOperacao[string(operacao)] = resumo
+		}
+		for operacao, faixas := range coletor.ResumosPorFaixa(ferramenta, resultado.Duracao) {
+			if relatorio.PorFaixa == nil {
+				relatorio.PorFaixa = map[string]map[string]estatisticas.ResumoDaCarga{}
+			}
+			relatorio.PorFaixa[string(operacao)] = faixas
+		}
+		fmt.Printf("carga %s fim: total=%d ok=%d duracao_ms=%d\n", nome, relatorio.Total, relatorio.Ok, relatorio.Duracao.Milliseconds())
+		for _, operacao := range operacoesOrdenadas(relatorio.PorOperacao) {
+			resumo := relatorio.PorOperacao[operacao]
+			fmt.Printf(" operacao=%s total=%d ok=%d cons=%s\n", operacao, resumo.Total, resumo.Ok,
+				cfg.ConsistenciaDaOperacao(portas.TipoDeOperacao(operacao)))
+			fmt.Print(estatisticas.FormatarResumoDaCarga(resumo))
+			if faixas, ok := relatorio.PorFaixa[operacao]; ok {
+				fmt.Print(estatisticas.FormatarFaixasDeResultado(faixas, resumo.Ok))
+			}
+		}
+		if resumoDoConteudo.Linhas > 0 {
+			fmt.Print(conteudo.FormatarResumoDoConteudo(resumoDoConteudo))
+		}
+		return relatorio
+	}
+
+	relatorios := make([]RelatorioDaCarga, 0, len(rodadas))
+	for _, rod := range rodadas {
+		relatorios = append(relatorios, executar(rod))
+	}
+
+	if gravadorDeAuditoria != nil {
+		if err := gravadorDeAuditoria.Fechar(); err != nil {
+			falhar(fmt.Errorf("registro de auditoria: %w", err))
+		}
+		fmt.Printf("carga %s: escritas registradas em %s\n", nome, *registro)
+	}
+	if manifestoDeParticoes != nil {
+		if err := manifestoDeParticoes.Gravar(*manifesto); err != nil {
+			falhar(err)
+		}
+		fmt.Printf("carga %s: manifesto %s: particoes=%d linhas=%d\n", nome, *manifesto, len(manifestoDeParticoes.Particoes()), manifestoDeParticoes.Linhas())
+	}
+	if len(relatorios) > 1 {
+		fmt.Print(formatarComparacao(relatorios))
+	}
+
+	if *saida != "" {
+		if len(relatorios) == 1 {
+			err = gravarRelatorio(*saida, relatorios[0])
+		} else {
+			err = gravarComparacao(*saida, relatorios)
+		}
+		if err != nil {
+			falhar(err)
+		}
+	}
+}
+
+// JSON: relatório inteiro. CSV: um arquivo por operação (<nome>-<operacao>.csv quando há mais de uma),
+// com as faixas de resultado das leituras em <arquivo>-faixas.csv.
+func gravarRelatorio(caminho string, relatorio RelatorioDaCarga) error {
+	if strings.EqualFold(filepath.Ext(caminho), ".json") {
+		conteudo, err := json.MarshalIndent(relatorio, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(caminho, conteudo, 0o644)
+	}
+	operacoes := operacoesOrdenadas(relatorio.PorOperacao)
+	for _, operacao := range operacoes {
+		destino := caminho
+		if len(operacoes) > 1 {
+			extensao := filepath.Ext(caminho)
+			destino = strings.TrimSuffix(caminho, extensao) + "-" + operacao + extensao
+		}
+		if err := estatisticas.GravarResumoDaCarga(destino, relatorio.PorOperacao[operacao]); err != nil {
+			return err
+		}
+		if faixas, ok := relatorio.PorFaixa[operacao]; ok {
+			if err := estatisticas.GravarFaixasDeResultado(destino, faixas); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Uma linha por rodada e operação.
+func formatarComparacao(relatorios []RelatorioDaCarga) string {
+	var b strings.Builder
+	b.WriteString("carga comparacao:\n")
+	fmt.Fprintf(&b, "  %-12s %6s %-18s %10s %10s %9s %9s %9s %8s %12s\n", "w", "lote", "operacao", "req_s", "itens_s", "p50_ms", "p99_ms", "max_ms", "erros", "itens_falha")
+	for _, relatorio := range relatorios {
+		for _, operacao := range operacoesOrdenadas(relatorio.PorOperacao) {
+			s := relatorio.PorOperacao[operacao]
+			fmt.Fprintf(&b, "  %-12s %6d %-18s %10.1f %10.1f %9.2f %9.2f %9.2f %8d %12d\n", relatorio.Escrita, relatorio.Lote, operacao,
+				s.VazaoOpsS, s.VazaoItens, s.Cliente.P50, s.Cliente.P99, s.Cliente.Max, s.Erros, s.ItensFalha)
+		}
+	}
+	return b.String()
+}
+
+// Comparação entre rodadas: JSON com a lista de relatórios ou CSV com uma linha por rodada e operação.
+func gravarComparacao(caminho string, relatorios []RelatorioDaCarga) error {
+	if strings.EqualFold(filepath.Ext(caminho), ".json") {
+		conteudo, err := json.MarshalIndent(relatorios, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(caminho, conteudo, 0o644)
+	}
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+	w := csv.NewWriter(arquivo)
+	w.Write([]string{"w", "lote", "operacao", "total", "ok", "erros", "itens", "itens_com_falha", "vazao_req_s", "vazao_itens_s",
+		"p50_ms", "p95_ms", "p99_ms", "max_ms", "servidor_p50_ms", "servidor_p99_ms"})
+	decimal := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	for _, relatorio := range relatorios {
+		for _, operacao := range operacoesOrdenadas(relatorio.PorOperacao) {
+			s := relatorio.PorOperacao[operacao]
+			w.Write([]string{relatorio.Escrita, strconv.Itoa(relatorio.Lote), operacao, strconv.FormatInt(s.Total, 10), strconv.FormatInt(s.Ok, 10),
+				strconv.FormatInt(s.Erros, 10), strconv.FormatInt(s.Itens, 10), strconv.FormatInt(s.ItensFalha, 10),
+				decimal(s.VazaoOpsS), decimal(s.VazaoItens), decimal(s.Cliente.P50), decimal(s.Cliente.P95), decimal(s.Cliente.P99),
+				decimal(s.Cliente.Max), decimal(s.Servidor.P50), decimal(s.Servidor.P99)})
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func operacoesOrdenadas(porOperacao map[string]estatisticas.ResumoDaCarga) []string {
+	operacoes := make([]string, 0, len(porOperacao))
+	for operacao := range porOperacao {
+		operacoes = append(operacoes, operacao)
+	}
+	sort.Strings(operacoes)
+	return operacoes
+}
+
+func uso() {
+	fmt.Fprintln(os.Stderr, "uso: carga <subcomando> [flags]")
+	nomes := make([]string, 0, len(subcomandos))
+	for nome := range subcomandos {
+		nomes = append(nomes, nome)
+	}
+	sort.Strings(nomes)
+	for _, nome := range nomes {
+		fmt.Fprintf(os.Stderr, "  %-11s %s\n", nome, subcomandos[nome].descricao)
+	}
+	fmt.Fprintln(os.Stderr, "flags de cada subcomando: carga <subcomando> -h")
+}
+
+func falhar(err error) {
+	fmt.Printf("carga: %v\n", err)
+	os.Exit(1)
+}
+
+func dividirLista(lista string) []string {
+	var saida []string
+	for _, p := range strings.Split(lista, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			saida = append(saida, p)
+		}
+	}
+	return saida
+}
+
+func valorOu(chave, padrao string) string {
+	if v := strings.TrimSpace(os.Getenv(chave)); v != "" {
+		return v
+	}
+	return padrao
+}
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gocql/gocql"
+	"github.com/pdrpinto/tcc-cassandra/internal/auditoria"
+	"github.com/pdrpinto/tcc-cassandra/internal/conteudo"
 	"github.com/pdrpinto/tcc-cassandra/internal/stress/adaptadores"
 	"github.com/pdrpinto/tcc-cassandra/internal/stress/aplicacao"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
 )
 
 func converteConsistencia(texto string) gocql.Consistency {
@@ -40,27 +44,141 @@ func main() {
 		hostsPadrao       = valorOu("CASSANDRA_HOSTS", "cassandra1:9042,cassandra2:9042,cassandra3:9042")
 		keyspacePadrao    = valorOu("CASSANDRA_KEYSPACE", "tcc")
 		consistPadrao     = valorOu("CONSISTENCY", "QUORUM")
+		consistUltPadrao  = valorOu("CONSISTENCY_LATEST", consistPadrao)
+		consistIntPadrao  = valorOu("CONSISTENCY_RANGE", consistPadrao)
+		misturaPadrao     = valorOu("MIX", "escrita=100")
+		limitePadrao      = valorOuInteiro("LATEST_LIMIT", 10)
+		janelaPadrao      = valorOu("RANGE_WINDOW", "5m")
+		distChavesPadrao  = valorOu("KEY_DIST", "uniforme")
+		zipfPadrao        = valorOuReal("ZIPF_S", 1.1)
+		fracQuentePadrao  = valorOuReal("HOT_KEYS", 0.01)
+		fracTrafegoPadrao = valorOuReal("HOT_TRAFFIC", 0.9)
+		taxasPadrao       = valorOu("SENSOR_RATES", "")
+		distTsPadrao      = valorOu("TS_DIST", "atual")
+		atrasoTsPadrao    = valorOu("TS_JITTER", "30s")
+		probRetroPadrao   = valorOuReal("TS_BACKDATE_PROB", 0.05)
+		diasRetroPadrao   = valorOuInteiro("TS_BACKDATE_DAYS", 7)
+		inclRetroPadrao   = valorOuReal("TS_BACKDATE_SKEW", 1.0)
+		sementePadrao     = valorOuInteiro("SEED", 0)
+		perfilPadrao      = valorOu("PROFILE", "")
+		saturacaoPadrao   = valorOu("SATURATION", "false") == "true"
+		satConsistPadrao  = valorOu("SAT_CONSISTENCIES", "ONE,QUORUM,ALL")
 		duracaoPadrao     = valorOu("DURATION", "0s") // 0 = contínuo
 		rpsPadrao         = valorOuInteiro("RPS", 1000)
 		concPadrao        = valorOuInteiro("CONC", runtime.NumCPU()*2)
 		sensoresPadrao    = valorOuInteiro("SENSORS", 5000)
 		metricsAddrPadrao = valorOu("METRICS_ADDR", ":9100")
 		progressoPadrao   = valorOu("PROGRESS_INTERVAL", "5s")
+		execucaoPadrao    = valorOu("RUN_NAME", "padrao")
+		manterPadrao      = valorOu("KEEP_ALIVE", "false") == "true"
+		aguardarPadrao    = valorOu("WAIT_START", "false") == "true"
+		auditoriaPadrao   = valorOu("AUDIT_LOG", "")
+		manifestoPadrao   = valorOu("MANIFEST", "")
+		etiquetasPadrao   = valorOu("TAGS", "3")
+		tamValorPadrao    = valorOu("TAG_VALUE_LEN", "8")
+		unidadesPadrao    = valorOu("UNITS", "C")
+		estadosPadrao     = valorOu("STATUS_MIX", "0")
 	)
 
 	var (
 		parametroListaDeHostsCassandra         = flag.String("hosts", hostsPadrao, "Hosts do Cassandra separados por vírgula")
 		parametroNomeDoKeyspace                = flag.String("keyspace", keyspacePadrao, "Keyspace a utilizar")
-		parametroNivelDeConsistencia           = flag.String("consistency", consistPadrao, "Nível de consistência")
+		parametroNivelDeConsistencia           = flag.String("consistency", consistPadrao, "Nível de consistência das escritas")
+		parametroConsistenciaUltimas           = flag.String("consistency-latest", consistUltPadrao, "Nível de consistência das leituras ultimas N")
+		parametroConsistenciaIntervalo         = flag.String("consistency-range", consistIntPadrao, "Nível de consistência das leituras por intervalo")
+		parametroMisturaDeOperacoes            = flag.String("mix", misturaPadrao, "Mistura ponderada de operações (ex.: escrita=70,ultimas=20,intervalo=10)")
+		parametroLimiteDeLeiturasUltimas       = flag.Int("latest-limit", limitePadrao, "N das leituras ultimas N")
+		parametroJanelaDeLeituraPorIntervalo   = flag.Duration("range-window", deveParsearDuracao(janelaPadrao), "Largura da janela das leituras por intervalo")
+		parametroDistribuicaoDeSensores        = flag.String("key-dist", distChavesPadrao, "Distribuição dos sensores: uniforme|zipf|hotspot|ponderada")
+		parametroExpoenteZipf                  = flag.Float64("zipf-s", zipfPadrao, "Expoente da distribuição zipf (>1)")
+		parametroFracaoQuente                  = flag.Float64("hot-keys", fracQuentePadrao, "Hotspot: fração dos sensores quentes")
+		parametroFracaoDoTrafego               = flag.Float64("hot-traffic", fracTrafegoPadrao, "Hotspot: fração do tráfego nos sensores quentes")
+		parametroTaxasPorSensor                = flag.String("sensor-rates", taxasPadrao, "Pesos relativos por sensor (ex.: 0:50,1-9:5; demais=1)")
+		parametroDistribuicaoDeInstantes       = flag.String("ts-dist", distTsPadrao, "Distribuição dos instantes: atual|desordenado|retroativo")
+		parametroAtrasoMaximo                  = flag.Duration("ts-jitter", deveParsearDuracao(atrasoTsPadrao), "Atraso máximo dos eventos fora de ordem")
+		parametroProbabilidadeRetroativa       = flag.Float64("ts-backdate-prob", probRetroPadrao, "Fração das escritas enviadas a dias anteriores")
+		parametroDiasRetroativos               = flag.Int("ts-backdate-days", diasRetroPadrao, "Máximo de dias para trás nas escritas retroativas")
+		parametroInclinacaoDosDias             = flag.Float64("ts-backdate-skew", inclRetroPadrao, "Viés para dias recentes (peso do dia k = 1/k^skew)")
+		parametroSemente                       = flag.Int64("seed", int64(sementePadrao), "Semente aleatória (0=derivada do relógio)")
+		parametroPerfilDeCarga                 = flag.String("profile", perfilPadrao, "Perfil de carga: arquivo YAML/JSON ou sintaxe compacta (ex.: rampa:60s:1000,constante:5m:1000)")
+		parametroSaturacao                     = flag.Bool("saturation", saturacaoPadrao, "Busca o ponto de saturação por nível de consistência")
+		parametroSatConsistencias              = flag.String("sat-consistencies", satConsistPadrao, "Níveis de consistência avaliados na busca de saturação")
+		parametroSatTaxaInicial                = flag.Float64("sat-start", 100, "Saturação: taxa inicial (req/s)")
+		parametroSatIncremento                 = flag.Float64("sat-step", 100, "Saturação: incremento por degrau (req/s)")
+		parametroSatDuracaoDoDegrau            = flag.Duration("sat-step-dur", 30*time.Second, "Saturação: duração de cada degrau")
+		parametroSatAquecimento                = flag.Duration("sat-warmup", 5*time.Second, "Saturação: aquecimento descartado em cada degrau")
+		parametroSatLimiteP99                  = flag.Duration("sat-p99", 200*time.Millisecond, "Saturação: p99 máximo aceitável")
+		parametroSatLimiteErros                = flag.Float64("sat-max-errors", 0.01, "Saturação: fração máxima de erros")
+		parametroSatTaxaMaxima                 = flag.Float64("sat-max", 0, "Saturação: teto da taxa (0=sem teto)")
 		parametroDuracaoTotalDoTeste           = flag.Duration("dur", deveParsearDuracao(duracaoPadrao), "Duração total do teste")
 		parametroTaxaDeRequisicoesPorSegundo   = flag.Int("rps", rpsPadrao, "RPS desejado")
 		parametroGrauDeConcorrencia            = flag.Int("conc", concPadrao, "Concorrência")
 		parametroQuantidadeDeSensoresDistintos = flag.Int("sensors", sensoresPadrao, "Sensores distintos")
 		parametroEnderecoDeMetricas            = flag.String("metrics", metricsAddrPadrao, "Endereço :porta de métricas Prometheus")
 		parametroIntervaloDeLogs               = flag.Duration("progress", deveParsearDuracao(progressoPadrao), "Intervalo para logs de progresso (0=desliga)")
+		parametroNomeDaExecucao                = flag.String("run-name", execucaoPadrao, "Nome da execução (rótulo execucao nas métricas)")
+		parametroManterAtivo                   = flag.Bool("keep-alive", manterPadrao, "Após o fim da execução, aguarda novas execuções via POST /controle/iniciar")
+		parametroAguardarInicio                = flag.Bool("wait-start", aguardarPadrao, "Não inicia carga até POST /controle/iniciar")
+		parametroRegistroDeAuditoria           = flag.String("audit-log", auditoriaPadrao, "Arquivo JSONL com as escritas (sensor_id, ts) para a auditoria de escritas perdidas")
+		parametroManifesto                     = flag.String("manifesto", manifestoPadrao, "Arquivo JSON com as partições escritas (sensor, dia, linhas) para carga http-read/cql-read; acumula entre execuções")
+		parametroQuantidadeDeEtiquetas         = flag.String("tags", etiquetasPadrao, "Etiquetas por leitura: n, minimo-maximo ou normal:media,desvio (ex.: 5-30)")
+		parametroTamanhoDoValor                = flag.String("tag-value-len", tamValorPadrao, "Caracteres do valor de cada etiqueta (mesma sintaxe de -tags)")
+		parametroUnidades                      = flag.String("units", unidadesPadrao, "Mistura ponderada de unidades (ex.: C=60,%=30,hPa=10)")
+		parametroEstados                       = flag.String("status-mix", estadosPadrao, "Mistura ponderada de status (ex.: 0=97,1=2,2=1)")
 	)
 	flag.Parse()
 
+	mistura, err := aplicacao.ParsearMisturaDeOperacoes(*parametroMisturaDeOperacoes)
+	if err != nil {
+		panic(err)
+	}
+
+	// Distribuições de chaves e instantes (semente registrada para reprodutibilidade)
+	semente := aplicacao.SementeOuAleatoria(*parametroSemente)
+	distribuicaoDeSensores, err := aplicacao.NovaDistribuicaoDeSensores(aplicacao.ParametrosDeDistribuicaoDeSensores{
+		Tipo:            *parametroDistribuicaoDeSensores,
+		ExpoenteZipf:    *parametroExpoenteZipf,
+		FracaoQuente:    *parametroFracaoQuente,
+		FracaoDoTrafego: *parametroFracaoDoTrafego,
+		TaxasPorSensor:  *parametroTaxasPorSensor,
+	}, *parametroQuantidadeDeSensoresDistintos, semente+1)
+	if err != nil {
+		panic(err)
+	}
+	distribuicaoDeInstantes, err := aplicacao.NovaDistribuicaoDeInstantes(aplicacao.ParametrosDeDistribuicaoDeInstantes{
+		Tipo:                    *parametroDistribuicaoDeInstantes,
+		AtrasoMaximo:            *parametroAtrasoMaximo,
+		ProbabilidadeRetroativa: *parametroProbabilidadeRetroativa,
+		DiasRetroativosMaximos:  *parametroDiasRetroativos,
+		InclinacaoDosDias:       *parametroInclinacaoDosDias,
+	}, semente+2)
+	if err != nil {
+		panic(err)
+	}
+	geradorDeConteudo, err := conteudo.NovoGeradorDeConteudo(conteudo.ParametrosDoConteudo{
+		QuantidadeDeEtiquetas: *parametroQuantidadeDeEtiquetas,
+		TamanhoDoValor:        *parametroTamanhoDoValor,
+		Unidades:              *parametroUnidades,
+		Estados:               *parametroEstados,
+	}, semente+3)
+	if err != nil {
+		panic(err)
+	}
+	var perfil *aplicacao.PerfilDeCarga
+	if texto := strings.TrimSpace(*parametroPerfilDeCarga); texto != "" {
+		var p aplicacao.PerfilDeCarga
+		if _, errArquivo := os.Stat(texto); errArquivo == nil {
+			p, err = aplicacao.CarregarPerfilDeCarga(texto)
+		} else {
+			p, err = aplicacao.ParsearPerfilCompacto(texto)
+		}
+		if err != nil {
+			panic(err)
+		}
+		perfil = &p
+	}
+	fmt.Printf("go-stress: seed=%d key-dist=%s ts-dist=%s mix=%s\n", semente, *parametroDistribuicaoDeSensores, *parametroDistribuicaoDeInstantes, *parametroMisturaDeOperacoes)
+
 	// Conexão Cassandra
 	cluster := gocql.NewCluster(dividirHosts(*parametroListaDeHostsCassandra)...)
 	cluster.Keyspace = *parametroNomeDoKeyspace
@@ -76,26 +194,160 @@ func main() {
 	}
 	defer sessao.Close()
 
+	// Registro das escritas para a auditoria (opcional)
+	var gravadorDeAuditoria *auditoria.GravadorDeEscritas
+	if *parametroRegistroDeAuditoria != "" {
+		gravadorDeAuditoria, err = auditoria.NovoGravadorDeEscritas(*parametroRegistroDeAuditoria)
+		if err != nil {
+			panic(err)
+		}
+		defer func() {
+			if err := gravadorDeAuditoria.Fechar(); err != nil {
+				fmt.Printf("go-stress: registro de auditoria: %v\n", err)
+			}
+		}()
+		fmt.Printf("go-stress: registrando escritas para auditoria em %s\n", *parametroRegistroDeAuditoria)
+	}
+	// Manifesto das partições escritas (opcional; mesclado com o arquivo existente)
+	var manifestoDeParticoes *conteudo.ManifestoDeParticoes
+	if *parametroManifesto != "" {
+		manifestoDeParticoes = conteudo.NovoManifestoDeParticoes()
+		if err := manifestoDeParticoes.Mesclar(*parametroManifesto); err != nil {
+			panic(err)
+		}
+	}
+	gravarManifesto := func() {
+		if manifestoDeParticoes == nil {
+			return
+		}
+		if err := manifestoDeParticoes.Gravar(*parametroManifesto); err != nil {
+			fmt.Printf("go-stress: manifesto: %v\n", err)
+			return
+		}
+		fmt.Printf("go-stress: manifesto %s: particoes=%d linhas=%d\n", *parametroManifesto, len(manifestoDeParticoes.Particoes()), manifestoDeParticoes.Linhas())
+	}
+	novoRepositorioDeEscrita := func(consist gocql.Consistency) portas.PortaDeEscrita {
+		var repositorio portas.PortaDeEscrita = adaptadores.NovoRepositorioDeEscritaCassandra(sessao, consist, 5*time.Second)
+		if gravadorDeAuditoria != nil {
+			repositorio = adaptadores.NovoRepositorioDeEscritaAuditado(repositorio, gravadorDeAuditoria, "go-stress", consist.String())
+		}
+		if manifestoDeParticoes != nil {
+			repositorio = adaptadores.NovoRepositorioDeEscritaComManifesto(repositorio, manifestoDeParticoes)
+		}
+		return repositorio
+	}
+
 	// Adaptadores
 	adaptadorDeMetricas := adaptadores.NovoRegistradorDeMetricas()
-	adaptadores.IniciarServidorDeMetricas(*parametroEnderecoDeMetricas)
-	repositorioDeEscrita := adaptadores.NovoRepositorioDeEscritaCassandra(sessao, converteConsistencia(*parametroNivelDeConsistencia), 5*time.Second)
+	controle := aplicacao.NovoControleDeExecucao(*parametroNomeDaExecucao)
+	if err := adaptadores.IniciarServidorDeMetricas(*parametroEnderecoDeMetricas, controle); err != nil {
+		panic(err)
+	}
+	repositorioDeEscrita := novoRepositorioDeEscrita(converteConsistencia(*parametroNivelDeConsistencia))
+	repositorioDeLeitura := adaptadores.NovoRepositorioDeLeituraCassandra(sessao,
+		converteConsistencia(*parametroConsistenciaUltimas), converteConsistencia(*parametroConsistenciaIntervalo), 5*time.Second)
 
 	// Serviço de aplicação (orquestra a carga)
-	servico := aplicacao.ServicoDeStress{Persistencia: repositorioDeEscrita, Metricas: adaptadorDeMetricas}
+	servico := aplicacao.ServicoDeStress{Persistencia: repositorioDeEscrita, Consultas: repositorioDeLeitura, Metricas: adaptadorDeMetricas, Controle: controle}
 	cfg := aplicacao.ConfiguracaoDoTesteDeStress{
-		ListaDeHostsCassandra:         dividirHosts(*parametroListaDeHostsCassandra),
-		NomeDoKeyspace:                *parametroNomeDoKeyspace,
-		NivelDeConsistenciaTexto:      strings.ToUpper(*parametroNivelDeConsistencia),
-		DuracaoTotalDoTeste:           *parametroDuracaoTotalDoTeste,
-		TaxaDeRequisicoesPorSegundo:   *parametroTaxaDeRequisicoesPorSegundo,
-		GrauDeConcorrencia:            *parametroGrauDeConcorrencia,
-		QuantidadeDeSensoresDistintos: *parametroQuantidadeDeSensoresDistintos,
-		IntervaloDeLogDeProgresso:     *parametroIntervaloDeLogs,
-	}
-
-	total, ok, dur := servico.Executar(context.Background(), cfg)
-	fmt.Printf("go-stress concluido: total=%d ok=%d duracao_ms=%d cons=%s\n", total, ok, dur.Milliseconds(), cfg.NivelDeConsistenciaTexto)
+		ListaDeHostsCassandra:             dividirHosts(*parametroListaDeHostsCassandra),
+		NomeDoKeyspace:                    *parametroNomeDoKeyspace,
+		NivelDeConsistenciaTexto:          strings.ToUpper(*parametroNivelDeConsistencia),
+		NivelDeConsistenciaUltimasTexto:   strings.ToUpper(*parametroConsistenciaUltimas),
+		NivelDeConsistenciaIntervaloTexto: strings.ToUpper(*parametroConsistenciaIntervalo),
+		DuracaoTotalDoTeste:               *parametroDuracaoTotalDoTeste,
+		TaxaDeRequisicoesPorSegundo:       *parametroTaxaDeRequisicoesPorSegundo,
+		GrauDeConcorrencia:                *parametroGrauDeConcorrencia,
+		QuantidadeDeSensoresDistintos:     *parametroQuantidadeDeSensoresDistintos,
+		MisturaDeOperacoes:                mistura,
+		LimiteDeLeiturasUltimas:           *parametroLimiteDeLeiturasUltimas,
+		JanelaDeLeituraPorIntervalo:       *parametroJanelaDeLeituraPorIntervalo,
+		DistribuicaoDeSensores:            distribuicaoDeSensores,
+		DistribuicaoDeInstantes:           distribuicaoDeInstantes,
+		SementeAleatoria:                  semente,
+		PerfilDeCarga:                     perfil,
+		GeradorDeConteudo:                 geradorDeConteudo,
+		IntervaloDeLogDeProgresso:         *parametroIntervaloDeLogs,
+	}
+
+	if *parametroSaturacao {
+		criterio := aplicacao.CriterioDeSaturacao{
+			TaxaInicial:      *parametroSatTaxaInicial,
+			Incremento:       *parametroSatIncremento,
+			DuracaoDoDegrau:  *parametroSatDuracaoDoDegrau,
+			Aquecimento:      *parametroSatAquecimento,
+			LimiteP99:        *parametroSatLimiteP99,
+			LimiteTaxaDeErro: *parametroSatLimiteErros,
+			TaxaMaxima:       *parametroSatTaxaMaxima,
+		}
+		var resultados []aplicacao.ResultadoDaSaturacao
+		for _, nivel := range strings.Split(*parametroSatConsistencias, ",") {
+			nivel = strings.ToUpper(strings.TrimSpace(nivel))
+			if nivel == "" {
+				continue
+			}
+			consist := converteConsistencia(nivel)
+			servicoNivel := aplicacao.ServicoDeStress{
+				Persistencia: novoRepositorioDeEscrita(consist),
+				Consultas:    adaptadores.NovoRepositorioDeLeituraCassandra(sessao, consist, consist, 5*time.Second),
+				Metricas:     adaptadorDeMetricas,
+			}
+			cfgNivel := cfg
+			cfgNivel.NivelDeConsistenciaTexto = nivel
+			cfgNivel.NivelDeConsistenciaUltimasTexto = nivel
+			cfgNivel.NivelDeConsistenciaIntervaloTexto = nivel
+			res, err := servicoNivel.BuscarPontoDeSaturacao(context.Background(), cfgNivel, criterio)
+			if err != nil {
+				panic(err)
+			}
+			resultados = append(resultados, res)
+		}
+		fmt.Println("go-stress saturacao (vazao maxima sustentavel por consistencia):")
+		for _, r := range resultados {
+			fmt.Printf("  cons=%s max_ops_s=%.0f saturou_em=%.0f motivo=%q degraus=%d\n",
+				r.Consistencia, r.TaxaMaximaSustentavel, r.TaxaAlvoNaSaturacao, r.Motivo, len(r.Degraus))
+		}
+		fmt.Print(conteudo.FormatarResumoDoConteudo(geradorDeConteudo.Resumo()))
+		gravarManifesto()
+		return
+	}
+
+	executar := func(cfgExec aplicacao.ConfiguracaoDoTesteDeStress) {
+		res := servico.Executar(context.Background(), cfgExec)
+		fmt.Printf("go-stress concluido: execucao=%s total=%d ok=%d duracao_ms=%d cons=%s seed=%d\n",
+			controle.NomeDaExecucao(), res.Total, res.Ok, res.Duracao.Milliseconds(), cfgExec.NivelDeConsistenciaTexto, res.Semente)
+		for _, m := range mistura {
+			r := res.PorOperacao[m.Operacao]
+			fmt.Printf("  operacao=%s total=%d ok=%d cons=%s\n", m.Operacao, r.Total, r.Ok, cfgExec.ConsistenciaDaOperacao(m.Operacao))
+		}
+		fmt.Print(conteudo.FormatarResumoDoConteudo(geradorDeConteudo.Resumo()))
+		gravarManifesto()
+	}
+	// Aguarda um pedido da API de controle e executa com a duração solicitada (se houver)
+	executarPedido := func() bool {
+		fmt.Printf("go-stress: aguardando POST /controle/iniciar em %s\n", *parametroEnderecoDeMetricas)
+		pedido, ok := controle.AguardarPedido(context.Background())
+		if !ok {
+			return false
+		}
+		cfgExec := cfg
+		if pedido.Duracao > 0 {
+			cfgExec.DuracaoTotalDoTeste = pedido.Duracao
+		}
+		executar(cfgExec)
+		return true
+	}
+
+	if *parametroAguardarInicio || *parametroManterAtivo {
+		controle.AceitarPedidos()
+	}
+	if *parametroAguardarInicio {
+		executarPedido()
+	} else {
+		executar(cfg)
+	}
+	for *parametroManterAtivo && executarPedido() {
+	}
 }
 
 func dividirHosts(lista string) []string {
@@ -127,6 +379,15 @@ func valorOuInteiro(chave string, padrao int) int {
 	return padrao
 }
 
+func valorOuReal(chave string, padrao float64) float64 {
+	if v := strings.TrimSpace(os.Getenv(chave)); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return padrao
+}
+
 func deveParsearDuracao(s string) time.Duration {
 	d, err := time.ParseDuration(s)
 	if err != nil {
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	injApp "github.com/pdrpinto/tcc-cassandra/internal/falhas/aplicacao"
+	injPorts "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/aplicacao"
+	"gopkg.in/yaml.v3"
+)
+
+// Cenário de experimento (YAML ou JSON): carga, plano de falhas, sondas e níveis de consistência.
+// Cada nível de consistência gera uma rodada completa (carga + falhas + sondas) no mesmo relógio.
+type Cenario struct {
+	Nome                  string                  `yaml:"nome" json:"nome"`
+	Cassandra             CassandraDoCenario      `yaml:"cassandra" json:"cassandra"`
+	Carga                 CargaDoCenario          `yaml:"carga" json:"carga"`
+	Falhas                []injPorts.EtapaDoPlano `yaml:"falhas" json:"falhas"`
+	Sondas                []SondaDoCenario        `yaml:"sondas" json:"sondas"`
+	Consistencias         []string                `yaml:"consistencias" json:"consistencias"`
+	IntervaloEntreRodadas string                  `yaml:"intervalo_entre_rodadas" json:"intervalo_entre_rodadas"`
+	Saida                 string                  `yaml:"saida" json:"saida"`   // .csv ou .json
+	Matriz                *MatrizDoCenario        `yaml:"matriz" json:"matriz"` // substitui "consistencias"
+}
+
+// Varredura de consistências: cada célula (W, R) é uma rodada com a mesma carga e o mesmo plano de falhas.
+type MatrizDoCenario struct {
+	Escritas                  []string `yaml:"escritas" json:"escritas"`
+	Leituras                  []string `yaml:"leituras" json:"leituras"`
+	FatorDeReplicacao         int      `yaml:"rf" json:"rf"`                                             // 0 = lido de system_schema.keyspaces
+	IntervaloDeDesatualizacao string   `yaml:"intervalo_desatualizacao" json:"intervalo_desatualizacao"` // sonda escrita->leitura; padrão 200ms
+	Saida                     string   `yaml:"saida" json:"saida"`                                       // .csv ou .json; o resumo Markdown vai ao lado (.md)
+}
+
+type CassandraDoCenario struct {
+	Hosts    []string `yaml:"hosts" json:"hosts"`
+	Keyspace string   `yaml:"keyspace" json:"keyspace"`
+	Nos      []string `yaml:"nos" json:"nos"` // containers de todos os nós (aguardar hints_drenados sem container); padrão cassandra1..3
+}
+
+// Carga via ServicoDeStress (CQL). Perfil compacto ou detalhado; sem perfil usa rps constante por "duracao".
+type CargaDoCenario struct {
+	Perfil          string                            `yaml:"perfil" json:"perfil"`
+	PerfilDetalhado *aplicacao.PerfilDeCargaEmArquivo `yaml:"perfil_detalhado" json:"perfil_detalhado"`
+	Rps             int                               `yaml:"rps" json:"rps"`
+	Duracao         string                            `yaml:"duracao" json:"duracao"`
+	Concorrencia    int                               `yaml:"concorrencia" json:"concorrencia"`
+	Sensores        int                               `yaml:"sensores" json:"sensores"`
+	Mistura         string                            `yaml:"mistura" json:"mistura"`
+	LimiteUltimas   int                               `yaml:"limite_ultimas" json:"limite_ultimas"`
+	JanelaIntervalo string                            `yaml:"janela_intervalo" json:"janela_intervalo"`
+	Semente         int64                             `yaml:"semente" json:"semente"`
+}
+
+// Sonda HTTP de disponibilidade (2xx-4xx conta como disponível).
+type SondaDoCenario struct {
+	Nome      string `yaml:"nome" json:"nome"`
+	URL       string `yaml:"url" json:"url"`
+	Intervalo string `yaml:"intervalo" json:"intervalo"`
+	Timeout   string `yaml:"timeout" json:"timeout"`
+}
+
+// Configuração derivada do cenário, já validada e convertida.
+type CenarioPreparado struct {
+	Cenario
+	Perfil                *aplicacao.PerfilDeCarga
+	Mistura               []aplicacao.PesoDeOperacao
+	Duracao               time.Duration
+	JanelaIntervalo       time.Duration
+	IntervaloEntreRodadas time.Duration
+	Sondas                []SondaPreparada
+	Rodadas               []RodadaDoCenario
+	// Só na matriz: intervalo da sonda de desatualização (0 = sem sonda)
+	IntervaloDeDesatualizacao time.Duration
+}
+
+// Consistências de escrita e leitura de uma rodada.
+type RodadaDoCenario struct {
+	Escrita string
+	Leitura string
+}
+
+// "QUORUM" quando escrita e leitura coincidem; senão "ONE/ALL" (escrita/leitura).
+func (r RodadaDoCenario) Rotulo() string {
+	if r.Escrita == r.Leitura {
+		return r.Escrita
+	}
+	return r.Escrita + "/" + r.Leitura
+}
+
+type SondaPreparada struct {
+	Nome      string
+	URL       string
+	Intervalo time.Duration
+	Timeout   time.Duration
+}
+
+func CarregarCenario(caminho string) (CenarioPreparado, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return CenarioPreparado{}, err
+	}
+	var c Cenario
+	if err := yaml.Unmarshal(conteudo, &c); err != nil {
+		return CenarioPreparado{}, fmt.Errorf("cenario %s invalido: %w", caminho, err)
+	}
+	return prepararCenario(c)
+}
+
+func prepararCenario(c Cenario) (CenarioPreparado, error) {
+	p := CenarioPreparado{Cenario: c}
+	if p.Nome == "" {
+		p.Nome = "experimento"
+	}
+	if len(p.Consistencias) == 0 {
+		p.Consistencias = []string{"QUORUM"}
+	}
+	if err := normalizarConsistencias(p.Consistencias); err != nil {
+		return CenarioPreparado{}, err
+	}
+	var err error
+	if p.Matriz != nil {
+		if len(p.Matriz.Escritas) == 0 || len(p.Matriz.Leituras) == 0 {
+			return CenarioPreparado{}, fmt.Errorf("matriz exige escritas e leituras")
+		}
+		if err := normalizarConsistencias(p.Matriz.Escritas); err != nil {
+			return CenarioPreparado{}, fmt.Errorf("matriz.escritas: %w", err)
+		}
+		if err := normalizarConsistencias(p.Matriz.Leituras); err != nil {
+			return CenarioPreparado{}, fmt.Errorf("matriz.leituras: %w", err)
+		}
+		if p.Matriz.FatorDeReplicacao < 0 {
+			return CenarioPreparado{}, fmt.Errorf("matriz.rf invalido: %d", p.Matriz.FatorDeReplicacao)
+		}
+		p.IntervaloDeDesatualizacao = 200 * time.Millisecond
+		if p.Matriz.IntervaloDeDesatualizacao != "" {
+			if p.IntervaloDeDesatualizacao, err = time.ParseDuration(p.Matriz.IntervaloDeDesatualizacao); err != nil || p.IntervaloDeDesatualizacao <= 0 {
+				return CenarioPreparado{}, fmt.Errorf("matriz.intervalo_desatualizacao invalido: %q", p.Matriz.IntervaloDeDesatualizacao)
+			}
+		}
+		for _, w := range p.Matriz.Escritas {
+			for _, r := range p.Matriz.Leituras {
+				p.Rodadas = append(p.Rodadas, RodadaDoCenario{Escrita: w, Leitura: r})
+			}
+		}
+	} else {
+		for _, nivel := range p.Consistencias {
+			p.Rodadas = append(p.Rodadas, RodadaDoCenario{Escrita: nivel, Leitura: nivel})
+		}
+	}
+	if p.Carga.Concorrencia <= 0 {
+		p.Carga.Concorrencia = 64
+	}
+	if p.Carga.Sensores <= 0 {
+		p.Carga.Sensores = 1000
+	}
+
+	switch {
+	case p.Carga.PerfilDetalhado != nil:
+		perfil, errPerfil := p.Carga.PerfilDetalhado.Converter()
+		if errPerfil != nil {
+			return CenarioPreparado{}, fmt.Errorf("carga.perfil_detalhado: %w", errPerfil)
+		}
+		p.Perfil = &perfil
+	case strings.TrimSpace(p.Carga.Perfil) != "":
+		perfil, errPerfil := aplicacao.ParsearPerfilCompacto(p.Carga.Perfil)
+		if errPerfil != nil {
+			return CenarioPreparado{}, fmt.Errorf("carga.perfil: %w", errPerfil)
+		}
+		p.Perfil = &perfil
+	}
+	if p.Carga.Duracao != "" {
+		if p.Duracao, err = time.ParseDuration(p.Carga.Duracao); err != nil {
+			return CenarioPreparado{}, fmt.Errorf("carga.duracao invalida: %w", err)
+		}
+	}
+	if p.Duracao <= 0 && p.Perfil != nil {
+		p.Duracao = p.Perfil.DuracaoTotal()
+	}
+	if p.Duracao <= 0 {
+		return CenarioPreparado{}, fmt.Errorf("informe carga.duracao ou um perfil de carga")
+	}
+	if p.Perfil == nil && p.Carga.Rps <= 0 {
+		return CenarioPreparado{}, fmt.Errorf("informe carga.rps ou um perfil de carga")
+	}
+
+	misturaTexto := p.Carga.Mistura
+	if misturaTexto == "" {
+		misturaTexto = "escrita=100"
+	}
+	if p.Mistura, err = aplicacao.ParsearMisturaDeOperacoes(misturaTexto); err != nil {
+		return CenarioPreparado{}, fmt.Errorf("carga.mistura: %w", err)
+	}
+	if p.Carga.JanelaIntervalo != "" {
+		if p.JanelaIntervalo, err = time.ParseDuration(p.Carga.JanelaIntervalo); err != nil {
+			return CenarioPreparado{}, fmt.Errorf("carga.janela_intervalo invalida: %w", err)
+		}
+	}
+	if c.IntervaloEntreRodadas != "" {
+		if p.IntervaloEntreRodadas, err = time.ParseDuration(c.IntervaloEntreRodadas); err != nil {
+			return CenarioPreparado{}, fmt.Errorf("intervalo_entre_rodadas invalido: %w", err)
+		}
+	}
+
+	if err := injApp.ValidarPlano(p.Falhas); err != nil {
+		return CenarioPreparado{}, fmt.Errorf("falhas: %w", err)
+	}
+	for _, e := range injApp.ExpandirPlano(p.Falhas) {
+		if time.Duration(e.MomentoRelativoSegundos)*time.Second > p.Duracao {
+			fmt.Printf("experimento: aviso: %s %s em %ds ocorre apos o fim da carga (%s)\n",
+				e.Acao, e.NomeDoContainer, e.MomentoRelativoSegundos, p.Duracao)
+		}
+	}
+
+	for i, s := range c.Sondas {
+		sonda := SondaPreparada{Nome: s.Nome, URL: s.URL, Intervalo: 500 * time.Millisecond, Timeout: 2 * time.Second}
+		if sonda.URL == "" {
+			return CenarioPreparado{}, fmt.Errorf("sonda %d sem url", i+1)
+		}
+		if sonda.Nome == "" {
+			sonda.Nome = fmt.Sprintf("sonda%d", i+1)
+		}
+		if s.Intervalo != "" {
+			if sonda.Intervalo, err = time.ParseDuration(s.Intervalo); err != nil || sonda.Intervalo <= 0 {
+				return CenarioPreparado{}, fmt.Errorf("sonda %s: intervalo invalido %q", sonda.Nome, s.Intervalo)
+			}
+		}
+		if s.Timeout != "" {
+			if sonda.Timeout, err = time.ParseDuration(s.Timeout); err != nil || sonda.Timeout <= 0 {
+				return CenarioPreparado{}, fmt.Errorf("sonda %s: timeout invalido %q", sonda.Nome, s.Timeout)
+			}
+		}
+		p.Sondas = append(p.Sondas, sonda)
+	}
+	return p, nil
+}
+
+// Normaliza (maiúsculas) e valida os níveis de consistência no lugar.
+func normalizarConsistencias(niveis []string) error {
+	for i, nivel := range niveis {
+		niveis[i] = strings.ToUpper(strings.TrimSpace(nivel))
+		if _, err := gocql.ParseConsistencyWrapper(niveis[i]); err != nil {
+			return fmt.Errorf("consistencia invalida: %q", nivel)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/estatisticas"
+	injApp "github.com/pdrpinto/tcc-cassandra/internal/falhas/aplicacao"
+	injPorts "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Linha do tempo de uma rodada, agregada por segundo desde o início comum.
+// Implementa a porta de métricas do stress e a porta de eventos da injeção de falhas.
+type LinhaDoTempo struct {
+	mu           sync.Mutex
+	consistencia string
+	inicio       time.Time
+	segundos     map[int]*segundoDaLinha
+	sondaNoAr    map[string]bool // último estado de cada sonda, para anotar transições
+}
+
+type segundoDaLinha struct {
+	ok, erros    int64
+	latencias    *estatisticas.HistogramaDeLatencias
+	sondasTotal  int64
+	sondasOk     int64
+	anotacoes    []string
+	errosPorTipo map[string]int64
+}
+
+func NovaLinhaDoTempo(consistencia string, inicio time.Time) *LinhaDoTempo {
+	return &LinhaDoTempo{consistencia: consistencia, inicio: inicio, segundos: map[int]*segundoDaLinha{}, sondaNoAr: map[string]bool{}}
+}
+
+// Deve ser chamado com o mutex travado.
+func (l *LinhaDoTempo) segundo(instante time.Time) *segundoDaLinha {
+	i := int(instante.Sub(l.inicio) / time.Second)
+	if i < 0 {
+		i = 0
+	}
+	s, ok := l.segundos[i]
+	if !ok {
+		s = &segundoDaLinha{latencias: estatisticas.NovoHistogramaDeLatencias(), errosPorTipo: map[string]int64{}}
+		l.segundos[i] = s
+	}
+	return s
+}
+
+func (l *LinhaDoTempo) RegistrarLatenciaEmMs(_ portas.TipoDeOperacao, _ string, duracao time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.segundo(time.Now())
+	s.ok++
+	s.latencias.Registrar(duracao)
+}
+
+func (l *LinhaDoTempo) RegistrarErro(_ portas.TipoDeOperacao, motivo string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.segundo(time.Now())
+	s.erros++
+	s.errosPorTipo[motivo]++
+}
+
+func (l *LinhaDoTempo) RegistrarSonda(nome string, instante time.Time, disponivel bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.segundo(instante)
+	s.sondasTotal++
+	if disponivel {
+		s.sondasOk++
+	}
+	anterior, conhecido := l.sondaNoAr[nome]
+	l.sondaNoAr[nome] = disponivel
+	switch {
+	case !disponivel && (anterior || !conhecido):
+		s.anotacoes = append(s.anotacoes, "sonda "+nome+" indisponivel")
+	case disponivel && conhecido && !anterior:
+		s.anotacoes = append(s.anotacoes, "sonda "+nome+" recuperada")
+	}
+}
+
+func (l *LinhaDoTempo) RegistrarEvento(evento injPorts.EventoDeEtapa) {
+	origem := "falha"
+	if evento.Reversao {
+		origem = "reversao"
+	}
+	texto := fmt.Sprintf("%s: %s %s", origem, evento.Etapa.Acao, evento.Etapa.NomeDoContainer)
+	if evento.Etapa.NomeDoContainerRemoto != "" {
+		texto += " remoto=" + evento.Etapa.NomeDoContainerRemoto
+		if evento.Etapa.Direcao != "" {
+			texto += " direcao=" + evento.Etapa.Direcao
+		}
+	}
+	if evento.Etapa.NomeDaRede != "" {
+		texto += " rede=" + evento.Etapa.NomeDaRede
+	}
+	if evento.Etapa.Acao == "degradar_rede" && evento.Etapa.Degradacao != nil {
+		texto += " " + injApp.DescreverDegradacao(*evento.Etapa.Degradacao)
+	}
+	if evento.Etapa.Condicao != "" {
+		texto += " ate=" + evento.Etapa.Condicao
+	}
+	if evento.TempoAteRecuperacao > 0 {
+		texto += fmt.Sprintf(" recuperacao=%s", evento.TempoAteRecuperacao.Round(time.Millisecond))
+	}
+	if evento.EstadoDepois != "" {
+		texto += fmt.Sprintf(" estado=[%s] -> [%s]", evento.EstadoAntes, evento.EstadoDepois)
+	}
+	if atraso := evento.MomentoReal.Sub(evento.MomentoPlanejado); atraso >= time.Second {
+		texto += fmt.Sprintf(" atraso=%s", atraso.Round(time.Millisecond))
+	}
+	if evento.Erro != "" {
+		texto += " erro=" + evento.Erro
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	s := l.segundo(evento.MomentoReal)
+	s.anotacoes = append(s.anotacoes, texto)
+	fmt.Printf("[experimento] cons=%s t=%ds %s\n", l.consistencia, int(evento.MomentoReal.Sub(l.inicio)/time.Second), texto)
+}
+
+// Latências de toda a rodada (soma dos histogramas por segundo).
+func (l *LinhaDoTempo) Latencias() *estatisticas.HistogramaDeLatencias {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	total := estatisticas.NovoHistogramaDeLatencias()
+	for _, s := range l.segundos {
+		total.Mesclar(s.latencias)
+	}
+	return total
+}
+
+// Linha do relatório (um segundo de uma rodada).
+type LinhaDoRelatorio struct {
+	Consistencia    string           `json:"consistencia"`
+	Segundo         int              `json:"segundo"`
+	Instante        time.Time        `json:"instante"`
+	OpsOk           int64            `json:"ops_ok"`
+	Erros           int64            `json:"erros"`
+	ErrosPorMotivo  map[string]int64 `json:"erros_por_motivo,omitempty"`
+	P50Ms           float64          `json:"p50_ms"`
+	P99Ms           float64          `json:"p99_ms"`
+	MaxMs           float64          `json:"max_ms"`
+	SondasTotal     int64            `json:"sondas_total"`
+	SondasOk        int64            `json:"sondas_ok"`
+	Disponibilidade *float64         `json:"disponibilidade,omitempty"` // nil quando não houve sonda no segundo
+	Anotacoes       []string         `json:"anotacoes,omitempty"`
+}
+
+// Linhas de todos os segundos da rodada, inclusive os vazios, até "duracao" ou o último registro.
+func (l *LinhaDoTempo) Linhas(duracao time.Duration) []LinhaDoRelatorio {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	ultimo := int(duracao/time.Second) - 1
+	for i := range l.segundos {
+		if i > ultimo {
+			ultimo = i
+		}
+	}
+	var linhas []LinhaDoRelatorio
+	for i := 0; i <= ultimo; i++ {
+		linha := LinhaDoRelatorio{Consistencia: l.consistencia, Segundo: i, Instante: l.inicio.Add(time.Duration(i) * time.Second).UTC()}
+		if s, ok := l.segundos[i]; ok {
+			linha.OpsOk, linha.Erros = s.ok, s.erros
+			if len(s.errosPorTipo) > 0 {
+				linha.ErrosPorMotivo = s.errosPorTipo
+			}
+			linha.P50Ms = emMs(s.latencias.Percentil(50))
+			linha.P99Ms = emMs(s.latencias.Percentil(99))
+			linha.MaxMs = emMs(s.latencias.Maximo())
+			linha.SondasTotal, linha.SondasOk = s.sondasTotal, s.sondasOk
+			if s.sondasTotal > 0 {
+				disp := 100 * float64(s.sondasOk) / float64(s.sondasTotal)
+				linha.Disponibilidade = &disp
+			}
+			linha.Anotacoes = s.anotacoes
+		}
+		linhas = append(linhas, linha)
+	}
+	return linhas
+}
+
+func emMs(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// Relatório único com as linhas do tempo de todas as rodadas.
+type RelatorioDoExperimento struct {
+	Cenario    string             `json:"cenario"`
+	IniciadoEm time.Time          `json:"iniciado_em"`
+	Rodadas    []ResumoDaRodada   `json:"rodadas"`
+	Linhas     []LinhaDoRelatorio `json:"linhas"`
+}
+
+type ResumoDaRodada struct {
+	Consistencia      string   `json:"consistencia"`
+	Escrita           string   `json:"escrita"`
+	Leitura           string   `json:"leitura"`
+	Total             int64    `json:"total"`
+	Ok                int64    `json:"ok"`
+	DuracaoMs         int64    `json:"duracao_ms"`
+	VazaoOpsS         float64  `json:"vazao_ops_s"`
+	P50Ms             float64  `json:"p50_ms"`
+	P95Ms             float64  `json:"p95_ms"`
+	P99Ms             float64  `json:"p99_ms"`
+	PercentualDeErros float64  `json:"percentual_de_erros"`
+	Disponibilidade   *float64 `json:"disponibilidade,omitempty"` // % das sondas disponíveis (nil sem sondas)
+	ErroDoPlano       string   `json:"erro_do_plano,omitempty"`
+	// Só na matriz: % das leituras logo após a escrita que não a viram (nil sem sonda de desatualização)
+	PercentualDesatualizado *float64 `json:"percentual_desatualizado,omitempty"`
+	// Etapas aguardar do plano: tempo até o cluster convergir após a última falha
+	Recuperacoes []injApp.MedicaoDeRecuperacao `json:"recuperacoes,omitempty"`
+}
+
+// Grava em JSON (extensão .json) ou CSV (demais).
+func GravarRelatorio(caminho string, relatorio RelatorioDoExperimento) error {
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+	if strings.EqualFold(filepath.Ext(caminho), ".json") {
+		enc := json.NewEncoder(arquivo)
+		enc.SetIndent("", "  ")
+		return enc.Encode(relatorio)
+	}
+	w := csv.NewWriter(arquivo)
+	w.Write([]string{"consistencia", "segundo", "instante", "ops_ok", "erros", "erros_por_motivo", "p50_ms", "p99_ms", "max_ms",
+		"sondas_total", "sondas_ok", "disponibilidade", "anotacoes"})
+	for _, l := range relatorio.Linhas {
+		disponibilidade := ""
+		if l.Disponibilidade != nil {
+			disponibilidade = strconv.FormatFloat(*l.Disponibilidade, 'f', 2, 64)
+		}
+		w.Write([]string{
+			l.Consistencia,
+			strconv.Itoa(l.Segundo),
+			l.Instante.Format(time.RFC3339),
+			strconv.FormatInt(l.OpsOk, 10),
+			strconv.FormatInt(l.Erros, 10),
+			formatarErrosPorMotivo(l.ErrosPorMotivo),
+			strconv.FormatFloat(l.P50Ms, 'f', 3, 64),
+			strconv.FormatFloat(l.P99Ms, 'f', 3, 64),
+			strconv.FormatFloat(l.MaxMs, 'f', 3, 64),
+			strconv.FormatInt(l.SondasTotal, 10),
+			strconv.FormatInt(l.SondasOk, 10),
+			disponibilidade,
+			strings.Join(l.Anotacoes, "; "),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func formatarErrosPorMotivo(erros map[string]int64) string {
+	motivos := make([]string, 0, len(erros))
+	for m := range erros {
+		motivos = append(motivos, m)
+	}
+	sort.Strings(motivos)
+	partes := make([]string, 0, len(motivos))
+	for _, m := range motivos {
+		partes = append(partes, fmt.Sprintf("%s=%d", m, erros[m]))
+	}
+	return strings.Join(partes, " ")
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gocql/gocql"
+	injAdapt "github.com/pdrpinto/tcc-cassandra/internal/falhas/adaptadores"
+	injApp "github.com/pdrpinto/tcc-cassandra/internal/falhas/aplicacao"
+	injPorts "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/adaptadores"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/aplicacao"
+)
+
+// Executa carga (ServicoDeStress), plano de falhas e sondas HTTP em paralelo, no mesmo relógio,
+// uma rodada por nível de consistência (ou por célula W x R da matriz), e grava uma única linha do tempo por segundo.
+func main() {
+	var (
+		parametroCenario = flag.String("cenario", valorOu("CENARIO", "experimento.yaml"), "Arquivo do cenário (YAML ou JSON)")
+		parametroSaida   = flag.String("saida", valorOu("SAIDA", ""), "Arquivo do relatório (.csv ou .json); sobrescreve o do cenário")
+		parametroHosts   = flag.String("hosts", valorOu("CASSANDRA_HOSTS", ""), "Hosts do Cassandra (sobrescreve o cenário)")
+		parametroImagem  = flag.String("imagem-rede", valorOu("IMAGEM_REDE", ""), "Imagem auxiliar com tc/iptables para falhas de rede (vazio = docker exec)")
+		parametroOrq     = flag.String("orquestrador", valorOu("ORQUESTRADOR", "cli"), "Adaptador de falhas: cli, api (Docker Engine API) ou processos (nós locais)")
+		parametroSocket  = flag.String("docker-socket", valorOu("DOCKER_SOCKET", "/var/run/docker.sock"), "Socket da Docker Engine API")
+		parametroProjeto = flag.String("projeto-compose", valorOu("COMPOSE_PROJECT", ""), "Projeto do compose para alvos servico:<nome>")
+		parametroInvent  = flag.String("inventario", valorOu("INVENTARIO", ""), "Inventário dos nós como processos locais (orquestrador processos)")
+		parametroEventos = flag.String("eventos", valorOu("EVENTOS", ""), "Arquivo JSONL com um evento por etapa de falha (append)")
+		parametroMetric  = flag.String("metricas", valorOu("METRICS_ADDR", ""), "Endereço para expor /metrics das etapas de falha (ex.: :9101)")
+		parametroGrafana = flag.String("grafana-url", valorOu("GRAFANA_URL", ""), "URL do Grafana para anotar as etapas de falha")
+		parametroToken   = flag.String("grafana-token", valorOu("GRAFANA_TOKEN", ""), "Token de service account do Grafana")
+	)
+	flag.Parse()
+
+	cenario, err := CarregarCenario(*parametroCenario)
+	if err != nil {
+		panic(err)
+	}
+	hosts := cenario.Cassandra.Hosts
+	if *parametroHosts != "" {
+		hosts = dividirHosts(*parametroHosts)
+	}
+	if len(hosts) == 0 {
+		hosts = []string{"127.0.0.1:9042"}
+	}
+	keyspace := cenario.Cassandra.Keyspace
+	if keyspace == "" {
+		keyspace = "tcc"
+	}
+	if len(cenario.Cassandra.Nos) == 0 {
+		cenario.Cassandra.Nos = []string{"cassandra1", "cassandra2", "cassandra3"}
+	}
+	saida := *parametroSaida
+	if saida == "" {
+		saida = cenario.Saida
+	}
+	if saida == "" {
+		saida = cenario.Nome + ".csv"
+	}
+
+	// Ctrl-C encerra a rodada corrente; o relatório parcial ainda é gravado
+	ctx, pararSinais := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer pararSinais()
+
+	cluster := gocql.NewCluster(hosts...)
+	cluster.Keyspace = keyspace
+	cluster.ProtoVersion = 4
+	cluster.Timeout = 5 * time.Second
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	cluster.RetryPolicy = &gocql.SimpleRetryPolicy{NumRetries: 1}
+	sessao, err := cluster.CreateSession()
+	if err != nil {
+		panic(err)
+	}
+	defer sessao.Close()
+
+	orquestrador, err := injAdapt.NovoOrquestradorDeFalhas(*parametroOrq, *parametroSocket, *parametroProjeto, *parametroImagem, *parametroInvent)
+	if err != nil {
+		panic(err)
+	}
+	eventos, fecharEventos, err := injAdapt.NovosRegistrosDeEventos(*parametroEventos, *parametroMetric, *parametroGrafana, *parametroToken, []string{"experimento", cenario.Nome})
+	if err != nil {
+		panic(err)
+	}
+	defer fecharEventos()
+	relatorio := RelatorioDoExperimento{Cenario: cenario.Nome, IniciadoEm: time.Now().UTC()}
+	rotulos := make([]string, len(cenario.Rodadas))
+	for i, rodada := range cenario.Rodadas {
+		rotulos[i] = rodada.Rotulo()
+	}
+	fmt.Printf("experimento %s: duracao=%s consistencias=%s falhas=%d sondas=%d\n",
+		cenario.Nome, cenario.Duracao, strings.Join(rotulos, ","), len(cenario.Falhas), len(cenario.Sondas))
+
+	for i, rodada := range cenario.Rodadas {
+		if i > 0 && cenario.IntervaloEntreRodadas > 0 {
+			fmt.Printf("experimento: aguardando %s antes da proxima rodada\n", cenario.IntervaloEntreRodadas)
+			select {
+			case <-ctx.Done():
+			case <-time.After(cenario.IntervaloEntreRodadas):
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		linha, resumo := executarRodada(ctx, cenario, rodada, sessao, orquestrador, eventos)
+		relatorio.Rodadas = append(relatorio.Rodadas, resumo)
+		relatorio.Linhas = append(relatorio.Linhas, linha.Linhas(cenario.Duracao)...)
+		disponibilidade := "sem sondas"
+		if resumo.Disponibilidade != nil {
+			disponibilidade = formatarPercentualOpcional(resumo.Disponibilidade) + "%"
+		}
+		fmt.Printf("rodada concluida: cons=%s total=%d ok=%d duracao_ms=%d disponibilidade=%s\n",
+			resumo.Consistencia, resumo.Total, resumo.Ok, resumo.DuracaoMs, disponibilidade)
+		for _, r := range resumo.Recuperacoes {
+			fmt.Printf("  recuperacao %s %s: recuperado=%t tempo=%s tentativas=%d\n",
+				r.Condicao, r.NomeDoContainer, r.Recuperado, r.TempoAteRecuperacao.Round(time.Millisecond), r.Tentativas)
+		}
+	}
+
+	if err := GravarRelatorio(saida, relatorio); err != nil {
+		panic(err)
+	}
+	fmt.Printf("experimento %s: relatorio gravado em %s (%d linhas)\n", cenario.Nome, saida, len(relatorio.Linhas))
+
+	if cenario.Matriz != nil {
+		if err := gravarMatrizDoExperimento(cenario, relatorio, sessao, keyspace); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// Uma rodada: carga, falhas e sondas partem do mesmo instante; a rodada termina com a carga.
+func executarRodada(ctx context.Context, cenario CenarioPreparado, rodada RodadaDoCenario, sessao *gocql.Session,
+	orquestrador injPorts.PortaDeOrquestracaoDeFalhas, eventos injAdapt.RegistrosDeEventos) (*LinhaDoTempo, ResumoDaRodada) {
+	consistEscrita, consistLeitura := converteConsistencia(rodada.Escrita), converteConsistencia(rodada.Leitura)
+	inicio := time.Now()
+	linha := NovaLinhaDoTempo(rodada.Rotulo(), inicio)
+	servico := aplicacao.ServicoDeStress{
+		Persistencia: adaptadores.NovoRepositorioDeEscritaCassandra(sessao, consistEscrita, 5*time.Second),
+		Consultas:    adaptadores.NovoRepositorioDeLeituraCassandra(sessao, consistLeitura, consistLeitura, 5*time.Second),
+		Metricas:     linha,
+	}
+	// A linha do tempo da rodada recebe os eventos junto com os registros globais (arquivo, métricas, Grafana)
+	registros := append(injAdapt.RegistrosDeEventos{linha}, eventos...)
+	injecao := &injApp.ServicoDeInjecaoDeFalhas{Orquestrador: orquestrador, Eventos: registros, ReverterAoConcluir: true,
+		Verificador: injAdapt.NovoVerificadorDeClusterCassandra(sessao, orquestrador), NosDoCluster: cenario.Cassandra.Nos}
+	cfg := aplicacao.ConfiguracaoDoTesteDeStress{
+		NivelDeConsistenciaTexto:          rodada.Escrita,
+		NivelDeConsistenciaUltimasTexto:   rodada.Leitura,
+		NivelDeConsistenciaIntervaloTexto: rodada.Leitura,
+		DuracaoTotalDoTeste:               cenario.Duracao,
+		TaxaDeRequisicoesPorSegundo:       cenario.Carga.Rps,
+		GrauDeConcorrencia:                cenario.Carga.Concorrencia,
+		QuantidadeDeSensoresDistintos:     cenario.Carga.Sensores,
+		MisturaDeOperacoes:                cenario.Mistura,
+		LimiteDeLeiturasUltimas:           cenario.Carga.LimiteUltimas,
+		JanelaDeLeituraPorIntervalo:       cenario.JanelaIntervalo,
+		SementeAleatoria:                  cenario.Carga.Semente,
+		PerfilDeCarga:                     cenario.Perfil,
+	}
+
+	ctxRodada, encerrarRodada := context.WithCancel(ctx)
+	defer encerrarRodada()
+	grupo := &sync.WaitGroup{}
+
+	var erroDoPlano error
+	if len(cenario.Falhas) > 0 {
+		grupo.Add(1)
+		go func() {
+			defer grupo.Done()
+			// O fim da carga cancela o plano; falhas pendentes são desfeitas antes do fim da rodada
+			if err := injecao.ExecutarPlano(ctxRodada, cenario.Falhas); err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				erroDoPlano = err
+				fmt.Printf("experimento: erro no plano de falhas: %v\n", err)
+			}
+		}()
+	}
+
+	var sondasTotal, sondasOk int64
+	var muSondas sync.Mutex
+	for _, sonda := range cenario.Sondas {
+		grupo.Add(1)
+		go func(sonda SondaPreparada) {
+			defer grupo.Done()
+			total, ok := executarSonda(ctxRodada, sonda, linha)
+			muSondas.Lock()
+			sondasTotal += total
+			sondasOk += ok
+			muSondas.Unlock()
+		}(sonda)
+	}
+
+	var desatualizacao ResultadoDaDesatualizacao
+	if cenario.IntervaloDeDesatualizacao > 0 {
+		grupo.Add(1)
+		go func() {
+			defer grupo.Done()
+			desatualizacao = executarSondaDeDesatualizacao(ctxRodada, sessao, consistEscrita, consistLeitura, cenario.IntervaloDeDesatualizacao,
+				"desatualizacao-"+strings.ToLower(strings.ReplaceAll(rodada.Rotulo(), "/", "-")))
+		}()
+	}
+
+	res := servico.Executar(ctxRodada, cfg)
+	encerrarRodada() // falhas pendentes e sondas terminam junto com a carga
+	grupo.Wait()
+
+	latencias := linha.Latencias()
+	resumo := ResumoDaRodada{
+		Consistencia: rodada.Rotulo(), Escrita: rodada.Escrita, Leitura: rodada.Leitura,
+		Total: res.Total, Ok: res.Ok, DuracaoMs: res.Duracao.Milliseconds(),
+		P50Ms: emMs(latencias.Percentil(50)), P95Ms: emMs(latencias.Percentil(95)), P99Ms: emMs(latencias.Percentil(99)),
+	}
+	if segundos := res.Duracao.Seconds(); segundos > 0 {
+		resumo.VazaoOpsS = float64(res.Ok) / segundos
+	}
+	if res.Total > 0 {
+		resumo.PercentualDeErros = 100 * float64(res.Total-res.Ok) / float64(res.Total)
+	}
+	if desatualizacao.Verificadas > 0 {
+		percentual := 100 * float64(desatualizacao.Desatualizadas) / float64(desatualizacao.Verificadas)
+		resumo.PercentualDesatualizado = &percentual
+	}
+	if sondasTotal > 0 {
+		disponibilidade := 100 * float64(sondasOk) / float64(sondasTotal)
+		resumo.Disponibilidade = &disponibilidade
+	}
+	if erroDoPlano != nil {
+		resumo.ErroDoPlano = erroDoPlano.Error()
+	}
+	resumo.Recuperacoes = injecao.Recuperacoes()
+	return linha, resumo
+}
+
+// Sonda HTTP periódica até o fim da rodada; 5xx e erros de rede contam como indisponível.
+func executarSonda(ctx context.Context, sonda SondaPreparada, linha *LinhaDoTempo) (total, sucesso int64) {
+	cliente := &http.Client{Timeout: sonda.Timeout}
+	tique := time.NewTicker(sonda.Intervalo)
+	defer tique.Stop()
+	for {
+		instante := time.Now()
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, sonda.URL, nil)
+		if err != nil {
+			return total, sucesso
+		}
+		resp, err := cliente.Do(req)
+		disponivel := err == nil && resp.StatusCode >= 200 && resp.StatusCode < 500
+		if resp != nil {
+			resp.Body.Close()
+		}
+		if ctx.Err() != nil {
+			return total, sucesso // requisição interrompida pelo fim da rodada não conta
+		}
+		total++
+		if disponivel {
+			sucesso++
+		}
+		linha.RegistrarSonda(sonda.Nome, instante, disponivel)
+		select {
+		case <-ctx.Done():
+			return total, sucesso
+		case <-tique.C:
+		}
+	}
+}
+
+func converteConsistencia(texto string) gocql.Consistency {
+	consist, err := gocql.ParseConsistencyWrapper(strings.ToUpper(strings.TrimSpace(texto)))
+	if err != nil {
+		return gocql.Quorum
+	}
+	return consist
+}
+
+func dividirHosts(lista string) []string {
+	var hosts []string
+	for _, h := range strings.Split(lista, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+func valorOu(chave, padrao string) string {
+	if v := os.Getenv(chave); v != "" {
+		return v
+	}
+	return padrao
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/adaptadores"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Sonda de desatualização: escreve um marcador na consistência de escrita e lê logo em seguida
+// na de leitura; a leitura é desatualizada quando a última leitura do sensor não traz o marcador.
+type ResultadoDaDesatualizacao struct {
+	Verificadas    int64 // escrita confirmada e leitura sem erro
+	Desatualizadas int64
+	Erros          int64
+}
+
+func executarSondaDeDesatualizacao(ctx context.Context, sessao *gocql.Session, escrita, leitura gocql.Consistency,
+	intervalo time.Duration, sensor string) ResultadoDaDesatualizacao {
+	repositorioDeEscrita := adaptadores.NovoRepositorioDeEscritaCassandra(sessao, escrita, 5*time.Second)
+	repositorioDeLeitura := adaptadores.NovoRepositorioDeLeituraCassandra(sessao, leitura, leitura, 5*time.Second)
+	tique := time.NewTicker(intervalo)
+	defer tique.Stop()
+	var resultado ResultadoDaDesatualizacao
+	for i := 0; ; i++ {
+		agora := time.Now().UTC()
+		dia := time.Date(agora.Year(), agora.Month(), agora.Day(), 0, 0, 0, 0, time.UTC)
+		marcador := strconv.Itoa(i)
+		err := repositorioDeEscrita.GravarLeitura(ctx, portas.LeituraDeSensor{
+			IdentificadorDoSensor: sensor, DiaDeAgrupamento: dia, InstanteDoEvento: agora, ValorMedido: float64(i),
+			AtributosAdicionais: map[string]string{"src": "go-matriz", "marcador": marcador},
+		})
+		if err == nil {
+			var lidas []portas.LeituraDeSensor
+			if lidas, err = repositorioDeLeitura.ConsultarUltimasLeituras(ctx, sensor, dia, 1); err == nil {
+				resultado.Verificadas++
+				if len(lidas) == 0 || lidas[0].AtributosAdicionais["marcador"] != marcador {
+					resultado.Desatualizadas++
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return resultado // operação interrompida pelo fim da rodada não conta
+		}
+		if err != nil {
+			resultado.Erros++
+		}
+		select {
+		case <-ctx.Done():
+			return resultado
+		case <-tique.C:
+		}
+	}
+}
+
+// Célula da matriz W x R com as métricas da rodada.
+type CelulaDaMatriz struct {
+	Escrita                 string   `json:"escrita"`
+	Leitura                 string   `json:"leitura"`
+	FatorDeReplicacao       int      `json:"rf"`
+	ReplicasDeEscrita       int      `json:"replicas_escrita"`
+	ReplicasDeLeitura       int      `json:"replicas_leitura"`
+	Sobreposicao            bool     `json:"r_mais_w_maior_que_rf"` // R+W>RF: toda leitura encontra a última escrita confirmada
+	VazaoOpsS               float64  `json:"vazao_ops_s"`
+	P50Ms                   float64  `json:"p50_ms"`
+	P95Ms                   float64  `json:"p95_ms"`
+	P99Ms                   float64  `json:"p99_ms"`
+	PercentualDeErros       float64  `json:"percentual_de_erros"`
+	PercentualDesatualizado *float64 `json:"percentual_desatualizado,omitempty"`
+	Disponibilidade         *float64 `json:"disponibilidade,omitempty"`
+	ErroDoPlano             string   `json:"erro_do_plano,omitempty"`
+}
+
+// Réplicas que precisam responder no nível de consistência. Os níveis LOCAL_* e EACH_QUORUM
+// usam o RF total, o que vale para clusters com um único datacenter (como o do docker-compose).
+func replicasExigidas(nivel string, rf int) int {
+	switch nivel {
+	case "ANY":
+		return 0 // a escrita pode ficar só em hint no coordenador
+	case "ONE", "LOCAL_ONE":
+		return 1
+	case "TWO":
+		return 2
+	case "THREE":
+		return 3
+	case "QUORUM", "LOCAL_QUORUM", "EACH_QUORUM":
+		return rf/2 + 1
+	default: // ALL
+		return rf
+	}
+}
+
+// RF do keyspace: replication_factor (SimpleStrategy) ou a soma dos datacenters (NetworkTopologyStrategy).
+func lerFatorDeReplicacao(sessao *gocql.Session, keyspace string) (int, error) {
+	var replicacao map[string]string
+	if err := sessao.Query(`SELECT replication FROM system_schema.keyspaces WHERE keyspace_name = ?`, keyspace).Scan(&replicacao); err != nil {
+		return 0, fmt.Errorf("replicacao do keyspace %s: %w", keyspace, err)
+	}
+	if fator, ok := replicacao["replication_factor"]; ok {
+		return strconv.Atoi(fator)
+	}
+	var total int
+	for chave, valor := range replicacao {
+		if chave == "class" {
+			continue
+		}
+		fator, err := strconv.Atoi(valor)
+		if err != nil {
+			return 0, fmt.Errorf("replicacao do keyspace %s: %s=%q", keyspace, chave, valor)
+		}
+		total += fator
+	}
+	return total, nil
+}
+
+func gravarMatrizDoExperimento(cenario CenarioPreparado, relatorio RelatorioDoExperimento, sessao *gocql.Session, keyspace string) error {
+	rf := cenario.Matriz.FatorDeReplicacao
+	if rf == 0 {
+		var err error
+		if rf, err = lerFatorDeReplicacao(sessao, keyspace); err != nil {
+			return err
+		}
+	}
+	var celulas []CelulaDaMatriz
+	for _, r := range relatorio.Rodadas {
+		celula := CelulaDaMatriz{
+			Escrita: r.Escrita, Leitura: r.Leitura, FatorDeReplicacao: rf,
+			ReplicasDeEscrita: replicasExigidas(r.Escrita, rf), ReplicasDeLeitura: replicasExigidas(r.Leitura, rf),
+			VazaoOpsS: r.VazaoOpsS, P50Ms: r.P50Ms, P95Ms: r.P95Ms, P99Ms: r.P99Ms, PercentualDeErros: r.PercentualDeErros,
+			PercentualDesatualizado: r.PercentualDesatualizado, Disponibilidade: r.Disponibilidade, ErroDoPlano: r.ErroDoPlano,
+		}
+		celula.Sobreposicao = celula.ReplicasDeEscrita+celula.ReplicasDeLeitura > rf
+		celulas = append(celulas, celula)
+	}
+
+	saida := cenario.Matriz.Saida
+	if saida == "" {
+		saida = cenario.Nome + "-matriz.csv"
+	}
+	if err := GravarMatriz(saida, celulas); err != nil {
+		return err
+	}
+	resumo := strings.TrimSuffix(saida, filepath.Ext(saida)) + ".md"
+	if err := os.WriteFile(resumo, []byte(ResumoDaMatrizEmMarkdown(cenario, celulas)), 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("experimento %s: matriz gravada em %s e %s (%d celulas, rf=%d)\n", cenario.Nome, saida, resumo, len(celulas), rf)
+	return nil
+}
+
+// Grava em JSON (extensão .json) ou CSV (demais).
+func GravarMatriz(caminho string, celulas []CelulaDaMatriz) error {
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+	if strings.EqualFold(filepath.Ext(caminho), ".json") {
+		enc := json.NewEncoder(arquivo)
+		enc.SetIndent("", "  ")
+		return enc.Encode(celulas)
+	}
+	w := csv.NewWriter(arquivo)
+	w.Write([]string{"escrita", "leitura", "rf", "replicas_escrita", "replicas_leitura", "r_mais_w_maior_que_rf", "vazao_ops_s",
+		"p50_ms", "p95_ms", "p99_ms", "percentual_de_erros", "percentual_desatualizado", "disponibilidade", "erro_do_plano"})
+	for _, c := range celulas {
+		w.Write([]string{c.Escrita, c.Leitura, strconv.Itoa(c.FatorDeReplicacao), strconv.Itoa(c.ReplicasDeEscrita),
+			strconv.Itoa(c.ReplicasDeLeitura), strconv.FormatBool(c.Sobreposicao), strconv.FormatFloat(c.VazaoOpsS, 'f', 1, 64),
+			strconv.FormatFloat(c.P50Ms, 'f', 2, 64), strconv.FormatFloat(c.P95Ms, 'f', 2, 64), strconv.FormatFloat(c.P99Ms, 'f', 2, 64),
+			strconv.FormatFloat(c.PercentualDeErros, 'f', 2, 64), formatarPercentualOpcional(c.PercentualDesatualizado),
+			formatarPercentualOpcional(c.Disponibilidade), c.ErroDoPlano})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func ResumoDaMatrizEmMarkdown(cenario CenarioPreparado, celulas []CelulaDaMatriz) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Matriz de consistência: %s\n\n", cenario.Nome)
+	fmt.Fprintf(&b, "Carga de %s por célula", cenario.Duracao)
+	if len(cenario.Falhas) > 0 {
+		fmt.Fprintf(&b, ", sob o plano de falhas do cenário (%d etapas)", len(cenario.Falhas))
+	}
+	b.WriteString(".\n\n")
+	b.WriteString("| W | R | RF | R+W>RF | vazão (ops/s) | p50 (ms) | p95 (ms) | p99 (ms) | erros (%) | desatualizadas (%) | disponibilidade (%) |\n")
+	b.WriteString("|---|---|---:|:---:|---:|---:|---:|---:|---:|---:|---:|\n")
+	for _, c := range celulas {
+		sobreposicao := "não"
+		if c.Sobreposicao {
+			sobreposicao = "sim"
+		}
+		desatualizadas, disponibilidade := formatarPercentualOpcional(c.PercentualDesatualizado), formatarPercentualOpcional(c.Disponibilidade)
+		if desatualizadas == "" {
+			desatualizadas = "-"
+		}
+		if disponibilidade == "" {
+			disponibilidade = "-"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %s (%d+%d) | %.1f | %.2f | %.2f | %.2f | %.2f | %s | %s |\n",
+			c.Escrita, c.Leitura, c.FatorDeReplicacao, sobreposicao, c.ReplicasDeEscrita, c.ReplicasDeLeitura,
+			c.VazaoOpsS, c.P50Ms, c.P95Ms, c.P99Ms, c.PercentualDeErros, desatualizadas, disponibilidade)
+	}
+	return b.String()
+}
+
+func formatarPercentualOpcional(p *float64) string {
+	if p == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*p, 'f', 2, 64)
+}
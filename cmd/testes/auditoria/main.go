@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pdrpinto/tcc-cassandra/internal/auditoria"
+)
+
+// Auditoria de escritas perdidas: relê as chaves (sensor_id, ts) registradas por go-stress (-audit-log)
+// e carga (-auditoria) depois que o cluster se recupera e aponta linhas ausentes e extras.
+func main() {
+	var (
+		hosts         = flag.String("hosts", valorOu("CASSANDRA_HOSTS", "localhost:9042"), "Hosts do Cassandra separados por vírgula")
+		keyspace      = flag.String("keyspace", valorOu("CASSANDRA_KEYSPACE", "tcc"), "Keyspace")
+		registros     = flag.String("registro", "escritas.jsonl", "Arquivos JSONL de escritas separados por vírgula")
+		consistencias = flag.String("consistencias", "ALL,QUORUM", "Níveis de consistência das releituras")
+		concorrencia  = flag.Int("conc", 8, "Partições consultadas em paralelo")
+		timeout       = flag.Duration("timeout", 10*time.Second, "Timeout por partição")
+		exemplos      = flag.Int("exemplos", 10, "Linhas ausentes/extras listadas por nível (0 = todas)")
+		saida         = flag.String("saida", "", "Arquivo JSON com o relatório (opcional)")
+	)
+	flag.Parse()
+
+	escritas, err := auditoria.CarregarEscritas(dividirLista(*registros)...)
+	if err != nil {
+		fmt.Printf("auditoria: %v\n", err)
+		os.Exit(1)
+	}
+	niveis := dividirLista(strings.ToUpper(*consistencias))
+	if len(escritas) == 0 || len(niveis) == 0 {
+		fmt.Println("auditoria: nada a auditar (registro vazio ou sem consistencias)")
+		os.Exit(1)
+	}
+
+	cluster := gocql.NewCluster(dividirLista(*hosts)...)
+	cluster.Keyspace = *keyspace
+	cluster.ProtoVersion = 4
+	cluster.Timeout = *timeout
+	cluster.PoolConfig.HostSelectionPolicy = gocql.TokenAwareHostPolicy(gocql.RoundRobinHostPolicy())
+	sessao, err := cluster.CreateSession()
+	if err != nil {
+		fmt.Printf("auditoria: %v\n", err)
+		os.Exit(1)
+	}
+	defer sessao.Close()
+
+	fmt.Printf("auditoria: %d escritas de %s; releitura em %s\n", len(escritas), *registros, strings.Join(niveis, ","))
+	relatorio := auditoria.Auditar(context.Background(),
+		auditoria.ConsultaDeParticaoCassandra{Sessao: sessao, TempoLimitePorConsulta: *timeout},
+		escritas, auditoria.ParametrosDaAuditoria{Consistencias: niveis, Concorrencia: *concorrencia, Exemplos: *exemplos})
+	fmt.Print(auditoria.FormatarRelatorio(relatorio))
+
+	if *saida != "" {
+		conteudo, _ := json.MarshalIndent(relatorio, "", "  ")
+		if err := os.WriteFile(*saida, conteudo, 0o644); err != nil {
+			fmt.Printf("auditoria: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	for _, r := range relatorio.PorConsistencia {
+		if r.Ausentes > 0 {
+			os.Exit(2)
+		}
+	}
+}
+
+func dividirLista(lista string) []string {
+	var saida []string
+	for _, p := range strings.Split(lista, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			saida = append(saida, p)
+		}
+	}
+	return saida
+}
+
+func valorOu(chave, padrao string) string {
+	if v := strings.TrimSpace(os.Getenv(chave)); v != "" {
+		return v
+	}
+	return padrao
+}
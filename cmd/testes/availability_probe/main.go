@@ -1,41 +1,498 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/estatisticas"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type writeReq struct {
+	IdentificadorDoSensor   string            `json:"identificador_do_sensor"`
+	InstanteDoEventoISO8601 string            `json:"instante_do_evento_iso8601"`
+	ValorMedido             float64           `json:"valor_medido"`
+	AtributosAdicionais     map[string]string `json:"atributos_adicionais,omitempty"`
+}
+
+type writeResp struct {
+	Sucesso bool   `json:"sucesso"`
+	Erro    string `json:"erro,omitempty"`
+}
+
+// Leitura como serializada pelo ingestor (sensors.LeituraDeSensor não tem tags json).
+type leituraResp struct {
+	Itens []struct {
+		AtributosAdicionais map[string]string
+	} `json:"itens"`
+}
+
+// Atributo gravado em cada escrita do canário para reconhecê-la na leitura.
+const atributoDoMarcador = "marcador"
+
+// Operações avaliadas: a escrita, a leitura da escrita e o canário completo (escrita confirmada e lida).
+const (
+	operacaoEscrita = "escrita"
+	operacaoLeitura = "leitura"
+	operacaoCanario = "canario"
+)
+
+var operacoes = []string{operacaoEscrita, operacaoLeitura, operacaoCanario}
+
+// Estados de um intervalo da linha do tempo.
+const (
+	estadoUp       = "up"
+	estadoDown     = "down"
+	estadoSemDados = "sem_dados" // nenhuma tentativa (ex.: leitura quando todas as escritas falharam)
 )
 
+type contagemDoIntervalo struct {
+	ok, falha int
+}
+
+// Intervalo da linha do tempo: "up" quando todas as tentativas da operação tiveram sucesso.
+type IntervaloDaLinhaDoTempo struct {
+	Inicio  time.Time         `json:"inicio"`
+	Estados map[string]string `json:"estados"`
+}
+
+type ResumoDaOperacao struct {
+	Operacao             string  `json:"operacao"`
+	Consistencia         string  `json:"consistencia"`
+	Tentativas           int64   `json:"tentativas"`
+	Ok                   int64   `json:"ok"`
+	Desatualizadas       int64   `json:"desatualizadas,omitempty"` // leitura ok sem a escrita do canário
+	SucessoPorOperacao   float64 `json:"sucesso_por_operacao_pct"`
+	IntervalosComDados   int     `json:"intervalos_com_dados"`
+	IntervalosUp         int     `json:"intervalos_up"`
+	Disponibilidade      float64 `json:"disponibilidade_pct"` // intervalos up / intervalos com dados
+	Quedas               int     `json:"quedas"`
+	MaiorQuedaS          float64 `json:"maior_queda_s"`
+	MTTRS                float64 `json:"mttr_s"` // duração média das quedas encerradas
+	EmQuedaNoFim         bool    `json:"em_queda_no_fim"`
+	AtendeSLO            bool    `json:"atende_slo"`
+	OrcamentoConsumidoPc float64 `json:"orcamento_de_erro_consumido_pct"`
+	LatenciaP50Ms        float64 `json:"latencia_p50_ms"`
+	LatenciaP99Ms        float64 `json:"latencia_p99_ms"`
+	LatenciaMaxMs        float64 `json:"latencia_max_ms"`
+}
+
+type RelatorioDaSonda struct {
+	W            string                    `json:"w"`
+	R            string                    `json:"r"`
+	Inicio       time.Time                 `json:"inicio"`
+	Duracao      time.Duration             `json:"duracao_ns"`
+	Intervalo    time.Duration             `json:"intervalo_entre_canarios_ns"`
+	Janela       time.Duration             `json:"janela_ns"`
+	SLO          float64                   `json:"slo_pct"`
+	Operacoes    []ResumoDaOperacao        `json:"operacoes"`
+	LinhaDoTempo []IntervaloDaLinhaDoTempo `json:"linha_do_tempo"`
+}
+
+// Resultados acumulados por operação e por intervalo; seguro para uso concorrente.
+type acumulador struct {
+	mu             sync.Mutex
+	inicio         time.Time
+	janela         time.Duration
+	intervalos     map[string]map[int]*contagemDoIntervalo
+	tentativas     map[string]int64
+	oks            map[string]int64
+	desatualizadas int64
+	latencias      map[string]*estatisticas.HistogramaDeLatencias
+	metricas       *metricasDaSonda
+}
+
+func novoAcumulador(inicio time.Time, janela time.Duration, metricas *metricasDaSonda) *acumulador {
+	a := &acumulador{inicio: inicio, janela: janela, intervalos: map[string]map[int]*contagemDoIntervalo{},
+		tentativas: map[string]int64{}, oks: map[string]int64{}, latencias: map[string]*estatisticas.HistogramaDeLatencias{}, metricas: metricas}
+	for _, op := range operacoes {
+		a.intervalos[op] = map[int]*contagemDoIntervalo{}
+		a.latencias[op] = estatisticas.NovoHistogramaDeLatencias()
+	}
+	return a
+}
+
+// O resultado conta no intervalo em que o canário começou.
+func (a *acumulador) registrar(operacao string, inicioDoCanario time.Time, latencia time.Duration, resultado string) {
+	ok := resultado == "ok"
+	a.mu.Lock()
+	indice := int(inicioDoCanario.Sub(a.inicio) / a.janela)
+	contagem, existe := a.intervalos[operacao][indice]
+	if !existe {
+		contagem = &contagemDoIntervalo{}
+		a.intervalos[operacao][indice] = contagem
+	}
+	a.tentativas[operacao]++
+	if ok {
+		contagem.ok++
+		a.oks[operacao]++
+	} else {
+		contagem.falha++
+	}
+	if resultado == "desatualizada" {
+		a.desatualizadas++
+	}
+	a.mu.Unlock()
+	if latencia > 0 {
+		a.latencias[operacao].Registrar(latencia)
+	}
+	if a.metricas != nil {
+		a.metricas.registrar(operacao, latencia, resultado)
+	}
+}
+
 func main() {
 	var (
-		url      = flag.String("url", "http://localhost:8080/healthz", "URL de health/read a testar")
-		interval = flag.Duration("interval", 500*time.Millisecond, "Intervalo entre probes")
-		duracao  = flag.Duration("duracao", 30*time.Second, "Duração total")
-		timeout  = flag.Duration("timeout", 2*time.Second, "Timeout HTTP")
+		baseIngest = flag.String("ingest", "http://localhost:8080/ingest", "URL /ingest")
+		baseRead   = flag.String("read", "http://localhost:8080/leituras/ultimas", "URL /leituras/ultimas")
+		consistW   = flag.String("w", "QUORUM", "Consistência de escrita do canário")
+		consistR   = flag.String("r", "QUORUM", "Consistência de leitura do canário (com W+R <= RF, leituras sem a escrita contam como falha do canário)")
+		intervalo  = flag.Duration("interval", 500*time.Millisecond, "Intervalo entre canários (iniciados mesmo com o anterior pendente)")
+		janela     = flag.Duration("janela", time.Second, "Largura de cada intervalo da linha do tempo up/down")
+		duracao    = flag.Duration("duracao", 30*time.Second, "Duração total (0 = até Ctrl+C)")
+		timeout    = flag.Duration("timeout", 2*time.Second, "Timeout HTTP por operação")
+		slo        = flag.Float64("slo", 99.9, "Alvo de disponibilidade em % dos intervalos")
+		prefixo    = flag.String("sensor", "sensor-probe", "Prefixo dos sensores do canário")
+		sensores   = flag.Int("sensores", 16, "Sensores do canário (rodízio, para canários concorrentes não disputarem a mesma partição)")
+		metricas   = flag.String("metricas", "", "Endereço para expor /metrics ao Prometheus (ex.: :9102)")
+		saida      = flag.String("saida", "", "Arquivo do relatório: .json (resumo e linha do tempo) ou .csv (linha do tempo)")
 	)
 	flag.Parse()
 
+	if *janela < *intervalo {
+		fmt.Println("availability_probe: -janela deve ser >= -interval (cada intervalo precisa de ao menos um canário)")
+		os.Exit(1)
+	}
+	w, r := strings.ToUpper(*consistW), strings.ToUpper(*consistR)
+	consistencias := map[string]string{operacaoEscrita: w, operacaoLeitura: r, operacaoCanario: w + "/" + r}
+
+	var m *metricasDaSonda
+	if *metricas != "" {
+		// Porta ocupada encerra antes do primeiro canário, em vez de rodar sem /metrics
+		ouvinte, err := net.Listen("tcp", *metricas)
+		if err != nil {
+			fmt.Printf("availability_probe: metricas: %v\n", err)
+			os.Exit(1)
+		}
+		m = novasMetricasDaSonda(consistencias, *slo)
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", promhttp.Handler())
+		go http.Serve(ouvinte, mux)
+		fmt.Printf("availability_probe: metricas em %s/metrics\n", *metricas)
+	}
+
+	ctx, cancelar := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancelar()
+	if *duracao > 0 {
+		ctx, cancelar = context.WithTimeout(ctx, *duracao)
+		defer cancelar()
+	}
+
 	client := &http.Client{Timeout: *timeout}
-	deadline := time.Now().Add(*duracao)
+	execucao := strconv.FormatInt(time.Now().UnixNano(), 36)
+	inicio := time.Now()
+	acumulado := novoAcumulador(inicio, *janela, m)
+	fmt.Printf("availability_probe: w=%s r=%s interval=%s janela=%s slo=%.3f%%\n", w, r, *intervalo, *janela, *slo)
+
+	var sequencia atomic.Int64
+	grupo := &sync.WaitGroup{}
+	executarCanario := func() {
+		defer grupo.Done()
+		n := sequencia.Add(1)
+		escrita := writeReq{
+			IdentificadorDoSensor:   fmt.Sprintf("%s-%d", *prefixo, n%int64(*sensores)),
+			InstanteDoEventoISO8601: time.Now().UTC().Format(time.RFC3339Nano),
+			ValorMedido:             float64(n),
+			AtributosAdicionais:     map[string]string{atributoDoMarcador: fmt.Sprintf("%s-%d", execucao, n)},
+		}
+		inicioDoCanario := time.Now()
+		if err := escrever(client, *baseIngest, w, escrita); err != nil {
+			acumulado.registrar(operacaoEscrita, inicioDoCanario, 0, "erro")
+			acumulado.registrar(operacaoCanario, inicioDoCanario, 0, "erro")
+			return
+		}
+		acumulado.registrar(operacaoEscrita, inicioDoCanario, time.Since(inicioDoCanario), "ok")
 
-	var total, sucesso int
+		inicioDaLeitura := time.Now()
+		vista, err := lerEConferir(client, *baseRead, r, escrita)
+		latenciaDaLeitura := time.Since(inicioDaLeitura)
+		switch {
+		case err != nil:
+			acumulado.registrar(operacaoLeitura, inicioDoCanario, 0, "erro")
+			acumulado.registrar(operacaoCanario, inicioDoCanario, 0, "erro")
+		case !vista: // a rota respondeu, mas sem a escrita confirmada: caminho de dados indisponível para o canário
+			acumulado.registrar(operacaoLeitura, inicioDoCanario, latenciaDaLeitura, "ok")
+			acumulado.registrar(operacaoCanario, inicioDoCanario, 0, "desatualizada")
+		default:
+			acumulado.registrar(operacaoLeitura, inicioDoCanario, latenciaDaLeitura, "ok")
+			acumulado.registrar(operacaoCanario, inicioDoCanario, time.Since(inicioDoCanario), "ok")
+		}
+	}
+
+	tique := time.NewTicker(*intervalo)
+	defer tique.Stop()
+	grupo.Add(1)
+	go executarCanario()
+	for laco := true; laco; {
+		select {
+		case <-ctx.Done():
+			laco = false
+		case <-tique.C:
+			grupo.Add(1)
+			go executarCanario()
+		}
+	}
+	grupo.Wait()
 
-	for time.Now().Before(deadline) {
-		total++
-		resp, err := client.Get(*url)
-		if err == nil && resp.StatusCode >= 200 && resp.StatusCode < 500 { // 5xx conta como indisponível
-			sucesso++
+	relatorio := montarRelatorio(acumulado, w, r, time.Since(inicio), *intervalo, *slo, consistencias)
+	imprimirRelatorio(relatorio)
+	if *saida != "" {
+		if err := gravarRelatorio(*saida, relatorio); err != nil {
+			fmt.Printf("availability_probe: %v\n", err)
 		}
-		if resp != nil {
-			resp.Body.Close()
+	}
+	for _, op := range relatorio.Operacoes {
+		if op.Operacao == operacaoCanario && !op.AtendeSLO {
+			os.Exit(2)
 		}
-		time.Sleep(*interval)
 	}
+}
+
+func montarRelatorio(a *acumulador, w, r string, duracao, intervalo time.Duration, slo float64, consistencias map[string]string) RelatorioDaSonda {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	relatorio := RelatorioDaSonda{W: w, R: r, Inicio: a.inicio, Duracao: duracao, Intervalo: intervalo, Janela: a.janela, SLO: slo}
+	quantidade := int((duracao + a.janela - 1) / a.janela)
+	for i := 0; i < quantidade; i++ {
+		relatorio.LinhaDoTempo = append(relatorio.LinhaDoTempo, IntervaloDaLinhaDoTempo{
+			Inicio: a.inicio.Add(time.Duration(i) * a.janela).UTC(), Estados: map[string]string{}})
+	}
+	for _, op := range operacoes {
+		resumo := ResumoDaOperacao{Operacao: op, Consistencia: consistencias[op], Tentativas: a.tentativas[op], Ok: a.oks[op]}
+		if op == operacaoCanario {
+			resumo.Desatualizadas = a.desatualizadas
+		}
+		if resumo.Tentativas > 0 {
+			resumo.SucessoPorOperacao = 100 * float64(resumo.Ok) / float64(resumo.Tentativas)
+		}
+
+		// Quedas: sequências de intervalos down; intervalos sem dados não encerram nem iniciam uma queda.
+		var quedas []int
+		emQueda := 0
+		for i := range relatorio.LinhaDoTempo {
+			estado := estadoSemDados
+			if c, ok := a.intervalos[op][i]; ok {
+				resumo.IntervalosComDados++
+				estado = estadoUp
+				if c.falha > 0 {
+					estado = estadoDown
+				}
+			}
+			relatorio.LinhaDoTempo[i].Estados[op] = estado
+			switch estado {
+			case estadoUp:
+				resumo.IntervalosUp++
+				if emQueda > 0 {
+					quedas = append(quedas, emQueda)
+					emQueda = 0
+				}
+			case estadoDown:
+				emQueda++
+			}
+		}
+		somaDasQuedas := 0
+		for _, q := range quedas {
+			somaDasQuedas += q
+		}
+		resumo.Quedas = len(quedas)
+		if emQueda > 0 {
+			resumo.Quedas++
+			resumo.EmQuedaNoFim = true
+		}
+		for _, q := range append(quedas, emQueda) {
+			resumo.MaiorQuedaS = max(resumo.MaiorQuedaS, (time.Duration(q) * a.janela).Seconds())
+		}
+		if len(quedas) > 0 {
+			resumo.MTTRS = (time.Duration(somaDasQuedas) * a.janela).Seconds() / float64(len(quedas))
+		}
+
+		if resumo.IntervalosComDados > 0 {
+			resumo.Disponibilidade = 100 * float64(resumo.IntervalosUp) / float64(resumo.IntervalosComDados)
+		}
+		resumo.AtendeSLO = resumo.IntervalosComDados > 0 && resumo.Disponibilidade >= slo
+		if slo < 100 {
+			resumo.OrcamentoConsumidoPc = 100 * (100 - resumo.Disponibilidade) / (100 - slo)
+		}
+		h := a.latencias[op]
+		resumo.LatenciaP50Ms = emMs(h.Percentil(50))
+		resumo.LatenciaP99Ms = emMs(h.Percentil(99))
+		resumo.LatenciaMaxMs = emMs(h.Maximo())
+		relatorio.Operacoes = append(relatorio.Operacoes, resumo)
+	}
+	return relatorio
+}
+
+func imprimirRelatorio(r RelatorioDaSonda) {
+	fmt.Printf("availability_probe: duracao=%s intervalos=%d janela=%s slo=%.3f%%\n", r.Duracao.Round(time.Millisecond), len(r.LinhaDoTempo), r.Janela, r.SLO)
+	for _, op := range r.Operacoes {
+		slo := "ATENDE"
+		if !op.AtendeSLO {
+			slo = "VIOLA"
+		}
+		fmt.Printf("  %-8s cons=%-13s tentativas=%d ok=%d (%.2f%%) disponibilidade=%.3f%% (%d/%d intervalos) slo=%s orcamento_consumido=%.1f%%\n",
+			op.Operacao, op.Consistencia, op.Tentativas, op.Ok, op.SucessoPorOperacao, op.Disponibilidade, op.IntervalosUp, op.IntervalosComDados,
+			slo, op.OrcamentoConsumidoPc)
+		fmt.Printf("  %-8s quedas=%d maior_queda_s=%.1f mttr_s=%.1f em_queda_no_fim=%t latencia_ms p50=%.1f p99=%.1f max=%.1f\n",
+			"", op.Quedas, op.MaiorQuedaS, op.MTTRS, op.EmQuedaNoFim, op.LatenciaP50Ms, op.LatenciaP99Ms, op.LatenciaMaxMs)
+		if op.Desatualizadas > 0 {
+			fmt.Printf("  %-8s leituras sem a escrita confirmada=%d\n", "", op.Desatualizadas)
+		}
+	}
+	// Linha do tempo do canário: um caractere por intervalo (+ up, X down, . sem dados)
+	simbolos := map[string]byte{estadoUp: '+', estadoDown: 'X', estadoSemDados: '.'}
+	linha := make([]byte, 0, len(r.LinhaDoTempo))
+	for _, intervalo := range r.LinhaDoTempo {
+		linha = append(linha, simbolos[intervalo.Estados[operacaoCanario]])
+	}
+	fmt.Println("  linha do tempo do canario (+ up, X down, . sem dados):")
+	for len(linha) > 0 {
+		n := min(len(linha), 100)
+		fmt.Printf("    %s\n", linha[:n])
+		linha = linha[n:]
+	}
+}
+
+// JSON com o relatório completo ou CSV com a linha do tempo (um intervalo por linha).
+func gravarRelatorio(caminho string, r RelatorioDaSonda) error {
+	if strings.EqualFold(filepath.Ext(caminho), ".json") {
+		conteudo, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(caminho, conteudo, 0o644)
+	}
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+	cw := csv.NewWriter(arquivo)
+	cw.Write(append([]string{"inicio"}, operacoes...))
+	for _, intervalo := range r.LinhaDoTempo {
+		linha := []string{intervalo.Inicio.Format(time.RFC3339Nano)}
+		for _, op := range operacoes {
+			linha = append(linha, intervalo.Estados[op])
+		}
+		cw.Write(linha)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func escrever(client *http.Client, baseURL, w string, corpo writeReq) error {
+	b, _ := json.Marshal(corpo)
+	resp, err := client.Post(baseURL+"?w="+url.QueryEscape(w), "application/json", bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	conteudo, _ := io.ReadAll(resp.Body)
+	var wr writeResp
+	if json.Unmarshal(conteudo, &wr) != nil {
+		wr.Erro = strings.TrimSpace(string(conteudo)) // 400 em texto (http.Error)
+	}
+	if resp.StatusCode/100 != 2 || !wr.Sucesso {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, wr.Erro)
+	}
+	return nil
+}
+
+// Lê as últimas leituras da partição da escrita e procura o marcador do canário.
+func lerEConferir(client *http.Client, baseURL, r string, escrita writeReq) (bool, error) {
+	q := url.Values{}
+	q.Set("sensor_id", escrita.IdentificadorDoSensor)
+	q.Set("data", escrita.InstanteDoEventoISO8601[:10])
+	q.Set("limite", "20")
+	q.Set("r", r)
+	resp, err := client.Get(baseURL + "?" + q.Encode())
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	conteudo, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(conteudo)))
+	}
+	var lr leituraResp
+	if err := json.Unmarshal(conteudo, &lr); err != nil {
+		return false, fmt.Errorf("resposta invalida: %w", err)
+	}
+	for _, item := range lr.Itens {
+		if item.AtributosAdicionais[atributoDoMarcador] == escrita.AtributosAdicionais[atributoDoMarcador] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func emMs(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+// Métricas do canário, com os mesmos buckets de latência do go-stress.
+type metricasDaSonda struct {
+	operacoes     *prometheus.CounterVec
+	latencia      *prometheus.HistogramVec
+	disponivel    *prometheus.GaugeVec
+	consistencias map[string]string
+}
+
+func novasMetricasDaSonda(consistencias map[string]string, slo float64) *metricasDaSonda {
+	m := &metricasDaSonda{
+		operacoes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "probe_operacoes_total",
+			Help: "Operacoes do canario por operacao (escrita|leitura|canario), consistencia e resultado (ok|erro|desatualizada)",
+		}, []string{"operacao", "consistencia", "resultado"}),
+		latencia: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "probe_latencia_ms",
+			Help:    "Latencia das operacoes do canario em ms",
+			Buckets: []float64{1, 5, 10, 20, 50, 100, 200, 500, 1000, 2000},
+		}, []string{"operacao", "consistencia"}),
+		disponivel: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "probe_disponivel",
+			Help: "Resultado da ultima operacao do canario (1 = ok, 0 = falha)",
+		}, []string{"operacao", "consistencia"}),
+		consistencias: consistencias,
+	}
+	alvo := prometheus.NewGauge(prometheus.GaugeOpts{Name: "probe_slo_alvo_pct", Help: "Alvo de disponibilidade do canario em %"})
+	alvo.Set(slo)
+	prometheus.MustRegister(m.operacoes, m.latencia, m.disponivel, alvo)
+	return m
+}
 
-	disponibilidade := 0.0
-	if total > 0 {
-		disponibilidade = float64(sucesso) / float64(total) * 100
+func (m *metricasDaSonda) registrar(operacao string, latencia time.Duration, resultado string) {
+	consistencia := m.consistencias[operacao]
+	m.operacoes.WithLabelValues(operacao, consistencia, resultado).Inc()
+	if resultado == "ok" {
+		m.latencia.WithLabelValues(operacao, consistencia).Observe(float64(latencia.Milliseconds()))
+		m.disponivel.WithLabelValues(operacao, consistencia).Set(1)
+	} else {
+		m.disponivel.WithLabelValues(operacao, consistencia).Set(0)
 	}
-	fmt.Printf("availability_probe: total=%d sucesso=%d disponibilidade=%.2f%%\n", total, sucesso, disponibilidade)
 }
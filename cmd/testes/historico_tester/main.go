@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/historico"
+)
+
+type writeReq struct {
+	IdentificadorDoSensor   string            `json:"identificador_do_sensor"`
+	InstanteDoEventoISO8601 string            `json:"instante_do_evento_iso8601"`
+	ValorMedido             float64           `json:"valor_medido"`
+	AtributosAdicionais     map[string]string `json:"atributos_adicionais,omitempty"`
+}
+
+type writeResp struct {
+	Sucesso bool   `json:"sucesso"`
+	Erro    string `json:"erro,omitempty"`
+}
+
+// Leitura como serializada pelo ingestor (sensors.LeituraDeSensor não tem tags json).
+type leituraDoSensor struct {
+	InstanteDoEvento    time.Time
+	AtributosAdicionais map[string]string
+}
+
+type leituraResp struct {
+	Itens []leituraDoSensor `json:"itens"`
+}
+
+const atributoDoMarcador = "marcador"
+
+// Máximo aceito por /leituras/ultimas; sensores com mais leituras não cabem na leitura final.
+const limiteDaLeituraFinal = 5000
+
+// Carga dedicada que grava o histórico de invocações/conclusões contra /ingest e /leituras/ultima
+// e, ao final, verifica escritas perdidas, leituras desatualizadas e leituras não monotônicas.
+func main() {
+	var (
+		baseIngest   = flag.String("ingest", "http://localhost:8080/ingest", "URL /ingest")
+		baseRead     = flag.String("read", "http://localhost:8080/leituras/ultima", "URL /leituras/ultima")
+		baseFinal    = flag.String("read-final", "http://localhost:8080/leituras/ultimas", "URL /leituras/ultimas (leituras finais completas)")
+		consistW     = flag.String("w", "QUORUM", "Consistência de escrita")
+		consistR     = flag.String("r", "QUORUM", "Consistência de leitura")
+		consistFinal = flag.String("r-final", "ALL", "Consistência das leituras finais (estado após a carga)")
+		clientes     = flag.Int("clientes", 4, "Clientes concorrentes (cada um sequencial)")
+		sensores     = flag.Int("sensores", 3, "Sensores compartilhados pelos clientes")
+		duracao      = flag.Duration("duracao", 60*time.Second, "Duração da carga")
+		proporcao    = flag.Float64("escritas", 0.5, "Proporção de escritas (0 a 1)")
+		pausa        = flag.Duration("pausa", 20*time.Millisecond, "Pausa entre operações de um cliente")
+		quiescencia  = flag.Duration("quiescencia", 5*time.Second, "Espera entre o fim da carga e as leituras finais")
+		limite       = flag.Duration("limite", time.Second, "Desatualização tolerada após a confirmação de uma escrita")
+		httpTimeout  = flag.Duration("timeout", 5*time.Second, "Timeout HTTP")
+		arquivo      = flag.String("historico", "historico.jsonl", "Arquivo JSONL do histórico")
+		verificar    = flag.String("verificar", "", "Só verifica um histórico já gravado (não gera carga)")
+		relatorio    = flag.String("relatorio", "", "Arquivo JSON com o relatório da verificação")
+		exemplos     = flag.Int("exemplos", 5, "Contraexemplos exibidos por tipo de anomalia (0 = todos)")
+		semente      = flag.Int64("semente", 0, "Semente do sorteio de operações (0 = relógio)")
+	)
+	flag.Parse()
+
+	caminho := *verificar
+	if caminho == "" {
+		caminho = *arquivo
+		if *semente == 0 {
+			*semente = time.Now().UnixNano()
+		}
+		carga := cargaDoHistorico{
+			cliente: &http.Client{Timeout: *httpTimeout}, baseIngest: *baseIngest, baseRead: *baseRead, baseFinal: *baseFinal,
+			w: *consistW, r: *consistR, execucao: strconv.FormatInt(time.Now().UnixNano(), 36),
+			dias: &diasDosSensores{dias: map[string]map[string]bool{}},
+		}
+		if err := carga.executar(caminho, *clientes, *sensores, *duracao, *proporcao, *pausa, *quiescencia, *consistFinal, *semente); err != nil {
+			fmt.Printf("historico_tester: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	eventos, err := historico.CarregarHistorico(caminho)
+	if err != nil {
+		fmt.Printf("historico_tester: %v\n", err)
+		os.Exit(1)
+	}
+	resultado, err := historico.Verificar(eventos, *limite)
+	if err != nil {
+		fmt.Printf("historico_tester: historico invalido: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Print(historico.FormatarRelatorio(resultado, *exemplos))
+	if *relatorio != "" {
+		conteudo, _ := json.MarshalIndent(resultado, "", "  ")
+		if err := os.WriteFile(*relatorio, conteudo, 0o644); err != nil {
+			fmt.Printf("historico_tester: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if len(resultado.Anomalias) > 0 {
+		os.Exit(2)
+	}
+}
+
+type cargaDoHistorico struct {
+	cliente              *http.Client
+	baseIngest, baseRead string
+	baseFinal            string
+	w, r                 string
+	execucao             string // prefixo dos sensores e marcadores, único por execução
+	dias                 *diasDosSensores
+}
+
+// Dias (partições day_bucket) em que cada sensor recebeu escritas. Sem "data" o ingestor só consulta o
+// dia atual (UTC): numa carga que cruza a meia-noite, as escritas do dia anterior sumiriam das leituras.
+type diasDosSensores struct {
+	mu   sync.Mutex
+	dias map[string]map[string]bool
+}
+
+func (d *diasDosSensores) registrar(sensor string, instante time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dias[sensor] == nil {
+		d.dias[sensor] = map[string]bool{}
+	}
+	d.dias[sensor][instante.UTC().Format("2006-01-02")] = true
+}
+
+// Do mais novo para o mais antigo; sem escritas, só o dia atual.
+func (d *diasDosSensores) doMaisNovo(sensor string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	dias := make([]string, 0, len(d.dias[sensor]))
+	for dia := range d.dias[sensor] {
+		dias = append(dias, dia)
+	}
+	if len(dias) == 0 {
+		return []string{time.Now().UTC().Format("2006-01-02")}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dias)))
+	return dias
+}
+
+func (c cargaDoHistorico) executar(caminho string, clientes, sensores int, duracao time.Duration, proporcao float64,
+	pausa, quiescencia time.Duration, consistFinal string, semente int64) error {
+	gravador, err := historico.NovoGravadorDeHistorico(caminho)
+	if err != nil {
+		return err
+	}
+	nomes := make([]string, sensores)
+	for i := range nomes {
+		nomes[i] = fmt.Sprintf("historico-%s-%d", c.execucao, i)
+	}
+	fmt.Printf("historico_tester: clientes=%d sensores=%d duracao=%s w=%s r=%s semente=%d historico=%s\n",
+		clientes, sensores, duracao, c.w, c.r, semente, caminho)
+
+	prazo := time.Now().Add(duracao)
+	var grupo sync.WaitGroup
+	for id := 1; id <= clientes; id++ {
+		grupo.Add(1)
+		go func(id int) {
+			defer grupo.Done()
+			sorteio := rand.New(rand.NewSource(semente + int64(id)))
+			for seq := 0; time.Now().Before(prazo); seq++ {
+				sensor := nomes[sorteio.Intn(len(nomes))]
+				if sorteio.Float64() < proporcao {
+					c.escrever(gravador, id, sensor, fmt.Sprintf("c%d-%d", id, seq))
+				} else {
+					c.ler(gravador, id, sensor, c.r)
+				}
+				time.Sleep(pausa)
+			}
+		}(id)
+	}
+	grupo.Wait()
+
+	// Leituras finais pelo cliente 0, depois de o cluster ter tempo para convergir; trazem todas as
+	// leituras do sensor para que escritas perdidas já superadas por outras também apareçam
+	fmt.Printf("historico_tester: carga concluida; aguardando %s para as leituras finais (%s)\n", quiescencia, consistFinal)
+	time.Sleep(quiescencia)
+	for _, sensor := range nomes {
+		for tentativa := 0; tentativa < 3 && !c.lerFinal(gravador, sensor, consistFinal); tentativa++ {
+			time.Sleep(time.Second)
+		}
+	}
+	return gravador.Fechar()
+}
+
+// Erro de rede ou 5xx deixam a escrita indeterminada: ela pode ter sido aplicada.
+func (c cargaDoHistorico) escrever(gravador *historico.GravadorDeHistorico, cliente int, sensor, marcador string) {
+	instante := time.Now().UTC().Truncate(time.Microsecond)
+	c.dias.registrar(sensor, instante)
+	op := gravador.Invocar(cliente, historico.OperacaoEscrita, sensor, marcador, instante)
+	corpo, _ := json.Marshal(writeReq{
+		IdentificadorDoSensor:   sensor,
+		InstanteDoEventoISO8601: instante.Format(time.RFC3339Nano),
+		ValorMedido:             rand.Float64() * 100,
+		AtributosAdicionais:     map[string]string{atributoDoMarcador: marcador},
+	})
+	resp, err := c.cliente.Post(fmt.Sprintf("%s?w=%s", c.baseIngest, url.QueryEscape(c.w)), "application/json", bytes.NewReader(corpo))
+	if err != nil {
+		gravador.Concluir(op, cliente, historico.OperacaoEscrita, sensor, historico.ResultadoIndeterminado, "", time.Time{}, err)
+		return
+	}
+	defer resp.Body.Close()
+	var wr writeResp
+	_ = json.NewDecoder(resp.Body).Decode(&wr)
+	switch {
+	case resp.StatusCode/100 == 2 && wr.Sucesso:
+		gravador.Concluir(op, cliente, historico.OperacaoEscrita, sensor, historico.ResultadoOk, "", time.Time{}, nil)
+	case resp.StatusCode >= 500:
+		gravador.Concluir(op, cliente, historico.OperacaoEscrita, sensor, historico.ResultadoIndeterminado, "", time.Time{},
+			fmt.Errorf("status %d: %s", resp.StatusCode, wr.Erro))
+	default:
+		gravador.Concluir(op, cliente, historico.OperacaoEscrita, sensor, historico.ResultadoFalha, "", time.Time{},
+			fmt.Errorf("status %d", resp.StatusCode))
+	}
+}
+
+func (c cargaDoHistorico) ler(gravador *historico.GravadorDeHistorico, cliente int, sensor, consistencia string) bool {
+	op := gravador.Invocar(cliente, historico.OperacaoLeitura, sensor, "", time.Time{})
+	// Dia mais novo com alguma linha: uma escrita recém-invocada no dia seguinte ainda pode não ter sido aplicada
+	var itens []leituraDoSensor
+	for _, dia := range c.dias.doMaisNovo(sensor) {
+		q := url.Values{}
+		q.Set("sensor_id", sensor)
+		q.Set("r", consistencia)
+		q.Set("data", dia)
+		var err error
+		if itens, err = c.consultar(c.baseRead, q); err != nil {
+			gravador.Concluir(op, cliente, historico.OperacaoLeitura, sensor, historico.ResultadoFalha, "", time.Time{}, err)
+			return false
+		}
+		if len(itens) > 0 {
+			break
+		}
+	}
+	var valor string
+	var instante time.Time
+	if len(itens) > 0 {
+		valor, instante = itens[0].AtributosAdicionais[atributoDoMarcador], itens[0].InstanteDoEvento
+	}
+	gravador.Concluir(op, cliente, historico.OperacaoLeitura, sensor, historico.ResultadoOk, valor, instante, nil)
+	return true
+}
+
+// Leitura final pelo cliente 0 com todos os marcadores do sensor (mais novo primeiro), em cada dia com escritas.
+func (c cargaDoHistorico) lerFinal(gravador *historico.GravadorDeHistorico, sensor, consistencia string) bool {
+	op := gravador.InvocarLeituraFinal(0, sensor)
+	var itens []leituraDoSensor
+	var truncadaEm time.Time
+	for _, dia := range c.dias.doMaisNovo(sensor) {
+		q := url.Values{}
+		q.Set("sensor_id", sensor)
+		q.Set("r", consistencia)
+		q.Set("data", dia)
+		q.Set("limite", strconv.Itoa(limiteDaLeituraFinal))
+		doDia, err := c.consultar(c.baseFinal, q)
+		if err != nil {
+			gravador.Concluir(op, 0, historico.OperacaoLeitura, sensor, historico.ResultadoFalha, "", time.Time{}, err)
+			return false
+		}
+		// Truncada: o verificador só procura escritas perdidas mais novas que a linha mais antiga devolvida
+		// (o corte mais novo entre os dias truncados)
+		if len(doDia) >= limiteDaLeituraFinal {
+			corte := doDia[len(doDia)-1].InstanteDoEvento
+			if corte.After(truncadaEm) {
+				truncadaEm = corte
+			}
+			fmt.Printf("historico_tester: aviso: %s tem %d leituras ou mais em %s; escritas ate %s nao sao verificadas (use mais -sensores)\n",
+				sensor, limiteDaLeituraFinal, dia, corte.Format(time.RFC3339Nano))
+		}
+		itens = append(itens, doDia...)
+	}
+	valores := make([]string, 0, len(itens))
+	var instante time.Time
+	for i, item := range itens {
+		valores = append(valores, item.AtributosAdicionais[atributoDoMarcador])
+		if i == 0 {
+			instante = item.InstanteDoEvento
+		}
+	}
+	gravador.ConcluirLeituraFinal(op, 0, sensor, valores, instante, truncadaEm)
+	return true
+}
+
+func (c cargaDoHistorico) consultar(base string, q url.Values) ([]leituraDoSensor, error) {
+	resp, err := c.cliente.Get(fmt.Sprintf("%s?%s", base, q.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var lr leituraResp
+	if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil {
+		return nil, err
+	}
+	return lr.Itens, nil
+}
@@ -2,11 +2,16 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/gocql/gocql"
 	injAdapt "github.com/pdrpinto/tcc-cassandra/internal/falhas/adaptadores"
 	injApp "github.com/pdrpinto/tcc-cassandra/internal/falhas/aplicacao"
 	injPorts "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
@@ -14,33 +19,74 @@ import (
 
 func main() {
 	var (
-		parametroCenario    = flag.String("cenario", "derrubar1", "Cenario: derrubar1|derrubar2|particao|custom")
+		parametroCenario    = flag.String("cenario", "derrubar1", "Cenario: derrubar1|derrubar2|particao|particao_parcial|custom|caos (ver -caos)")
 		parametroContainers = flag.String("containers", "cassandra2", "Lista de containers separados por vírgula (para custom)")
 		parametroRede       = flag.String("rede", "tcc-net", "Nome da rede Docker (para partição)")
+		parametroPlano      = flag.String("plano", "", "Arquivo de plano YAML/JSON (substitui -cenario)")
+		parametroCaos       = flag.String("caos", "caos.yaml", "Configuração do modo caos (YAML/JSON) usada por -cenario caos")
+		parametroSemente    = flag.Int64("semente", 0, "Semente do caos (sobrescreve a do arquivo; 0 = mantém a do arquivo ou usa o relógio)")
+		parametroSalvar     = flag.String("salvar-plano", "", "Grava o plano resolvido (no caos, com a semente) para reexecutar com -plano; no caos o padrão é caos_<semente>.yaml")
+		parametroDryRun     = flag.Bool("dry-run", false, "Valida e imprime a linha do tempo resolvida sem executar")
+		parametroImagemRede = flag.String("imagem-rede", "", "Imagem auxiliar com tc/iptables (ex.: nicolaka/netshoot); vazio = docker exec no próprio container")
+		parametroVerificar  = flag.Bool("verificar", false, "Executa apenas a pré-checagem de privilégios de rede (tc/iptables) do plano")
+		parametroAdaptador  = flag.String("orquestrador", "cli", "Adaptador de falhas: cli (docker CLI), api (Docker Engine API pelo socket) ou processos (nós locais, ver -inventario)")
+		parametroSocket     = flag.String("docker-socket", "/var/run/docker.sock", "Socket da Docker Engine API (orquestrador api)")
+		parametroProjeto    = flag.String("projeto-compose", "", "Projeto do compose usado para resolver alvos servico:<nome> (orquestrador api)")
+		parametroInventario = flag.String("inventario", "", "Inventário YAML/JSON dos nós como processos locais (orquestrador processos)")
+		parametroManter     = flag.Bool("manter-falhas", false, "Não desfaz as falhas pendentes ao concluir o plano (erro e Ctrl-C sempre desfazem)")
+		parametroEventos    = flag.String("eventos", "", "Arquivo JSONL com um evento por etapa executada (append)")
+		parametroMetricas   = flag.String("metricas", "", "Endereço para expor /metrics das etapas ao Prometheus (ex.: :9101)")
+		parametroGrafana    = flag.String("grafana-url", os.Getenv("GRAFANA_URL"), "URL do Grafana para anotar as etapas (ex.: http://localhost:3000)")
+		parametroTokenGraf  = flag.String("grafana-token", os.Getenv("GRAFANA_TOKEN"), "Token de service account do Grafana")
+		parametroTags       = flag.String("grafana-tags", "", "Tags extras das anotações, separadas por vírgula")
+		parametroHosts      = flag.String("hosts", os.Getenv("CASSANDRA_HOSTS"), "Hosts do Cassandra para as etapas aguardar schema_acordo/consulta (vazio = só nodetool)")
+		parametroNos        = flag.String("nos", "cassandra1,cassandra2,cassandra3", "Containers de todos os nós do cluster (aguardar hints_drenados sem container soma os hints de todos)")
 	)
 	flag.Parse()
 
-	orq := injAdapt.NovoOrquestradorDeFalhasDockerCLI()
-	servico := injApp.ServicoDeInjecaoDeFalhas{Orquestrador: orq}
-	ctx := context.Background()
+	// Construir o orquestrador não tem efeitos colaterais; ele só é consultado na validação das redes
+	orq, err := injAdapt.NovoOrquestradorDeFalhas(*parametroAdaptador, *parametroSocket, *parametroProjeto, *parametroImagemRede, *parametroInventario)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	ctx, pararSinais := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer pararSinais()
 
 	var plano []injPorts.EtapaDoPlano
-	switch *parametroCenario {
+	var caos *injApp.ConfiguracaoDoCaos
+	cenario := *parametroCenario
+	if *parametroPlano != "" {
+		cenario = "arquivo"
+	}
+	switch cenario {
+	case "arquivo":
+		if plano, err = injApp.CarregarPlanoDeArquivo(*parametroPlano); err != nil {
+			fmt.Printf("Erro ao carregar plano: %v\n", err)
+			return
+		}
 	case "derrubar1":
 		plano = []injPorts.EtapaDoPlano{
 			{MomentoRelativoSegundos: 5, Acao: "parar", NomeDoContainer: "cassandra2", TimeoutSegundos: 10},
-			{MomentoRelativoSegundos: 60, Acao: "continuar", NomeDoContainer: "cassandra2"}, // no effect after stop, illustrative
+			{MomentoRelativoSegundos: 60, Acao: "iniciar", NomeDoContainer: "cassandra2"},
 		}
 	case "derrubar2":
 		plano = []injPorts.EtapaDoPlano{
 			{MomentoRelativoSegundos: 5, Acao: "parar", NomeDoContainer: "cassandra2", TimeoutSegundos: 10},
 			{MomentoRelativoSegundos: 10, Acao: "parar", NomeDoContainer: "cassandra3", TimeoutSegundos: 10},
+			{MomentoRelativoSegundos: 60, Acao: "iniciar", NomeDoContainer: "cassandra2"},
+			{MomentoRelativoSegundos: 60, Acao: "iniciar", NomeDoContainer: "cassandra3"},
 		}
 	case "particao":
 		plano = []injPorts.EtapaDoPlano{
 			{MomentoRelativoSegundos: 5, Acao: "desconectar", NomeDoContainer: "cassandra2", NomeDaRede: *parametroRede},
 			{MomentoRelativoSegundos: 60, Acao: "reconectar", NomeDoContainer: "cassandra2", NomeDaRede: *parametroRede},
 		}
+	case "particao_parcial":
+		// cassandra1 e cassandra3 não se veem; ambos continuam vendo cassandra2
+		plano = []injPorts.EtapaDoPlano{
+			{MomentoRelativoSegundos: 5, Acao: "bloquear_trafego", NomeDoContainer: "cassandra1", NomeDoContainerRemoto: "cassandra3", Direcao: injPorts.DirecaoAmbas, DuracaoSegundos: 60},
+		}
 	case "custom":
 		ids := strings.Split(*parametroContainers, ",")
 		momento := 5
@@ -49,19 +95,139 @@ func main() {
 			if id == "" {
 				continue
 			}
-			plano = append(plano, injPorts.EtapaDoPlano{MomentoRelativoSegundos: momento, Acao: "parar", NomeDoContainer: id, TimeoutSegundos: 10})
+			plano = append(plano, injPorts.EtapaDoPlano{MomentoRelativoSegundos: momento, Acao: "parar", NomeDoContainer: id, TimeoutSegundos: 10, DuracaoSegundos: 60})
 			momento += 5
 		}
+	case "caos":
+		configuracao, err := injApp.CarregarConfiguracaoDoCaos(*parametroCaos)
+		if err != nil {
+			fmt.Printf("Erro ao carregar caos: %v\n", err)
+			return
+		}
+		if *parametroSemente != 0 {
+			configuracao.Semente = *parametroSemente
+		}
+		configuracao, plano, err = injApp.GerarPlanoDeCaos(configuracao)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		caos = &configuracao
 	default:
 		fmt.Println("Cenário inválido")
 		return
 	}
 
-	fmt.Printf("Executando plano de falhas (%d etapas)\n", len(plano))
+	// Valida antes de gravar o plano ou abrir qualquer dependência (sessão, eventos, métricas)
+	if err := errors.Join(injApp.ValidarPlano(plano), injApp.ValidarRedesDoPlano(ctx, orq, plano)); err != nil {
+		fmt.Printf("Plano inválido:\n%v\n", err)
+		return
+	}
+	destino := *parametroSalvar
+	if caos != nil && destino == "" {
+		destino = fmt.Sprintf("caos_%d.yaml", caos.Semente)
+	}
+	if destino != "" {
+		if err := injApp.SalvarPlanoEmArquivo(destino, injApp.ArquivoDePlano{Caos: caos, Etapas: plano}); err != nil {
+			fmt.Printf("Erro ao salvar plano: %v\n", err)
+			return
+		}
+	}
+	if caos != nil {
+		fmt.Printf("Caos: semente=%d, %d falhas; plano salvo em %s (reexecute com -plano %s)\n", caos.Semente, len(plano), destino, destino)
+	}
+	etapas := injApp.ExpandirPlano(plano)
+	if *parametroDryRun {
+		fmt.Printf("Linha do tempo (%d etapas):\n%s", len(etapas), injApp.FormatarLinhaDoTempo(etapas))
+		return
+	}
+
+	sessao, err := novaSessao(dividirLista(*parametroHosts))
+	if err != nil {
+		fmt.Printf("Erro ao conectar ao Cassandra: %v\n", err)
+		return
+	}
+	if sessao != nil {
+		defer sessao.Close()
+	}
+	// O caos sempre termina com o cluster recuperado, mesmo com -manter-falhas
+	servico := &injApp.ServicoDeInjecaoDeFalhas{Orquestrador: orq, ReverterAoConcluir: !*parametroManter || caos != nil,
+		Verificador: injAdapt.NovoVerificadorDeClusterCassandra(sessao, orq), NosDoCluster: strings.Split(*parametroNos, ",")}
+	if *parametroVerificar {
+		if err := servico.VerificarPreRequisitos(ctx, plano); err != nil {
+			fmt.Printf("Pré-checagem falhou:\n%v\n", err)
+			return
+		}
+		fmt.Println("Pré-checagem OK")
+		return
+	}
+	eventos, fecharEventos, err := injAdapt.NovosRegistrosDeEventos(*parametroEventos, *parametroMetricas, *parametroGrafana, *parametroTokenGraf, dividirLista(*parametroTags))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer fecharEventos()
+	if len(eventos) > 0 {
+		servico.Eventos = eventos
+	}
+
+	fmt.Printf("Executando plano de falhas (%d etapas)\n", len(etapas))
 	inicio := time.Now()
-	if err := servico.ExecutarPlano(ctx, plano); err != nil {
+	err = servico.ExecutarPlano(ctx, plano)
+	fmt.Println("Diário de ações:")
+	for _, r := range servico.Diario() {
+		origem := "plano"
+		if r.Reversao {
+			origem = "reversao"
+		}
+		fmt.Printf("  %s %-8s %-23s %s %s\n", r.Momento.Format(time.RFC3339), origem, r.Etapa.Acao, r.Etapa.NomeDoContainer, r.Erro)
+		if len(r.IpsDoRemoto) > 0 {
+			fmt.Printf("      ips do remoto: %s\n", strings.Join(r.IpsDoRemoto, " "))
+		}
+		if r.EstadoAntes != "" || r.EstadoDepois != "" {
+			fmt.Printf("      antes:  %s\n      depois: %s\n", r.EstadoAntes, r.EstadoDepois)
+		}
+	}
+	if recuperacoes := servico.Recuperacoes(); len(recuperacoes) > 0 {
+		fmt.Println("Recuperação do cluster:")
+		for _, r := range recuperacoes {
+			situacao := "recuperado"
+			if !r.Recuperado {
+				situacao = "NAO recuperado"
+			}
+			fmt.Printf("  %-15s %-12s %s em %s (%d tentativas) %s\n", r.Condicao, r.NomeDoContainer, situacao,
+				r.TempoAteRecuperacao.Round(time.Millisecond), r.Tentativas, r.UltimoDetalhe)
+		}
+	}
+	if err != nil {
 		fmt.Printf("Erro no plano: %v\n", err)
 		return
 	}
 	fmt.Printf("Plano concluído em %s\n", time.Since(inicio))
+	if *parametroMetricas != "" {
+		// Tempo para o Prometheus coletar as últimas etapas antes de o processo terminar
+		fmt.Println("Aguardando 10s para a coleta das métricas...")
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// Sessão usada só pelas condições de aguardar que dependem de CQL; nil sem hosts.
+func novaSessao(hosts []string) (*gocql.Session, error) {
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+	cluster := gocql.NewCluster(hosts...)
+	cluster.ProtoVersion = 4
+	cluster.Timeout = 5 * time.Second
+	return cluster.CreateSession()
+}
+
+func dividirLista(lista string) []string {
+	var itens []string
+	for _, item := range strings.Split(lista, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			itens = append(itens, item)
+		}
+	}
+	return itens
 }
@@ -2,71 +2,278 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"net/url"
-	"sync/atomic"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/estatisticas"
 )
 
 type writeReq struct {
-	IdentificadorDoSensor   string  `json:"identificador_do_sensor"`
-	InstanteDoEventoISO8601 string  `json:"instante_do_evento_iso8601"`
-	ValorMedido             float64 `json:"valor_medido"`
+	IdentificadorDoSensor   string            `json:"identificador_do_sensor"`
+	InstanteDoEventoISO8601 string            `json:"instante_do_evento_iso8601"`
+	ValorMedido             float64           `json:"valor_medido"`
+	AtributosAdicionais     map[string]string `json:"atributos_adicionais,omitempty"`
+}
+
+type writeResp struct {
+	Sucesso bool   `json:"sucesso"`
+	Erro    string `json:"erro,omitempty"`
+}
+
+// Leitura como serializada pelo ingestor (sensors.LeituraDeSensor não tem tags json).
+type leituraDoSensor struct {
+	InstanteDoEvento    time.Time
+	ValorMedido         float64
+	AtributosAdicionais map[string]string
 }
 
 type leituraResp struct {
-	Quantidade int `json:"quantidade"`
+	Quantidade int               `json:"quantidade"`
+	Itens      []leituraDoSensor `json:"itens"`
+}
+
+// Atributo gravado em cada escrita para reconhecê-la na leitura.
+const atributoDoMarcador = "marcador"
+
+// Faixas do atraso de visibilidade (limite superior; a última é aberta).
+var faixasDeAtraso = []time.Duration{100 * time.Millisecond, 500 * time.Millisecond, time.Second, 5 * time.Second}
+
+// Resultado de uma combinação W/R.
+type ResumoDaCombinacao struct {
+	W                            string           `json:"w"`
+	R                            string           `json:"r"`
+	Rodadas                      int              `json:"rodadas"`
+	ErrosDeEscrita               int              `json:"erros_de_escrita"`
+	ErrosDeLeitura               int              `json:"erros_de_leitura"`               // leituras com erro HTTP/5xx (todas as tentativas)
+	Verificadas                  int              `json:"verificadas"`                    // rodadas com escrita confirmada
+	PrimeiraLeituraDesatualizada int              `json:"primeira_leitura_desatualizada"` // primeira leitura ok, sem o marcador
+	PrimeiraLeituraComErro       int              `json:"primeira_leitura_com_erro"`      // rodadas cuja primeira leitura falhou
+	NuncaVisiveis                int              `json:"nunca_visiveis"`                 // escrita confirmada e não lida até o prazo
+	PercentualDesatualizado      float64          `json:"percentual_desatualizado"`       // sobre as rodadas com primeira leitura ok
+	AtrasoP50Ms                  float64          `json:"atraso_p50_ms"`
+	AtrasoP90Ms                  float64          `json:"atraso_p90_ms"`
+	AtrasoP99Ms                  float64          `json:"atraso_p99_ms"`
+	AtrasoMaxMs                  float64          `json:"atraso_max_ms"`
+	Faixas                       map[string]int64 `json:"faixas"` // ex.: "<=100ms": 12
 }
 
 func main() {
 	var (
-		baseIngest  = flag.String("ingest", "http://localhost:8080/ingest", "URL /ingest")
-		baseRead    = flag.String("read", "http://localhost:8080/leituras/ultima", "URL /leituras/ultima")
-		consistW    = flag.String("w", "QUORUM", "Consistência de escrita")
-		consistR    = flag.String("r", "ONE", "Consistência de leitura")
-		rounds      = flag.Int("rounds", 200, "Rodadas de W->R")
-		delayRead   = flag.Duration("delay", 50*time.Millisecond, "Atraso entre W e R")
-		sensorID    = flag.String("sensor", "sensor-staleness", "Sensor fixo a testar")
-		httpTimeout = flag.Duration("timeout", 5*time.Second, "Timeout HTTP")
+		baseIngest     = flag.String("ingest", "http://localhost:8080/ingest", "URL /ingest")
+		baseRead       = flag.String("read", "http://localhost:8080/leituras/ultima", "URL /leituras/ultima")
+		consistW       = flag.String("w", "QUORUM", "Consistências de escrita separadas por vírgula (ex.: ONE,QUORUM)")
+		consistR       = flag.String("r", "ONE", "Consistências de leitura separadas por vírgula; cada W é testada com cada R")
+		rounds         = flag.Int("rounds", 200, "Rodadas de W->R por combinação")
+		delayRead      = flag.Duration("delay", 50*time.Millisecond, "Atraso entre a confirmação da escrita e a primeira leitura")
+		intervaloRetry = flag.Duration("retry", 20*time.Millisecond, "Intervalo entre novas leituras até a escrita ficar visível")
+		prazo          = flag.Duration("prazo", 10*time.Second, "Prazo, desde a confirmação da escrita, para a escrita ficar visível")
+		sensorID       = flag.String("sensor", "sensor-staleness", "Prefixo do sensor (um sensor por combinação)")
+		httpTimeout    = flag.Duration("timeout", 5*time.Second, "Timeout HTTP")
+		saida          = flag.String("saida", "", "Arquivo do resumo por combinação (.json ou .csv)")
 	)
 	flag.Parse()
 
 	client := &http.Client{Timeout: *httpTimeout}
+	execucao := strconv.FormatInt(time.Now().UnixNano(), 36)
 
-	var stales int64
+	var resumos []ResumoDaCombinacao
+	for _, w := range dividirLista(*consistW) {
+		for _, r := range dividirLista(*consistR) {
+			sensor := fmt.Sprintf("%s-%s-%s", *sensorID, strings.ToLower(w), strings.ToLower(r))
+			resumo := ResumoDaCombinacao{W: w, R: r, Rodadas: *rounds, Faixas: map[string]int64{}}
+			atrasos := estatisticas.NovoHistogramaDeLatencias()
 
-	for i := 0; i < *rounds; i++ {
-		// write
-		wr := writeReq{
-			IdentificadorDoSensor:   *sensorID,
-			InstanteDoEventoISO8601: time.Now().UTC().Format(time.RFC3339),
-			ValorMedido:             rand.Float64()*100 + 1,
-		}
-		b, _ := json.Marshal(wr)
-		ingestURL := fmt.Sprintf("%s?w=%s", *baseIngest, *consistW)
-		_, _ = client.Post(ingestURL, "application/json", bytes.NewReader(b))
-
-		time.Sleep(*delayRead)
-
-		// read last
-		q := url.Values{}
-		q.Set("sensor_id", *sensorID)
-		q.Set("r", *consistR)
-		readURL := fmt.Sprintf("%s?%s", *baseRead, q.Encode())
-		resp, err := client.Get(readURL)
-		if err == nil && resp.StatusCode == 200 {
-			var lr leituraResp
-			_ = json.NewDecoder(resp.Body).Decode(&lr)
-			resp.Body.Close()
-			if lr.Quantidade == 0 {
-				atomic.AddInt64(&stales, 1)
+			for i := 0; i < *rounds; i++ {
+				marcador := fmt.Sprintf("%s-%d", execucao, i)
+				escrita := writeReq{
+					IdentificadorDoSensor:   sensor,
+					InstanteDoEventoISO8601: time.Now().UTC().Format(time.RFC3339Nano),
+					ValorMedido:             float64(i) + rand.Float64(),
+					AtributosAdicionais:     map[string]string{atributoDoMarcador: marcador},
+				}
+				if err := escrever(client, *baseIngest, w, escrita); err != nil {
+					resumo.ErrosDeEscrita++
+					continue
+				}
+				confirmada := time.Now()
+				resumo.Verificadas++
+
+				time.Sleep(*delayRead)
+				for tentativa := 0; ; tentativa++ {
+					inicioDaLeitura := time.Now()
+					vista, err := lerEConferir(client, *baseRead, sensor, r, escrita)
+					if err != nil {
+						resumo.ErrosDeLeitura++
+					}
+					if vista {
+						atraso := inicioDaLeitura.Sub(confirmada)
+						atrasos.Registrar(atraso)
+						resumo.Faixas[faixaDoAtraso(atraso, tentativa == 0)]++
+						break
+					}
+					// Erro na leitura não diz nada sobre a réplica estar desatualizada
+					if tentativa == 0 {
+						if err != nil {
+							resumo.PrimeiraLeituraComErro++
+						} else {
+							resumo.PrimeiraLeituraDesatualizada++
+						}
+					}
+					if time.Since(confirmada)+*intervaloRetry > *prazo {
+						resumo.NuncaVisiveis++
+						resumo.Faixas["nunca"]++
+						break
+					}
+					time.Sleep(*intervaloRetry)
+				}
+			}
+
+			if lidas := resumo.Verificadas - resumo.PrimeiraLeituraComErro; lidas > 0 {
+				resumo.PercentualDesatualizado = 100 * float64(resumo.PrimeiraLeituraDesatualizada) / float64(lidas)
 			}
+			resumo.AtrasoP50Ms = emMs(atrasos.Percentil(50))
+			resumo.AtrasoP90Ms = emMs(atrasos.Percentil(90))
+			resumo.AtrasoP99Ms = emMs(atrasos.Percentil(99))
+			resumo.AtrasoMaxMs = emMs(atrasos.Maximo())
+			resumos = append(resumos, resumo)
+
+			fmt.Printf("staleness_tester: w=%s r=%s rounds=%d erros_escrita=%d erros_leitura=%d primeira_com_erro=%d desatualizadas=%d (%.2f%%) nunca_visiveis=%d atraso_ms p50=%.1f p90=%.1f p99=%.1f max=%.1f\n",
+				w, r, resumo.Rodadas, resumo.ErrosDeEscrita, resumo.ErrosDeLeitura, resumo.PrimeiraLeituraComErro, resumo.PrimeiraLeituraDesatualizada, resumo.PercentualDesatualizado,
+				resumo.NuncaVisiveis, resumo.AtrasoP50Ms, resumo.AtrasoP90Ms, resumo.AtrasoP99Ms, resumo.AtrasoMaxMs)
+			fmt.Printf("  distribuicao: %s\n", formatarFaixas(resumo.Faixas))
+		}
+	}
+
+	if *saida != "" {
+		if err := gravarResumos(*saida, resumos); err != nil {
+			fmt.Printf("staleness_tester: erro ao gravar %s: %v\n", *saida, err)
+			os.Exit(1)
+		}
+		fmt.Printf("staleness_tester: resumo gravado em %s\n", *saida)
+	}
+}
+
+// Escrita com erro HTTP, status fora de 2xx ou sucesso=false não é confirmada.
+func escrever(client *http.Client, base, w string, escrita writeReq) error {
+	corpo, _ := json.Marshal(escrita)
+	resp, err := client.Post(fmt.Sprintf("%s?w=%s", base, url.QueryEscape(w)), "application/json", bytes.NewReader(corpo))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var wr writeResp
+	_ = json.NewDecoder(resp.Body).Decode(&wr)
+	if resp.StatusCode/100 != 2 || !wr.Sucesso {
+		return fmt.Errorf("status %d: %s", resp.StatusCode, wr.Erro)
+	}
+	return nil
+}
+
+// Lê a última leitura e confere se é a escrita da rodada (marcador; na falta dele, valor e instante).
+func lerEConferir(client *http.Client, base, sensor, r string, escrita writeReq) (bool, error) {
+	q := url.Values{}
+	q.Set("sensor_id", sensor)
+	q.Set("r", r)
+	resp, err := client.Get(fmt.Sprintf("%s?%s", base, q.Encode()))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return false, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	var lr leituraResp
+	if err := json.NewDecoder(resp.Body).Decode(&lr); err != nil {
+		return false, err
+	}
+	if len(lr.Itens) == 0 {
+		return false, nil
+	}
+	return mesmaLeitura(lr.Itens[0], escrita), nil
+}
+
+func mesmaLeitura(lida leituraDoSensor, escrita writeReq) bool {
+	if marcador, ok := lida.AtributosAdicionais[atributoDoMarcador]; ok {
+		return marcador == escrita.AtributosAdicionais[atributoDoMarcador]
+	}
+	instante, _ := time.Parse(time.RFC3339Nano, escrita.InstanteDoEventoISO8601)
+	// O timeuuid guarda o instante com resolução de 100ns
+	diferenca := lida.InstanteDoEvento.Sub(instante)
+	return lida.ValorMedido == escrita.ValorMedido && diferenca > -time.Microsecond && diferenca < time.Microsecond
+}
+
+// A primeira leitura visível cai na faixa "primeira"; as demais na menor faixa que contém o atraso.
+func faixaDoAtraso(atraso time.Duration, primeira bool) string {
+	if primeira {
+		return "primeira"
+	}
+	for _, limite := range faixasDeAtraso {
+		if atraso <= limite {
+			return "<=" + limite.String()
 		}
 	}
+	return ">" + faixasDeAtraso[len(faixasDeAtraso)-1].String()
+}
+
+func formatarFaixas(faixas map[string]int64) string {
+	ordem := []string{"primeira"}
+	for _, limite := range faixasDeAtraso {
+		ordem = append(ordem, "<="+limite.String())
+	}
+	ordem = append(ordem, ">"+faixasDeAtraso[len(faixasDeAtraso)-1].String(), "nunca")
+	var partes []string
+	for _, faixa := range ordem {
+		partes = append(partes, fmt.Sprintf("%s=%d", faixa, faixas[faixa]))
+	}
+	return strings.Join(partes, " ")
+}
+
+// Grava em JSON (extensão .json) ou CSV (demais).
+func gravarResumos(caminho string, resumos []ResumoDaCombinacao) error {
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+	if strings.EqualFold(filepath.Ext(caminho), ".json") {
+		enc := json.NewEncoder(arquivo)
+		enc.SetIndent("", "  ")
+		return enc.Encode(resumos)
+	}
+	w := csv.NewWriter(arquivo)
+	w.Write([]string{"w", "r", "rodadas", "erros_de_escrita", "erros_de_leitura", "verificadas", "primeira_leitura_desatualizada", "primeira_leitura_com_erro",
+		"nunca_visiveis", "percentual_desatualizado", "atraso_p50_ms", "atraso_p90_ms", "atraso_p99_ms", "atraso_max_ms", "faixas"})
+	for _, r := range resumos {
+		w.Write([]string{r.W, r.R, strconv.Itoa(r.Rodadas), strconv.Itoa(r.ErrosDeEscrita), strconv.Itoa(r.ErrosDeLeitura),
+			strconv.Itoa(r.Verificadas), strconv.Itoa(r.PrimeiraLeituraDesatualizada), strconv.Itoa(r.PrimeiraLeituraComErro), strconv.Itoa(r.NuncaVisiveis),
+			strconv.FormatFloat(r.PercentualDesatualizado, 'f', 2, 64), strconv.FormatFloat(r.AtrasoP50Ms, 'f', 2, 64),
+			strconv.FormatFloat(r.AtrasoP90Ms, 'f', 2, 64), strconv.FormatFloat(r.AtrasoP99Ms, 'f', 2, 64),
+			strconv.FormatFloat(r.AtrasoMaxMs, 'f', 2, 64), formatarFaixas(r.Faixas)})
+	}
+	w.Flush()
+	return w.Error()
+}
 
-	fmt.Printf("staleness_tester: rounds=%d stale_reads=%d stale_pct=%.2f%%\n", *rounds, stales, 100*float64(stales)/float64(*rounds))
+func emMs(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+
+func dividirLista(lista string) []string {
+	var itens []string
+	for _, item := range strings.Split(lista, ",") {
+		if item = strings.ToUpper(strings.TrimSpace(item)); item != "" {
+			itens = append(itens, item)
+		}
+	}
+	return itens
 }
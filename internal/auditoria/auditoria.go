@@ -0,0 +1,249 @@
+package auditoria
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Consulta as linhas de uma partição de sensor_readings entre dois instantes, no nível de consistência pedido.
+type PortaDeConsultaDeParticao interface {
+	InstantesDaParticao(ctx context.Context, sensor string, dia, inicio, fim time.Time, consistencia string) ([]time.Time, error)
+}
+
+type ParametrosDaAuditoria struct {
+	Consistencias []string // níveis de leitura auditados (ex.: ALL, QUORUM)
+	Concorrencia  int      // partições consultadas em paralelo
+	Exemplos      int      // linhas ausentes/extras guardadas por nível
+}
+
+// Linha presente na tabela sem escrita confirmada correspondente no registro.
+type LinhaExtra struct {
+	Sensor   string    `json:"sensor_id"`
+	Instante time.Time `json:"ts"`
+}
+
+type ResultadoPorConsistencia struct {
+	Consistencia string `json:"consistencia"`
+	Confirmadas  int64  `json:"confirmadas"` // escritas confirmadas nas partições consultadas com sucesso
+	Encontradas  int64  `json:"encontradas"`
+	Ausentes     int64  `json:"ausentes"`
+	// Extras que batem com uma escrita não confirmada (timeout, erro) foram aplicadas apesar do erro;
+	// as demais vieram de fora do registro (outra carga nos mesmos sensores e janela).
+	Extras                  int64               `json:"extras"`
+	NaoConfirmadasAplicadas int64               `json:"nao_confirmadas_aplicadas"`
+	AusentesPorEscrita      map[string]int64    `json:"ausentes_por_w"` // consistência usada na escrita perdida
+	ParticoesComErro        int                 `json:"particoes_com_erro"`
+	Erros                   []string            `json:"erros,omitempty"` // limitado como os exemplos
+	ExemplosDeAusentes      []RegistroDeEscrita `json:"exemplos_de_ausentes,omitempty"`
+	ExemplosDeExtras        []LinhaExtra        `json:"exemplos_de_extras,omitempty"`
+}
+
+type RelatorioDaAuditoria struct {
+	Registros       int                        `json:"registros"`
+	Confirmadas     int                        `json:"confirmadas"`
+	NaoConfirmadas  int                        `json:"nao_confirmadas"`
+	Particoes       int                        `json:"particoes"`
+	PorConsistencia []ResultadoPorConsistencia `json:"por_consistencia"`
+}
+
+// Partição (sensor_id, day_bucket) com as escritas registradas nela.
+type particao struct {
+	sensor         string
+	dia            time.Time
+	inicio, fim    time.Time
+	confirmadas    map[int64][]RegistroDeEscrita
+	naoConfirmadas map[int64]int64
+}
+
+// Resolução do ts (timeuuid): intervalos de 100ns, como em gocql.UUIDFromTime.
+func chaveDoInstante(t time.Time) int64 {
+	return t.Unix()*10_000_000 + int64(t.Nanosecond()/100)
+}
+
+func agruparPorParticao(registros []RegistroDeEscrita) []*particao {
+	porChave := map[string]*particao{}
+	for _, r := range registros {
+		instante := r.Instante.UTC()
+		dia := time.Date(instante.Year(), instante.Month(), instante.Day(), 0, 0, 0, 0, time.UTC)
+		chave := r.Sensor + "|" + dia.Format("2006-01-02")
+		p, ok := porChave[chave]
+		if !ok {
+			p = &particao{sensor: r.Sensor, dia: dia, inicio: instante, fim: instante,
+				confirmadas: map[int64][]RegistroDeEscrita{}, naoConfirmadas: map[int64]int64{}}
+			porChave[chave] = p
+		}
+		if instante.Before(p.inicio) {
+			p.inicio = instante
+		}
+		if instante.After(p.fim) {
+			p.fim = instante
+		}
+		if r.Confirmada {
+			p.confirmadas[chaveDoInstante(instante)] = append(p.confirmadas[chaveDoInstante(instante)], r)
+		} else {
+			p.naoConfirmadas[chaveDoInstante(instante)]++
+		}
+	}
+	particoes := make([]*particao, 0, len(porChave))
+	for _, p := range porChave {
+		particoes = append(particoes, p)
+	}
+	sort.Slice(particoes, func(i, j int) bool {
+		if particoes[i].sensor != particoes[j].sensor {
+			return particoes[i].sensor < particoes[j].sensor
+		}
+		return particoes[i].dia.Before(particoes[j].dia)
+	})
+	return particoes
+}
+
+// Relê cada partição do registro em cada nível de consistência e reconcilia com as escritas confirmadas.
+// Só a janela [primeira, última] escrita registrada da partição é lida, para não contar dados de outras execuções.
+func Auditar(ctx context.Context, consulta PortaDeConsultaDeParticao, registros []RegistroDeEscrita, p ParametrosDaAuditoria) RelatorioDaAuditoria {
+	particoes := agruparPorParticao(registros)
+	relatorio := RelatorioDaAuditoria{Registros: len(registros), Particoes: len(particoes)}
+	for _, r := range registros {
+		if r.Confirmada {
+			relatorio.Confirmadas++
+		} else {
+			relatorio.NaoConfirmadas++
+		}
+	}
+	concorrencia := p.Concorrencia
+	if concorrencia <= 0 {
+		concorrencia = 1
+	}
+	for _, consistencia := range p.Consistencias {
+		resultados := make([]ResultadoPorConsistencia, len(particoes))
+		indices := make(chan int)
+		var grupo sync.WaitGroup
+		for i := 0; i < concorrencia; i++ {
+			grupo.Add(1)
+			go func() {
+				defer grupo.Done()
+				for indice := range indices {
+					resultados[indice] = reconciliarParticao(ctx, consulta, particoes[indice], consistencia, p.Exemplos)
+				}
+			}()
+		}
+		for i := range particoes {
+			indices <- i
+		}
+		close(indices)
+		grupo.Wait()
+
+		total := ResultadoPorConsistencia{Consistencia: consistencia, AusentesPorEscrita: map[string]int64{}}
+		for _, r := range resultados {
+			somarResultado(&total, r, p.Exemplos)
+		}
+		relatorio.PorConsistencia = append(relatorio.PorConsistencia, total)
+	}
+	return relatorio
+}
+
+func reconciliarParticao(ctx context.Context, consulta PortaDeConsultaDeParticao, p *particao, consistencia string, exemplos int) ResultadoPorConsistencia {
+	resultado := ResultadoPorConsistencia{AusentesPorEscrita: map[string]int64{}}
+	instantes, err := consulta.InstantesDaParticao(ctx, p.sensor, p.dia, p.inicio, p.fim, consistencia)
+	if err != nil {
+		resultado.ParticoesComErro = 1
+		resultado.Erros = []string{fmt.Sprintf("%s %s: %v", p.sensor, p.dia.Format("2006-01-02"), err)}
+		return resultado
+	}
+	encontradas := map[int64]int64{}
+	primeiro := map[int64]time.Time{}
+	for _, t := range instantes {
+		chave := chaveDoInstante(t)
+		encontradas[chave]++
+		if _, ok := primeiro[chave]; !ok {
+			primeiro[chave] = t
+		}
+	}
+	for chave, escritas := range p.confirmadas {
+		resultado.Confirmadas += int64(len(escritas))
+		presentes := min(encontradas[chave], int64(len(escritas)))
+		resultado.Encontradas += presentes
+		for _, w := range escritas[presentes:] {
+			resultado.Ausentes++
+			resultado.AusentesPorEscrita[w.Consistencia]++
+			if exemplos == 0 || len(resultado.ExemplosDeAusentes) < exemplos {
+				resultado.ExemplosDeAusentes = append(resultado.ExemplosDeAusentes, w)
+			}
+		}
+	}
+	for chave, quantidade := range encontradas {
+		sobra := quantidade - int64(len(p.confirmadas[chave]))
+		if sobra <= 0 {
+			continue
+		}
+		resultado.Extras += sobra
+		resultado.NaoConfirmadasAplicadas += min(sobra, p.naoConfirmadas[chave])
+		if exemplos == 0 || len(resultado.ExemplosDeExtras) < exemplos {
+			resultado.ExemplosDeExtras = append(resultado.ExemplosDeExtras, LinhaExtra{Sensor: p.sensor, Instante: primeiro[chave]})
+		}
+	}
+	return resultado
+}
+
+func somarResultado(total *ResultadoPorConsistencia, r ResultadoPorConsistencia, exemplos int) {
+	total.Confirmadas += r.Confirmadas
+	total.Encontradas += r.Encontradas
+	total.Ausentes += r.Ausentes
+	total.Extras += r.Extras
+	total.NaoConfirmadasAplicadas += r.NaoConfirmadasAplicadas
+	total.ParticoesComErro += r.ParticoesComErro
+	for _, erro := range r.Erros {
+		if exemplos == 0 || len(total.Erros) < exemplos {
+			total.Erros = append(total.Erros, erro)
+		}
+	}
+	for w, n := range r.AusentesPorEscrita {
+		total.AusentesPorEscrita[w] += n
+	}
+	for _, w := range r.ExemplosDeAusentes {
+		if exemplos == 0 || len(total.ExemplosDeAusentes) < exemplos {
+			total.ExemplosDeAusentes = append(total.ExemplosDeAusentes, w)
+		}
+	}
+	for _, e := range r.ExemplosDeExtras {
+		if exemplos == 0 || len(total.ExemplosDeExtras) < exemplos {
+			total.ExemplosDeExtras = append(total.ExemplosDeExtras, e)
+		}
+	}
+}
+
+// Resumo legível por nível de consistência.
+func FormatarRelatorio(r RelatorioDaAuditoria) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "registros=%d confirmadas=%d nao_confirmadas=%d particoes=%d\n", r.Registros, r.Confirmadas, r.NaoConfirmadas, r.Particoes)
+	for _, c := range r.PorConsistencia {
+		fmt.Fprintf(&b, "leitura %s: confirmadas=%d encontradas=%d ausentes=%d extras=%d (nao_confirmadas_aplicadas=%d desconhecidas=%d) particoes_com_erro=%d\n",
+			c.Consistencia, c.Confirmadas, c.Encontradas, c.Ausentes, c.Extras, c.NaoConfirmadasAplicadas,
+			c.Extras-c.NaoConfirmadasAplicadas, c.ParticoesComErro)
+		if len(c.AusentesPorEscrita) > 0 {
+			niveis := make([]string, 0, len(c.AusentesPorEscrita))
+			for w := range c.AusentesPorEscrita {
+				niveis = append(niveis, w)
+			}
+			sort.Strings(niveis)
+			partes := make([]string, 0, len(niveis))
+			for _, w := range niveis {
+				partes = append(partes, fmt.Sprintf("w=%s:%d", w, c.AusentesPorEscrita[w]))
+			}
+			fmt.Fprintf(&b, "  ausentes por escrita: %s\n", strings.Join(partes, " "))
+		}
+		for _, w := range c.ExemplosDeAusentes {
+			fmt.Fprintf(&b, "  ausente: %s ts=%s w=%s origem=%s\n", w.Sensor, w.Instante.Format(time.RFC3339Nano), w.Consistencia, w.Origem)
+		}
+		for _, e := range c.ExemplosDeExtras {
+			fmt.Fprintf(&b, "  extra: %s ts=%s\n", e.Sensor, e.Instante.Format(time.RFC3339Nano))
+		}
+		for _, erro := range c.Erros {
+			fmt.Fprintf(&b, "  erro: %s\n", erro)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package auditoria
+
+import (
+	"context"
+	"time"
+
+	"github.com/gocql/gocql"
+	"github.com/pdrpinto/tcc-cassandra/internal/db"
+)
+
+// Lê as partições de sensor_readings com paginação (sem LIMIT), para que nenhuma linha da janela fique de fora.
+type ConsultaDeParticaoCassandra struct {
+	Sessao                 *gocql.Session
+	TempoLimitePorConsulta time.Duration
+}
+
+func (c ConsultaDeParticaoCassandra) InstantesDaParticao(ctx context.Context, sensor string, dia, inicio, fim time.Time, consistencia string) ([]time.Time, error) {
+	nivel, err := db.ConverterTextoParaNivelDeConsistencia(consistencia)
+	if err != nil {
+		return nil, err
+	}
+	ctxComTempo, cancelar := context.WithTimeout(ctx, c.TempoLimitePorConsulta)
+	defer cancelar()
+	iterador := c.Sessao.Query(
+		`SELECT ts FROM sensor_readings WHERE sensor_id = ? AND day_bucket = ? AND ts >= ? AND ts <= ?`,
+		sensor, dia, gocql.MinTimeUUID(inicio.UTC()), gocql.MaxTimeUUID(fim.UTC()),
+	).Consistency(nivel).WithContext(ctxComTempo).PageSize(5000).Iter()
+	var instantes []time.Time
+	var ts gocql.UUID
+	for iterador.Scan(&ts) {
+		instantes = append(instantes, ts.Time())
+	}
+	if err := iterador.Close(); err != nil {
+		return nil, err
+	}
+	return instantes, nil
+}
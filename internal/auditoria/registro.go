@@ -0,0 +1,100 @@
+package auditoria
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Escrita registrada pelas ferramentas de carga. A chave da linha no Cassandra é
+// (sensor_id, day_bucket, ts), com day_bucket e ts derivados do instante do evento.
+type RegistroDeEscrita struct {
+	Sensor       string    `json:"sensor_id"`
+	Instante     time.Time `json:"ts"`
+	Consistencia string    `json:"w"`
+	Origem       string    `json:"origem,omitempty"` // ferramenta que escreveu (go-stress, carga <subcomando>)
+	Confirmada   bool      `json:"confirmada"`
+	Erro         string    `json:"erro,omitempty"` // escrita não confirmada: pode ter sido aplicada (ex.: timeout)
+}
+
+// Grava os registros em JSONL; seguro para uso concorrente.
+type GravadorDeEscritas struct {
+	mu      sync.Mutex
+	arquivo *os.File
+	saida   *bufio.Writer
+	erro    error
+}
+
+func NovoGravadorDeEscritas(caminho string) (*GravadorDeEscritas, error) {
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return nil, err
+	}
+	return &GravadorDeEscritas{arquivo: arquivo, saida: bufio.NewWriter(arquivo)}, nil
+}
+
+func (g *GravadorDeEscritas) Registrar(registro RegistroDeEscrita) {
+	linha, err := json.Marshal(registro)
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err == nil {
+		linha = append(linha, '\n')
+		_, err = g.saida.Write(linha)
+	}
+	if err != nil && g.erro == nil {
+		g.erro = err
+	}
+}
+
+// Registra o resultado de uma escrita; err != nil marca a escrita como não confirmada.
+func (g *GravadorDeEscritas) RegistrarResultado(origem, sensor string, instante time.Time, consistencia string, err error) {
+	registro := RegistroDeEscrita{Sensor: sensor, Instante: instante.UTC(), Consistencia: consistencia, Origem: origem, Confirmada: err == nil}
+	if err != nil {
+		registro.Erro = err.Error()
+	}
+	g.Registrar(registro)
+}
+
+func (g *GravadorDeEscritas) Fechar() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.saida.Flush(); err != nil && g.erro == nil {
+		g.erro = err
+	}
+	if err := g.arquivo.Close(); err != nil && g.erro == nil {
+		g.erro = err
+	}
+	return g.erro
+}
+
+// Carrega e concatena os registros de um ou mais arquivos.
+func CarregarEscritas(caminhos ...string) ([]RegistroDeEscrita, error) {
+	var registros []RegistroDeEscrita
+	for _, caminho := range caminhos {
+		arquivo, err := os.Open(caminho)
+		if err != nil {
+			return nil, err
+		}
+		leitor := bufio.NewScanner(arquivo)
+		for linha := 1; leitor.Scan(); linha++ {
+			if len(leitor.Bytes()) == 0 {
+				continue
+			}
+			var registro RegistroDeEscrita
+			if err := json.Unmarshal(leitor.Bytes(), &registro); err != nil {
+				arquivo.Close()
+				return nil, fmt.Errorf("%s:%d: %w", caminho, linha, err)
+			}
+			registros = append(registros, registro)
+		}
+		err = leitor.Err()
+		arquivo.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", caminho, err)
+		}
+	}
+	return registros, nil
+}
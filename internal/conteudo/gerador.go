@@ -0,0 +1,236 @@
+package conteudo
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Parâmetros do conteúdo das leituras geradas pelas ferramentas de carga.
+type ParametrosDoConteudo struct {
+	QuantidadeDeEtiquetas string // etiquetas (tags) por leitura: "3", "5-30" (uniforme) ou "normal:15,5"
+	TamanhoDoValor        string // caracteres do valor de cada etiqueta, mesma sintaxe
+	Unidades              string // mistura ponderada: "C=60,%=30,hPa=10"
+	Estados               string // mistura ponderada de status: "0=97,1=2,2=1"
+}
+
+// Conteúdo comum aos formatos de escrita (JSON do ingestor e INSERT direto).
+type ConteudoDaLeitura struct {
+	UnidadeDeMedida     string
+	EstadoDaLeitura     int16
+	AtributosAdicionais map[string]string
+}
+
+type ResumoDoConteudo struct {
+	Linhas            int64   `json:"linhas"`
+	BytesPorLinha     float64 `json:"bytes_por_linha"`
+	MenorLinha        int     `json:"menor_linha_bytes"`
+	MaiorLinha        int     `json:"maior_linha_bytes"`
+	EtiquetasPorLinha float64 `json:"etiquetas_por_linha"`
+}
+
+// Gera unidade, status e etiquetas de cada escrita e acumula o tamanho das linhas. Seguro para uso concorrente.
+type GeradorDeConteudo struct {
+	mu        sync.Mutex
+	r         *rand.Rand
+	etiquetas distribuicaoDeTamanho
+	valor     distribuicaoDeTamanho
+	unidades  []opcaoPonderada[string]
+	estados   []opcaoPonderada[int16]
+
+	linhas, bytes, totalDeEtiquetas int64
+	menor, maior                    int
+}
+
+// Chaves das etiquetas, na ordem em que são usadas; além delas, "atributo_<n>".
+var chavesDeEtiquetas = []string{
+	"site", "tipo", "fabricante", "modelo", "firmware", "predio", "andar", "sala", "linha", "maquina",
+	"lote", "calibracao", "instalacao", "responsavel", "protocolo", "gateway", "rede", "zona", "setor", "ativo",
+}
+
+func NovoGeradorDeConteudo(p ParametrosDoConteudo, semente int64) (*GeradorDeConteudo, error) {
+	g := &GeradorDeConteudo{r: rand.New(rand.NewSource(semente))}
+	var err error
+	if g.etiquetas, err = parsearDistribuicaoDeTamanho(valorOu(p.QuantidadeDeEtiquetas, "3")); err != nil {
+		return nil, fmt.Errorf("quantidade de etiquetas: %w", err)
+	}
+	if g.valor, err = parsearDistribuicaoDeTamanho(valorOu(p.TamanhoDoValor, "8")); err != nil {
+		return nil, fmt.Errorf("tamanho do valor: %w", err)
+	}
+	if g.unidades, err = parsearOpcoesPonderadas(valorOu(p.Unidades, "C"), func(s string) (string, error) { return s, nil }); err != nil {
+		return nil, fmt.Errorf("unidades: %w", err)
+	}
+	g.estados, err = parsearOpcoesPonderadas(valorOu(p.Estados, "0"), func(s string) (int16, error) {
+		n, err := strconv.ParseInt(s, 10, 16)
+		return int16(n), err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("estados: %w", err)
+	}
+	return g, nil
+}
+
+// Gera o conteúdo de uma escrita do sensor e contabiliza o tamanho da linha.
+func (g *GeradorDeConteudo) Gerar(identificadorDoSensor string) ConteudoDaLeitura {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c := ConteudoDaLeitura{
+		UnidadeDeMedida: sortearOpcao(g.r, g.unidades),
+		EstadoDaLeitura: sortearOpcao(g.r, g.estados),
+	}
+	if quantidade := g.etiquetas.sortear(g.r); quantidade > 0 {
+		c.AtributosAdicionais = make(map[string]string, quantidade)
+		for i := 0; i < quantidade; i++ {
+			c.AtributosAdicionais[chaveDaEtiqueta(i)] = textoAleatorio(g.r, g.valor.sortear(g.r))
+		}
+	}
+	tamanho := TamanhoDaLinha(identificadorDoSensor, c)
+	if g.linhas == 0 || tamanho < g.menor {
+		g.menor = tamanho
+	}
+	if tamanho > g.maior {
+		g.maior = tamanho
+	}
+	g.linhas++
+	g.bytes += int64(tamanho)
+	g.totalDeEtiquetas += int64(len(c.AtributosAdicionais))
+	return c
+}
+
+func (g *GeradorDeConteudo) Resumo() ResumoDoConteudo {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	r := ResumoDoConteudo{Linhas: g.linhas, MenorLinha: g.menor, MaiorLinha: g.maior}
+	if g.linhas > 0 {
+		r.BytesPorLinha = float64(g.bytes) / float64(g.linhas)
+		r.EtiquetasPorLinha = float64(g.totalDeEtiquetas) / float64(g.linhas)
+	}
+	return r
+}
+
+func FormatarResumoDoConteudo(r ResumoDoConteudo) string {
+	return fmt.Sprintf(" conteudo: linhas=%d bytes_por_linha=%.1f menor=%d maior=%d etiquetas_por_linha=%.1f\n",
+		r.Linhas, r.BytesPorLinha, r.MenorLinha, r.MaiorLinha, r.EtiquetasPorLinha)
+}
+
+// Tamanho dos valores de uma linha de sensor_readings serializados no protocolo CQL v4:
+// sensor_id, day_bucket (date, 4), ts (timeuuid, 16), value (double, 8), unit, status (smallint, 2)
+// e tags (map: 4 bytes de contagem e, por entrada, 4 bytes de tamanho antes da chave e do valor).
+// Não inclui o overhead de armazenamento do Cassandra (timestamps de célula, índices, compressão).
+func TamanhoDaLinha(identificadorDoSensor string, c ConteudoDaLeitura) int {
+	tamanho := len(identificadorDoSensor) + 4 + 16 + 8 + len(c.UnidadeDeMedida) + 2 + 4
+	for chave, valor := range c.AtributosAdicionais {
+		tamanho += 4 + len(chave) + 4 + len(valor)
+	}
+	return tamanho
+}
+
+func chaveDaEtiqueta(i int) string {
+	if i < len(chavesDeEtiquetas) {
+		return chavesDeEtiquetas[i]
+	}
+	return fmt.Sprintf("atributo_%d", i)
+}
+
+const alfabeto = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_"
+
+func textoAleatorio(r *rand.Rand, tamanho int) string {
+	b := make([]byte, tamanho)
+	for i := range b {
+		b[i] = alfabeto[r.Intn(len(alfabeto))]
+	}
+	return string(b)
+}
+
+// Tamanho fixo, uniforme em [minimo, maximo] ou normal (arredondada, limitada a >= 0).
+type distribuicaoDeTamanho struct {
+	minimo, maximo int
+	normal         bool
+	media, desvio  float64
+}
+
+func (d distribuicaoDeTamanho) sortear(r *rand.Rand) int {
+	if d.normal {
+		return max(0, int(math.Round(r.NormFloat64()*d.desvio+d.media)))
+	}
+	if d.maximo == d.minimo {
+		return d.minimo
+	}
+	return d.minimo + r.Intn(d.maximo-d.minimo+1)
+}
+
+// Converte "12", "5-30" ou "normal:15,5".
+func parsearDistribuicaoDeTamanho(texto string) (distribuicaoDeTamanho, error) {
+	texto = strings.ToLower(strings.TrimSpace(texto))
+	if parametros, ok := strings.CutPrefix(texto, "normal:"); ok {
+		mediaTexto, desvioTexto, _ := strings.Cut(parametros, ",")
+		media, errMedia := strconv.ParseFloat(strings.TrimSpace(mediaTexto), 64)
+		desvio, errDesvio := strconv.ParseFloat(strings.TrimSpace(desvioTexto), 64)
+		if errMedia != nil || errDesvio != nil || media < 0 || desvio < 0 {
+			return distribuicaoDeTamanho{}, fmt.Errorf("distribuicao invalida %q (use normal:media,desvio)", texto)
+		}
+		return distribuicaoDeTamanho{normal: true, media: media, desvio: desvio}, nil
+	}
+	minimoTexto, maximoTexto, ehFaixa := strings.Cut(texto, "-")
+	minimo, err := strconv.Atoi(strings.TrimSpace(minimoTexto))
+	maximo := minimo
+	if err == nil && ehFaixa {
+		maximo, err = strconv.Atoi(strings.TrimSpace(maximoTexto))
+	}
+	if err != nil || minimo < 0 || maximo < minimo {
+		return distribuicaoDeTamanho{}, fmt.Errorf("distribuicao invalida %q (use n, minimo-maximo ou normal:media,desvio)", texto)
+	}
+	return distribuicaoDeTamanho{minimo: minimo, maximo: maximo}, nil
+}
+
+type opcaoPonderada[T any] struct {
+	valor     T
+	acumulado float64
+}
+
+// Converte "C=60,%=30,hPa=10" (sem "=peso", peso 1).
+func parsearOpcoesPonderadas[T any](texto string, converter func(string) (T, error)) ([]opcaoPonderada[T], error) {
+	var opcoes []opcaoPonderada[T]
+	soma := 0.0
+	for _, parte := range strings.Split(texto, ",") {
+		parte = strings.TrimSpace(parte)
+		if parte == "" {
+			continue
+		}
+		nome, pesoTexto, temPeso := strings.Cut(parte, "=")
+		peso := 1.0
+		if temPeso {
+			var err error
+			if peso, err = strconv.ParseFloat(strings.TrimSpace(pesoTexto), 64); err != nil || peso < 0 {
+				return nil, fmt.Errorf("peso invalido em %q", parte)
+			}
+		}
+		valor, err := converter(strings.TrimSpace(nome))
+		if err != nil {
+			return nil, fmt.Errorf("valor invalido em %q", parte)
+		}
+		soma += peso
+		opcoes = append(opcoes, opcaoPonderada[T]{valor: valor, acumulado: soma})
+	}
+	if soma == 0 {
+		return nil, fmt.Errorf("mistura sem pesos positivos: %q", texto)
+	}
+	return opcoes, nil
+}
+
+func sortearOpcao[T any](r *rand.Rand, opcoes []opcaoPonderada[T]) T {
+	alvo := r.Float64() * opcoes[len(opcoes)-1].acumulado
+	i := sort.Search(len(opcoes), func(i int) bool { return opcoes[i].acumulado > alvo })
+	return opcoes[min(i, len(opcoes)-1)].valor
+}
+
+func valorOu(texto, padrao string) string {
+	if strings.TrimSpace(texto) == "" {
+		return padrao
+	}
+	return texto
+}
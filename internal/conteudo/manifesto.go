@@ -0,0 +1,125 @@
+package conteudo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Partição (sensor_id, day_bucket) com as escritas confirmadas pelas ferramentas de carga.
+type ParticaoDoManifesto struct {
+	Sensor   string    `json:"sensor_id"`
+	Dia      string    `json:"dia"` // YYYY-MM-DD (day_bucket, UTC)
+	Linhas   int64     `json:"linhas"`
+	Primeiro time.Time `json:"primeiro"` // menor instante escrito
+	Ultimo   time.Time `json:"ultimo"`   // maior instante escrito
+}
+
+// Formato do arquivo de manifesto.
+type arquivoDeManifesto struct {
+	AtualizadoEm time.Time             `json:"atualizado_em"`
+	Particoes    []ParticaoDoManifesto `json:"particoes"`
+}
+
+// Partições que existem de fato, para as leituras da carga consultarem dados escritos. Seguro para uso concorrente.
+type ManifestoDeParticoes struct {
+	mu        sync.Mutex
+	particoes map[[2]string]*ParticaoDoManifesto
+}
+
+func NovoManifestoDeParticoes() *ManifestoDeParticoes {
+	return &ManifestoDeParticoes{particoes: map[[2]string]*ParticaoDoManifesto{}}
+}
+
+// Registra uma escrita confirmada.
+func (m *ManifestoDeParticoes) Registrar(sensor string, instante time.Time) {
+	instante = instante.UTC()
+	m.somar(ParticaoDoManifesto{Sensor: sensor, Dia: instante.Format("2006-01-02"), Linhas: 1, Primeiro: instante, Ultimo: instante})
+}
+
+func (m *ManifestoDeParticoes) somar(p ParticaoDoManifesto) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chave := [2]string{p.Sensor, p.Dia}
+	existente, ok := m.particoes[chave]
+	if !ok {
+		m.particoes[chave] = &p
+		return
+	}
+	existente.Linhas += p.Linhas
+	if p.Primeiro.Before(existente.Primeiro) {
+		existente.Primeiro = p.Primeiro
+	}
+	if p.Ultimo.After(existente.Ultimo) {
+		existente.Ultimo = p.Ultimo
+	}
+}
+
+// Partições ordenadas por sensor e dia.
+func (m *ManifestoDeParticoes) Particoes() []ParticaoDoManifesto {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	particoes := make([]ParticaoDoManifesto, 0, len(m.particoes))
+	for _, p := range m.particoes {
+		particoes = append(particoes, *p)
+	}
+	sort.Slice(particoes, func(i, j int) bool {
+		if particoes[i].Sensor != particoes[j].Sensor {
+			return particoes[i].Sensor < particoes[j].Sensor
+		}
+		return particoes[i].Dia < particoes[j].Dia
+	})
+	return particoes
+}
+
+func (m *ManifestoDeParticoes) Linhas() int64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total int64
+	for _, p := range m.particoes {
+		total += p.Linhas
+	}
+	return total
+}
+
+func (m *ManifestoDeParticoes) Gravar(caminho string) error {
+	conteudo, err := json.MarshalIndent(arquivoDeManifesto{AtualizadoEm: time.Now().UTC(), Particoes: m.Particoes()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(caminho, conteudo, 0o644)
+}
+
+// Soma os manifestos informados (execuções diferentes podem escrever na mesma partição).
+func CarregarManifesto(caminhos ...string) (*ManifestoDeParticoes, error) {
+	m := NovoManifestoDeParticoes()
+	for _, caminho := range caminhos {
+		if err := m.Mesclar(caminho); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Soma o manifesto gravado em caminho; arquivo inexistente é ignorado (primeira execução).
+func (m *ManifestoDeParticoes) Mesclar(caminho string) error {
+	conteudo, err := os.ReadFile(caminho)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var arquivo arquivoDeManifesto
+	if err := json.Unmarshal(conteudo, &arquivo); err != nil {
+		return fmt.Errorf("manifesto %s: %w", caminho, err)
+	}
+	for _, p := range arquivo.Particoes {
+		m.somar(p)
+	}
+	return nil
+}
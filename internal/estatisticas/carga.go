@@ -0,0 +1,279 @@
+package estatisticas
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mensagens de erro distintas guardadas no resumo; as demais somam em "outros".
+const maximoDeErrosDistintos = 20
+
+// Resultado de uma requisição feita por uma ferramenta de carga.
+type AmostraDeRequisicao struct {
+	Instante           time.Time     // início da requisição
+	Latencia           time.Duration // medida no cliente
+	LatenciaNoServidor time.Duration // duracao_ms devolvido pelo servidor; < 0 = ausente
+	Status             int           // 0 = sem resposta (erro de rede, timeout); < 0 = não HTTP (CQL)
+	Ok                 bool
+	Erro               string // erro de rede ou campo "erro" da resposta
+	Itens              int64  // leituras aceitas (escrita) ou devolvidas (consulta)
+	ItensComFalha      int64  // lote: leituras recusadas (quantidade_falha)
+}
+
+// Agrega as amostras no total e por segundo desde o início; seguro para uso concorrente.
+type ColetorDeRequisicoes struct {
+	mu        sync.Mutex
+	inicio    time.Time
+	total     segundoDaCarga
+	porStatus map[int]int64
+	porErro   map[string]int64
+	segundos  map[int]*segundoDaCarga
+}
+
+type segundoDaCarga struct {
+	total, ok, itens int64
+	itensComFalha    int64
+	cliente          *HistogramaDeLatencias // só requisições com sucesso
+	servidor         *HistogramaDeLatencias
+	diferenca        *HistogramaDeLatencias // cliente - servidor: rede, fila e serialização
+}
+
+func novoSegundoDaCarga() *segundoDaCarga {
+	return &segundoDaCarga{cliente: NovoHistogramaDeLatencias(), servidor: NovoHistogramaDeLatencias(), diferenca: NovoHistogramaDeLatencias()}
+}
+
+func (s *segundoDaCarga) registrar(a AmostraDeRequisicao) {
+	s.total++
+	s.itens += a.Itens // um lote com falhas parciais ainda tem itens aceitos
+	s.itensComFalha += a.ItensComFalha
+	if !a.Ok {
+		return
+	}
+	s.ok++
+	s.cliente.Registrar(a.Latencia)
+	if a.LatenciaNoServidor >= 0 {
+		s.servidor.Registrar(a.LatenciaNoServidor)
+		s.diferenca.Registrar(a.Latencia - a.LatenciaNoServidor)
+	}
+}
+
+func NovoColetorDeRequisicoes(inicio time.Time) *ColetorDeRequisicoes {
+	return &ColetorDeRequisicoes{inicio: inicio, total: *novoSegundoDaCarga(), porStatus: map[int]int64{},
+		porErro: map[string]int64{}, segundos: map[int]*segundoDaCarga{}}
+}
+
+func (c *ColetorDeRequisicoes) Registrar(a AmostraDeRequisicao) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total.registrar(a)
+	if a.Status >= 0 {
+		c.porStatus[a.Status]++
+	}
+	if a.Erro != "" {
+		erro := a.Erro
+		if len(erro) > 120 {
+			erro = erro[:120]
+		}
+		if _, conhecido := c.porErro[erro]; !conhecido && len(c.porErro) >= maximoDeErrosDistintos {
+			erro = "outros"
+		}
+		c.porErro[erro]++
+	}
+	i := int(a.Instante.Sub(c.inicio) / time.Second)
+	if i < 0 {
+		i = 0
+	}
+	s, ok := c.segundos[i]
+	if !ok {
+		s = novoSegundoDaCarga()
+		c.segundos[i] = s
+	}
+	s.registrar(a)
+}
+
+// Percentis em ms de um histograma.
+type PercentisEmMs struct {
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+	Media float64 `json:"media"`
+}
+
+func percentisEmMs(h *HistogramaDeLatencias) PercentisEmMs {
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	return PercentisEmMs{P50: ms(h.Percentil(50)), P95: ms(h.Percentil(95)), P99: ms(h.Percentil(99)), Max: ms(h.Maximo()), Media: ms(h.Media())}
+}
+
+type SegundoDoResumo struct {
+	Segundo   int           `json:"segundo"`
+	Total     int64         `json:"total"`
+	Ok        int64         `json:"ok"`
+	Itens     int64         `json:"itens"`
+	Cliente   PercentisEmMs `json:"cliente_ms"`
+	Servidor  PercentisEmMs `json:"servidor_ms"`
+	Diferenca PercentisEmMs `json:"diferenca_ms"`
+}
+
+type ResumoDaCarga struct {
+	Ferramenta    string            `json:"ferramenta"`
+	Duracao       time.Duration     `json:"duracao_ns"`
+	Total         int64             `json:"total"`
+	Ok            int64             `json:"ok"`
+	Erros         int64             `json:"erros"`
+	Itens         int64             `json:"itens"`
+	ItensFalha    int64             `json:"itens_com_falha,omitempty"`
+	VazaoOpsS     float64           `json:"vazao_ops_s"`
+	VazaoItens    float64           `json:"vazao_itens_s"`
+	BytesPorLinha float64           `json:"bytes_por_linha,omitempty"` // escritas: tamanho médio da linha gerada (conteudo.TamanhoDaLinha)
+	Cliente       PercentisEmMs     `json:"cliente_ms"`
+	Servidor      PercentisEmMs     `json:"servidor_ms"` // duracao_ms do servidor (resolução de 1ms)
+	Diferenca     PercentisEmMs     `json:"diferenca_ms"`
+	PorStatus     map[string]int64  `json:"por_status,omitempty"` // "0" = sem resposta
+	PorErro       map[string]int64  `json:"por_erro,omitempty"`
+	Serie         []SegundoDoResumo `json:"serie"`
+}
+
+func (c *ColetorDeRequisicoes) Resumo(ferramenta string, duracao time.Duration) ResumoDaCarga {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r := ResumoDaCarga{
+		Ferramenta: ferramenta, Duracao: duracao, Total: c.total.total, Ok: c.total.ok, Erros: c.total.total - c.total.ok,
+		Itens: c.total.itens, ItensFalha: c.total.itensComFalha, Cliente: percentisEmMs(c.total.cliente), Servidor: percentisEmMs(c.total.servidor),
+		Diferenca: percentisEmMs(c.total.diferenca), PorStatus: map[string]int64{}, PorErro: map[string]int64{},
+	}
+	if duracao > 0 {
+		r.VazaoOpsS = float64(r.Ok) / duracao.Seconds()
+		r.VazaoItens = float64(r.Itens) / duracao.Seconds()
+	}
+	for status, n := range c.porStatus {
+		r.PorStatus[strconv.Itoa(status)] = n
+	}
+	for erro, n := range c.porErro {
+		r.PorErro[erro] = n
+	}
+	indices := make([]int, 0, len(c.segundos))
+	for i := range c.segundos {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	for _, i := range indices {
+		s := c.segundos[i]
+		r.Serie = append(r.Serie, SegundoDoResumo{Segundo: i, Total: s.total, Ok: s.ok, Itens: s.itens,
+			Cliente: percentisEmMs(s.cliente), Servidor: percentisEmMs(s.servidor), Diferenca: percentisEmMs(s.diferenca)})
+	}
+	return r
+}
+
+// Grava em JSON (extensão .json) ou CSV: métricas "metric,valor" no arquivo pedido
+// e a série por segundo em <nome>-serie.csv.
+func GravarResumoDaCarga(caminho string, r ResumoDaCarga) error {
+	if strings.EqualFold(filepath.Ext(caminho), ".json") {
+		conteudo, err := json.MarshalIndent(r, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(caminho, conteudo, 0o644)
+	}
+	decimal := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	linhas := [][]string{
+		{"metric", "valor"},
+		{"total", strconv.FormatInt(r.Total, 10)},
+		{"ok", strconv.FormatInt(r.Ok, 10)},
+		{"erros", strconv.FormatInt(r.Erros, 10)},
+		{"itens", strconv.FormatInt(r.Itens, 10)},
+		{"itens_com_falha", strconv.FormatInt(r.ItensFalha, 10)},
+		{"duracao_ms", strconv.FormatInt(r.Duracao.Milliseconds(), 10)},
+		{"vazao_ops_s", decimal(r.VazaoOpsS)},
+		{"vazao_itens_s", decimal(r.VazaoItens)},
+	}
+	if r.BytesPorLinha > 0 {
+		linhas = append(linhas, []string{"bytes_por_linha", decimal(r.BytesPorLinha)})
+	}
+	for _, grupo := range []struct {
+		prefixo string
+		p       PercentisEmMs
+	}{{"cliente", r.Cliente}, {"servidor", r.Servidor}, {"diferenca", r.Diferenca}} {
+		linhas = append(linhas,
+			[]string{grupo.prefixo + "_p50_ms", decimal(grupo.p.P50)}, []string{grupo.prefixo + "_p95_ms", decimal(grupo.p.P95)},
+			[]string{grupo.prefixo + "_p99_ms", decimal(grupo.p.P99)}, []string{grupo.prefixo + "_max_ms", decimal(grupo.p.Max)},
+			[]string{grupo.prefixo + "_media_ms", decimal(grupo.p.Media)})
+	}
+	for _, status := range chavesOrdenadas(r.PorStatus) {
+		linhas = append(linhas, []string{"status_" + status, strconv.FormatInt(r.PorStatus[status], 10)})
+	}
+	for _, erro := range chavesOrdenadas(r.PorErro) {
+		linhas = append(linhas, []string{"erro:" + erro, strconv.FormatInt(r.PorErro[erro], 10)})
+	}
+	if err := gravarCSV(caminho, linhas); err != nil {
+		return err
+	}
+
+	serie := [][]string{{"segundo", "total", "ok", "itens", "p50_ms", "p95_ms", "p99_ms", "max_ms", "servidor_p50_ms", "servidor_p99_ms", "diferenca_p50_ms", "diferenca_p99_ms"}}
+	for _, s := range r.Serie {
+		serie = append(serie, []string{strconv.Itoa(s.Segundo), strconv.FormatInt(s.Total, 10), strconv.FormatInt(s.Ok, 10),
+			strconv.FormatInt(s.Itens, 10), decimal(s.Cliente.P50), decimal(s.Cliente.P95), decimal(s.Cliente.P99), decimal(s.Cliente.Max),
+			decimal(s.Servidor.P50), decimal(s.Servidor.P99), decimal(s.Diferenca.P50), decimal(s.Diferenca.P99)})
+	}
+	return gravarCSV(strings.TrimSuffix(caminho, filepath.Ext(caminho))+"-serie.csv", serie)
+}
+
+func gravarCSV(caminho string, linhas [][]string) error {
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return err
+	}
+	defer arquivo.Close()
+	w := csv.NewWriter(arquivo)
+	w.WriteAll(linhas)
+	return w.Error()
+}
+
+func chavesOrdenadas(m map[string]int64) []string {
+	chaves := make([]string, 0, len(m))
+	for k := range m {
+		chaves = append(chaves, k)
+	}
+	sort.Strings(chaves)
+	return chaves
+}
+
+// Linhas de resumo para o terminal (latências, status e erros mais frequentes).
+func FormatarResumoDaCarga(r ResumoDaCarga) string {
+	var b strings.Builder
+	if r.ItensFalha > 0 {
+		fmt.Fprintf(&b, "  vazao_ops_s=%.1f vazao_itens_s=%.1f erros=%d itens_com_falha=%d\n", r.VazaoOpsS, r.VazaoItens, r.Erros, r.ItensFalha)
+	} else if r.Itens > 0 {
+		fmt.Fprintf(&b, "  vazao_ops_s=%.1f vazao_itens_s=%.1f erros=%d\n", r.VazaoOpsS, r.VazaoItens, r.Erros)
+	} else {
+		fmt.Fprintf(&b, "  vazao_ops_s=%.1f erros=%d\n", r.VazaoOpsS, r.Erros)
+	}
+	fmt.Fprintf(&b, "  cliente_ms   p50=%.2f p95=%.2f p99=%.2f max=%.2f\n", r.Cliente.P50, r.Cliente.P95, r.Cliente.P99, r.Cliente.Max)
+	if len(r.PorStatus) > 0 { // alvo HTTP
+		fmt.Fprintf(&b, "  servidor_ms  p50=%.2f p95=%.2f p99=%.2f max=%.2f\n", r.Servidor.P50, r.Servidor.P95, r.Servidor.P99, r.Servidor.Max)
+		fmt.Fprintf(&b, "  diferenca_ms p50=%.2f p95=%.2f p99=%.2f max=%.2f\n", r.Diferenca.P50, r.Diferenca.P95, r.Diferenca.P99, r.Diferenca.Max)
+		partes := make([]string, 0, len(r.PorStatus))
+		for _, status := range chavesOrdenadas(r.PorStatus) {
+			partes = append(partes, fmt.Sprintf("%s=%d", status, r.PorStatus[status]))
+		}
+		fmt.Fprintf(&b, "  status{%s}\n", strings.Join(partes, " "))
+	}
+	erros := chavesOrdenadas(r.PorErro)
+	sort.SliceStable(erros, func(i, j int) bool { return r.PorErro[erros[i]] > r.PorErro[erros[j]] })
+	for i, erro := range erros {
+		if i == 5 {
+			fmt.Fprintf(&b, "  ... %d mensagens de erro distintas\n", len(erros))
+			break
+		}
+		fmt.Fprintf(&b, "  erro x%d: %s\n", r.PorErro[erro], erro)
+	}
+	return b.String()
+}
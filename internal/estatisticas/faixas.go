@@ -0,0 +1,66 @@
+package estatisticas
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Faixas de tamanho do resultado das consultas (quantidade de leituras devolvidas).
+var FaixasDeResultado = []struct {
+	Nome   string
+	Maximo int64
+}{{"0", 0}, {"1-9", 9}, {"10-99", 99}, {"100-999", 999}, {"1000+", 1<<63 - 1}}
+
+func FaixaDoResultado(itens int64) string {
+	for _, f := range FaixasDeResultado {
+		if itens <= f.Maximo {
+			return f.Nome
+		}
+	}
+	return FaixasDeResultado[len(FaixasDeResultado)-1].Nome
+}
+
+// Uma linha por faixa com consultas; ok é o total de consultas com sucesso da operação.
+func FormatarFaixasDeResultado(resumos map[string]ResumoDaCarga, ok int64) string {
+	var b strings.Builder
+	b.WriteString("  por faixa de resultado (consultas ok):\n")
+	for _, f := range FaixasDeResultado {
+		r, existe := resumos[f.Nome]
+		if !existe {
+			continue
+		}
+		fmt.Fprintf(&b, "   faixa=%-8s consultas=%d (%.1f%%) p50=%.2f p95=%.2f p99=%.2f max=%.2f servidor_p50=%.2f servidor_p99=%.2f\n",
+			f.Nome, r.Total, 100*float64(r.Total)/float64(max(ok, 1)), r.Cliente.P50, r.Cliente.P95, r.Cliente.P99, r.Cliente.Max,
+			r.Servidor.P50, r.Servidor.P99)
+	}
+	return b.String()
+}
+
+// <nome>-faixas.json com os resumos completos ou <nome>-faixas.csv com uma linha por faixa.
+func GravarFaixasDeResultado(caminho string, resumos map[string]ResumoDaCarga) error {
+	extensao := filepath.Ext(caminho)
+	destino := strings.TrimSuffix(caminho, extensao) + "-faixas" + extensao
+	if strings.EqualFold(extensao, ".json") {
+		conteudo, err := json.MarshalIndent(resumos, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(destino, conteudo, 0o644)
+	}
+	decimal := func(v float64) string { return strconv.FormatFloat(v, 'f', 3, 64) }
+	linhas := [][]string{{"faixa", "consultas", "itens", "p50_ms", "p95_ms", "p99_ms", "max_ms", "servidor_p50_ms", "servidor_p99_ms"}}
+	for _, f := range FaixasDeResultado {
+		r, existe := resumos[f.Nome]
+		if !existe {
+			continue
+		}
+		linhas = append(linhas, []string{f.Nome, strconv.FormatInt(r.Total, 10), strconv.FormatInt(r.Itens, 10),
+			decimal(r.Cliente.P50), decimal(r.Cliente.P95), decimal(r.Cliente.P99), decimal(r.Cliente.Max),
+			decimal(r.Servidor.P50), decimal(r.Servidor.P99)})
+	}
+	return gravarCSV(destino, linhas)
+}
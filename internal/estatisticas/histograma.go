@@ -0,0 +1,134 @@
+package estatisticas
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Buckets exponenciais de 10µs até ~2min com erro relativo de ~2% por bucket.
+const (
+	menorLatencia       = 10 * time.Microsecond
+	fatorDosBuckets     = 1.02
+	quantidadeDeBuckets = 830
+)
+
+var logDoFator = math.Log(fatorDosBuckets)
+
+// Histograma de latências de memória constante, seguro para uso concorrente.
+type HistogramaDeLatencias struct {
+	mu        sync.Mutex
+	contagens [quantidadeDeBuckets]int64
+	total     int64
+	soma      time.Duration
+	maximo    time.Duration
+}
+
+func NovoHistogramaDeLatencias() *HistogramaDeLatencias {
+	return &HistogramaDeLatencias{}
+}
+
+func indiceDoBucket(d time.Duration) int {
+	if d <= menorLatencia {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(d)/float64(menorLatencia)) / logDoFator))
+	if i >= quantidadeDeBuckets {
+		return quantidadeDeBuckets - 1
+	}
+	return i
+}
+
+func limiteSuperiorDoBucket(i int) time.Duration {
+	return time.Duration(float64(menorLatencia) * math.Pow(fatorDosBuckets, float64(i)))
+}
+
+func (h *HistogramaDeLatencias) Registrar(d time.Duration) {
+	if d < 0 {
+		d = 0
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.contagens[indiceDoBucket(d)]++
+	h.total++
+	h.soma += d
+	if d > h.maximo {
+		h.maximo = d
+	}
+}
+
+func (h *HistogramaDeLatencias) Quantidade() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.total
+}
+
+// Percentil p em [0,100]; retorna 0 para histograma vazio.
+func (h *HistogramaDeLatencias) Percentil(p float64) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return 0
+	}
+	alvo := int64(math.Ceil(p / 100 * float64(h.total)))
+	if alvo < 1 {
+		alvo = 1
+	}
+	var acumulado int64
+	for i, c := range h.contagens {
+		acumulado += c
+		if acumulado >= alvo {
+			if limite := limiteSuperiorDoBucket(i); limite < h.maximo {
+				return limite
+			}
+			return h.maximo
+		}
+	}
+	return h.maximo
+}
+
+func (h *HistogramaDeLatencias) Media() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.total == 0 {
+		return 0
+	}
+	return h.soma / time.Duration(h.total)
+}
+
+func (h *HistogramaDeLatencias) Maximo() time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.maximo
+}
+
+// Soma as amostras de outro histograma neste.
+func (h *HistogramaDeLatencias) Mesclar(outro *HistogramaDeLatencias) {
+	copia := outro.Copiar()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, c := range copia.contagens {
+		h.contagens[i] += c
+	}
+	h.total += copia.total
+	h.soma += copia.soma
+	if copia.maximo > h.maximo {
+		h.maximo = copia.maximo
+	}
+}
+
+func (h *HistogramaDeLatencias) Copiar() *HistogramaDeLatencias {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return &HistogramaDeLatencias{contagens: h.contagens, total: h.total, soma: h.soma, maximo: h.maximo}
+}
+
+// Retorna uma cópia do estado atual e zera o histograma (útil para janelas de medição).
+func (h *HistogramaDeLatencias) CopiarEZerar() *HistogramaDeLatencias {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	copia := &HistogramaDeLatencias{contagens: h.contagens, total: h.total, soma: h.soma, maximo: h.maximo}
+	h.contagens = [quantidadeDeBuckets]int64{}
+	h.total, h.soma, h.maximo = 0, 0, 0
+	return copia
+}
@@ -0,0 +1,492 @@
+package adaptadores
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+const (
+	socketDockerPadrao = "/var/run/docker.sock"
+	versaoDaApiDocker  = "v1.41"
+	rotuloServico      = "com.docker.compose.service"
+	rotuloProjeto      = "com.docker.compose.project"
+)
+
+var (
+	ErrContainerNaoEncontrado = errors.New("container nao encontrado")
+	ErrRedeNaoEncontrada      = errors.New("rede nao encontrada")
+	ErrImagemNaoEncontrada    = errors.New("imagem nao encontrada")
+	ErrAlvoAmbiguo            = errors.New("alvo corresponde a mais de um container")
+	ErrConflitoDeEstado       = errors.New("container em estado incompativel com a acao")
+	ErrComandoNoContainer     = errors.New("comando no container terminou com erro")
+)
+
+// Erro retornado pela Docker Engine API (status HTTP e mensagem do daemon).
+type ErroDaApiDocker struct {
+	Operacao      string
+	Status        int
+	Mensagem      string
+	NaoEncontrado error // recurso ausente no 404, definido pela operação (padrão: container)
+}
+
+func (e *ErroDaApiDocker) Error() string {
+	return fmt.Sprintf("docker api %s: status %d: %s", e.Operacao, e.Status, e.Mensagem)
+}
+
+func (e *ErroDaApiDocker) Unwrap() error {
+	switch e.Status {
+	case http.StatusNotFound:
+		if e.NaoEncontrado != nil {
+			return e.NaoEncontrado
+		}
+		return ErrContainerNaoEncontrado
+	case http.StatusConflict:
+		return ErrConflitoDeEstado
+	default:
+		return nil
+	}
+}
+
+// Comando (tc, iptables) executado no container com código de saída diferente de zero.
+type ErroDeComando struct {
+	Comando       []string
+	CodigoDeSaida int
+	Saida         string
+}
+
+func (e *ErroDeComando) Error() string {
+	return fmt.Sprintf("%v: codigo %d - %s", e.Comando, e.CodigoDeSaida, e.Saida)
+}
+
+func (e *ErroDeComando) Unwrap() error { return ErrComandoNoContainer }
+
+// Orquestrador que fala com a Docker Engine API pelo socket unix, sem depender do CLI.
+// Alvos: nome/ID do container, "servico:<nome>" (serviço do compose) ou "rotulo:k=v[,k=v]".
+type OrquestradorDeFalhasDockerAPI struct {
+	cliente *http.Client
+	base    string
+
+	ProjetoCompose       string // opcional: restringe "servico:" a um projeto do compose
+	ImagemAuxiliarDeRede string // como no adaptador CLI: executa tc/iptables num container auxiliar
+}
+
+func NovoOrquestradorDeFalhasDockerAPI(caminhoDoSocket string) *OrquestradorDeFalhasDockerAPI {
+	if caminhoDoSocket == "" {
+		caminhoDoSocket = socketDockerPadrao
+	}
+	transporte := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", caminhoDoSocket)
+		},
+	}
+	return &OrquestradorDeFalhasDockerAPI{
+		cliente: &http.Client{Transport: transporte},
+		base:    "http://docker/" + versaoDaApiDocker,
+	}
+}
+
+// Faz a chamada e decodifica a resposta JSON (se "resposta" != nil). Status fora de "aceitos" vira ErroDaApiDocker.
+func (o *OrquestradorDeFalhasDockerAPI) chamar(ctx context.Context, operacao, metodo, caminho string, consulta url.Values,
+	corpo any, resposta any, aceitos ...int) (int, error) {
+	var leitor io.Reader
+	if corpo != nil {
+		conteudo, err := json.Marshal(corpo)
+		if err != nil {
+			return 0, err
+		}
+		leitor = bytes.NewReader(conteudo)
+	}
+	endereco := o.base + caminho
+	if len(consulta) > 0 {
+		endereco += "?" + consulta.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, metodo, endereco, leitor)
+	if err != nil {
+		return 0, err
+	}
+	if corpo != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := o.cliente.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("docker api %s: %w", operacao, err)
+	}
+	defer resp.Body.Close()
+	dados, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, fmt.Errorf("docker api %s: %w", operacao, err)
+	}
+	for _, status := range aceitos {
+		if resp.StatusCode != status {
+			continue
+		}
+		if resposta != nil && len(dados) > 0 {
+			if err := json.Unmarshal(dados, resposta); err != nil {
+				return resp.StatusCode, fmt.Errorf("docker api %s: resposta invalida: %w", operacao, err)
+			}
+		}
+		return resp.StatusCode, nil
+	}
+	var mensagem struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(dados, &mensagem) != nil || mensagem.Message == "" {
+		mensagem.Message = strings.TrimSpace(string(dados))
+	}
+	return resp.StatusCode, &ErroDaApiDocker{Operacao: operacao, Status: resp.StatusCode, Mensagem: mensagem.Message}
+}
+
+// Atribui o 404 ao recurso da operação (rede, imagem); "No such container" continua sendo do container.
+func naoEncontrado(err error, recurso error) error {
+	var erroApi *ErroDaApiDocker
+	if errors.As(err, &erroApi) && erroApi.Status == http.StatusNotFound &&
+		!strings.Contains(strings.ToLower(erroApi.Mensagem), "no such container") {
+		erroApi.NaoEncontrado = recurso
+	}
+	return err
+}
+
+// Resolve o alvo da etapa para o ID do container.
+func (o *OrquestradorDeFalhasDockerAPI) resolverContainer(ctx context.Context, alvo string) (string, error) {
+	var rotulos []string
+	switch {
+	case strings.HasPrefix(alvo, "servico:"):
+		rotulos = append(rotulos, rotuloServico+"="+strings.TrimPrefix(alvo, "servico:"))
+		if o.ProjetoCompose != "" {
+			rotulos = append(rotulos, rotuloProjeto+"="+o.ProjetoCompose)
+		}
+	case strings.HasPrefix(alvo, "rotulo:"):
+		for _, r := range strings.Split(strings.TrimPrefix(alvo, "rotulo:"), ",") {
+			if r = strings.TrimSpace(r); r != "" {
+				rotulos = append(rotulos, r)
+			}
+		}
+	default:
+		return alvo, nil // nome ou ID: o daemon resolve
+	}
+	if len(rotulos) == 0 {
+		return "", fmt.Errorf("alvo %q sem rotulos", alvo)
+	}
+	filtros, err := json.Marshal(map[string][]string{"label": rotulos})
+	if err != nil {
+		return "", err
+	}
+	var containers []struct {
+		Id    string
+		Names []string
+	}
+	if _, err := o.chamar(ctx, "listar containers", http.MethodGet, "/containers/json",
+		url.Values{"all": {"1"}, "filters": {string(filtros)}}, nil, &containers, http.StatusOK); err != nil {
+		return "", err
+	}
+	switch len(containers) {
+	case 0:
+		return "", fmt.Errorf("%w: %s", ErrContainerNaoEncontrado, alvo)
+	case 1:
+		return containers[0].Id, nil
+	default:
+		nomes := make([]string, 0, len(containers))
+		for _, c := range containers {
+			nomes = append(nomes, strings.Join(c.Names, ","))
+		}
+		return "", fmt.Errorf("%w: %s -> %s", ErrAlvoAmbiguo, alvo, strings.Join(nomes, " "))
+	}
+}
+
+// Ação simples sobre o container (pause, stop, ...). 304 significa que já estava no estado desejado.
+func (o *OrquestradorDeFalhasDockerAPI) acaoNoContainer(ctx context.Context, alvo, acao string, consulta url.Values) error {
+	id, err := o.resolverContainer(ctx, alvo)
+	if err != nil {
+		return err
+	}
+	_, err = o.chamar(ctx, acao+" "+alvo, http.MethodPost, "/containers/"+url.PathEscape(id)+"/"+acao, consulta, nil, nil,
+		http.StatusNoContent, http.StatusNotModified, http.StatusOK)
+	return err
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) PausarNo(ctx context.Context, nomeDoContainer string) error {
+	return o.acaoNoContainer(ctx, nomeDoContainer, "pause", nil)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) ContinuarNo(ctx context.Context, nomeDoContainer string) error {
+	return o.acaoNoContainer(ctx, nomeDoContainer, "unpause", nil)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) PararNo(ctx context.Context, nomeDoContainer string, timeoutSegundos int) error {
+	if timeoutSegundos <= 0 {
+		timeoutSegundos = 10
+	}
+	return o.acaoNoContainer(ctx, nomeDoContainer, "stop", url.Values{"t": {strconv.Itoa(timeoutSegundos)}})
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) IniciarNo(ctx context.Context, nomeDoContainer string) error {
+	return o.acaoNoContainer(ctx, nomeDoContainer, "start", nil)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) MatarNo(ctx context.Context, nomeDoContainer string, sinal string) error {
+	if sinal == "" {
+		sinal = "SIGKILL"
+	}
+	return o.acaoNoContainer(ctx, nomeDoContainer, "kill", url.Values{"signal": {sinal}})
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) ReiniciarNo(ctx context.Context, nomeDoContainer string, timeoutSegundos int) error {
+	if timeoutSegundos <= 0 {
+		timeoutSegundos = 10
+	}
+	return o.acaoNoContainer(ctx, nomeDoContainer, "restart", url.Values{"t": {strconv.Itoa(timeoutSegundos)}})
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) DesconectarNoDaRede(ctx context.Context, nomeDoContainer string, nomeDaRede string, forcar bool) error {
+	if nomeDaRede == "" {
+		return errors.New("nome da rede obrigatorio")
+	}
+	id, err := o.resolverContainer(ctx, nomeDoContainer)
+	if err != nil {
+		return err
+	}
+	_, err = o.chamar(ctx, "desconectar "+nomeDoContainer, http.MethodPost, "/networks/"+url.PathEscape(nomeDaRede)+"/disconnect", nil,
+		map[string]any{"Container": id, "Force": forcar}, nil, http.StatusOK, http.StatusNoContent)
+	return naoEncontrado(err, ErrRedeNaoEncontrada)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) ReconectarNoARede(ctx context.Context, nomeDoContainer string, nomeDaRede string) error {
+	if nomeDaRede == "" {
+		return errors.New("nome da rede obrigatorio")
+	}
+	id, err := o.resolverContainer(ctx, nomeDoContainer)
+	if err != nil {
+		return err
+	}
+	// aguarda rede aparecer (robustez), como no adaptador CLI
+	prazo, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	var ultimoErro error
+	for prazo.Err() == nil {
+		_, ultimoErro = o.chamar(ctx, "reconectar "+nomeDoContainer, http.MethodPost, "/networks/"+url.PathEscape(nomeDaRede)+"/connect", nil,
+			map[string]any{"Container": id}, nil, http.StatusOK, http.StatusNoContent)
+		if ultimoErro = naoEncontrado(ultimoErro, ErrRedeNaoEncontrada); ultimoErro == nil {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return ultimoErro
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) VerificarRede(ctx context.Context, nomeDaRede string) error {
+	_, err := o.chamar(ctx, "inspecionar rede "+nomeDaRede, http.MethodGet, "/networks/"+url.PathEscape(nomeDaRede), nil, nil, nil, http.StatusOK)
+	return naoEncontrado(err, ErrRedeNaoEncontrada)
+}
+
+// Executa um comando de rede (tc, iptables) no namespace de rede do container, via exec ou container auxiliar.
+func (o *OrquestradorDeFalhasDockerAPI) executarNaRedeDoNo(ctx context.Context, nomeDoContainer string, comando ...string) (string, error) {
+	id, err := o.resolverContainer(ctx, nomeDoContainer)
+	if err != nil {
+		return "", err
+	}
+	if o.ImagemAuxiliarDeRede != "" {
+		return o.executarEmContainerAuxiliar(ctx, id, comando)
+	}
+	return o.executarNoContainer(ctx, id, comando, false)
+}
+
+// Exec no próprio container; em segundo plano só dispara o comando, sem saída nem código de retorno.
+func (o *OrquestradorDeFalhasDockerAPI) executarNoContainer(ctx context.Context, id string, comando []string, emSegundoPlano bool) (string, error) {
+	var criado struct{ Id string }
+	if _, err := o.chamar(ctx, "criar exec", http.MethodPost, "/containers/"+url.PathEscape(id)+"/exec", nil,
+		map[string]any{"Cmd": comando, "AttachStdout": !emSegundoPlano, "AttachStderr": !emSegundoPlano, "Tty": true}, &criado, http.StatusCreated, http.StatusOK); err != nil {
+		return "", err
+	}
+	saida, err := o.lerCorpo(ctx, "iniciar exec", http.MethodPost, "/exec/"+url.PathEscape(criado.Id)+"/start", map[string]any{"Detach": emSegundoPlano, "Tty": true})
+	if err != nil || emSegundoPlano {
+		return saida, err
+	}
+	var inspecao struct{ ExitCode int }
+	if _, err := o.chamar(ctx, "inspecionar exec", http.MethodGet, "/exec/"+url.PathEscape(criado.Id)+"/json", nil, nil, &inspecao, http.StatusOK); err != nil {
+		return saida, err
+	}
+	if inspecao.ExitCode != 0 {
+		return saida, &ErroDeComando{Comando: comando, CodigoDeSaida: inspecao.ExitCode, Saida: strings.TrimSpace(saida)}
+	}
+	return saida, nil
+}
+
+// Container efêmero no namespace de rede do alvo, com NET_ADMIN; removido ao final.
+// A imagem ausente é baixada uma vez antes de repetir a criação (como o "docker run" do adaptador CLI).
+func (o *OrquestradorDeFalhasDockerAPI) executarEmContainerAuxiliar(ctx context.Context, idDoAlvo string, comando []string) (string, error) {
+	var criado struct{ Id string }
+	criar := func() error {
+		_, err := o.chamar(ctx, "criar auxiliar", http.MethodPost, "/containers/create", nil, map[string]any{
+			"Image": o.ImagemAuxiliarDeRede,
+			"Cmd":   comando,
+			"Tty":   true,
+			"HostConfig": map[string]any{
+				"NetworkMode": "container:" + idDoAlvo,
+				"CapAdd":      []string{"NET_ADMIN"},
+			},
+		}, &criado, http.StatusCreated)
+		return naoEncontrado(err, ErrImagemNaoEncontrada)
+	}
+	err := criar()
+	if errors.Is(err, ErrImagemNaoEncontrada) {
+		if err = o.baixarImagem(ctx, o.ImagemAuxiliarDeRede); err == nil {
+			err = criar()
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	caminho := "/containers/" + url.PathEscape(criado.Id)
+	defer o.chamar(context.WithoutCancel(ctx), "remover auxiliar", http.MethodDelete, caminho, url.Values{"force": {"1"}}, nil, nil,
+		http.StatusNoContent, http.StatusNotFound)
+
+	if _, err := o.chamar(ctx, "iniciar auxiliar", http.MethodPost, caminho+"/start", nil, nil, nil, http.StatusNoContent, http.StatusNotModified); err != nil {
+		return "", err
+	}
+	var espera struct{ StatusCode int }
+	if _, err := o.chamar(ctx, "aguardar auxiliar", http.MethodPost, caminho+"/wait", nil, nil, &espera, http.StatusOK); err != nil {
+		return "", err
+	}
+	saida, err := o.lerCorpo(ctx, "logs auxiliar", http.MethodGet, caminho+"/logs?stdout=1&stderr=1", nil)
+	if err != nil {
+		return saida, err
+	}
+	if espera.StatusCode != 0 {
+		return saida, &ErroDeComando{Comando: comando, CodigoDeSaida: espera.StatusCode, Saida: strings.TrimSpace(saida)}
+	}
+	return saida, nil
+}
+
+// POST /images/create; o progresso vem em JSON por linha e a falha do pull aparece no próprio fluxo.
+func (o *OrquestradorDeFalhasDockerAPI) baixarImagem(ctx context.Context, imagem string) error {
+	consulta := url.Values{"fromImage": {imagem}}
+	if nome := imagem[strings.LastIndex(imagem, "/")+1:]; !strings.ContainsAny(nome, ":@") {
+		consulta.Set("tag", "latest") // sem tag o daemon baixaria todas
+	}
+	saida, err := o.lerCorpo(ctx, "baixar imagem "+imagem, http.MethodPost, "/images/create?"+consulta.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrImagemNaoEncontrada, imagem, err)
+	}
+	decodificador := json.NewDecoder(strings.NewReader(saida))
+	for {
+		var progresso struct {
+			Error string `json:"error"`
+		}
+		if decodificador.Decode(&progresso) != nil {
+			return nil
+		}
+		if progresso.Error != "" {
+			return fmt.Errorf("%w: %s: %s", ErrImagemNaoEncontrada, imagem, progresso.Error)
+		}
+	}
+}
+
+// Chamada cujo corpo de resposta é texto bruto (saída de exec/logs com TTY).
+func (o *OrquestradorDeFalhasDockerAPI) lerCorpo(ctx context.Context, operacao, metodo, caminho string, corpo any) (string, error) {
+	var leitor io.Reader
+	if corpo != nil {
+		conteudo, err := json.Marshal(corpo)
+		if err != nil {
+			return "", err
+		}
+		leitor = bytes.NewReader(conteudo)
+	}
+	req, err := http.NewRequestWithContext(ctx, metodo, o.base+caminho, leitor)
+	if err != nil {
+		return "", err
+	}
+	if corpo != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := o.cliente.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("docker api %s: %w", operacao, err)
+	}
+	defer resp.Body.Close()
+	dados, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("docker api %s: %w", operacao, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &ErroDaApiDocker{Operacao: operacao, Status: resp.StatusCode, Mensagem: strings.TrimSpace(string(dados))}
+	}
+	return string(dados), nil
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) AplicarDegradacaoDeRede(ctx context.Context, nomeDoContainer string, degradacao p.DegradacaoDeRede) error {
+	return aplicarNetem(ctx, o.executarNaRedeDoNo, nomeDoContainer, degradacao)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) RemoverDegradacaoDeRede(ctx context.Context, nomeDoContainer string, nomeDaInterface string) error {
+	return removerNetem(ctx, o.executarNaRedeDoNo, nomeDoContainer, nomeDaInterface)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) ipsDoContainer(ctx context.Context, nomeDoContainer string) ([]string, error) {
+	id, err := o.resolverContainer(ctx, nomeDoContainer)
+	if err != nil {
+		return nil, err
+	}
+	var inspecao struct {
+		NetworkSettings struct {
+			Networks map[string]struct{ IPAddress string }
+		}
+	}
+	if _, err := o.chamar(ctx, "inspecionar "+nomeDoContainer, http.MethodGet, "/containers/"+url.PathEscape(id)+"/json", nil, nil, &inspecao, http.StatusOK); err != nil {
+		return nil, err
+	}
+	var ips []string
+	for _, rede := range inspecao.NetworkSettings.Networks {
+		if rede.IPAddress != "" {
+			ips = append(ips, rede.IPAddress)
+		}
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("container %s sem IP (parado ou desconectado?)", nomeDoContainer)
+	}
+	return ips, nil
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) BloquearTrafegoEntreNos(ctx context.Context, nomeDoContainer string, nomeDoContainerRemoto string, direcao string) ([]string, error) {
+	ips, err := o.ipsDoContainer(ctx, nomeDoContainerRemoto)
+	if err != nil {
+		return nil, err
+	}
+	return ips, bloquearTrafego(ctx, o.executarNaRedeDoNo, nomeDoContainer, ips, direcao)
+}
+
+// Usa os IPs gravados no bloqueio: o remoto pode ter reiniciado com outro IP (ou estar parado).
+func (o *OrquestradorDeFalhasDockerAPI) DesbloquearTrafegoEntreNos(ctx context.Context, nomeDoContainer string, nomeDoContainerRemoto string, direcao string, ipsDoRemoto []string) error {
+	if len(ipsDoRemoto) == 0 {
+		ips, err := o.ipsDoContainer(ctx, nomeDoContainerRemoto)
+		if err != nil {
+			return err
+		}
+		ipsDoRemoto = ips
+	}
+	return desbloquearTrafego(ctx, o.executarNaRedeDoNo, nomeDoContainer, ipsDoRemoto, direcao)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) VerificarPrivilegiosDeRede(ctx context.Context, nomeDoContainer string, ferramenta string) error {
+	return verificarPrivilegiosDeRede(ctx, o.executarNaRedeDoNo, nomeDoContainer, ferramenta)
+}
+
+func (o *OrquestradorDeFalhasDockerAPI) ExecutarNodetool(ctx context.Context, nomeDoContainer string, emSegundoPlano bool, argumentos ...string) (string, error) {
+	id, err := o.resolverContainer(ctx, nomeDoContainer)
+	if err != nil {
+		return "", err
+	}
+	return o.executarNoContainer(ctx, id, append([]string{"nodetool"}, argumentos...), emSegundoPlano)
+}
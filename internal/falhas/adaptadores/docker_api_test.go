@@ -0,0 +1,315 @@
+package adaptadores
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Daemon Docker falso servido num socket unix temporário. Registra as chamadas recebidas.
+type daemonFalso struct {
+	mu       sync.Mutex
+	chamadas []string
+	corpos   map[string]string
+	rotas    map[string]http.HandlerFunc
+}
+
+func novoDaemonFalso(t *testing.T) (*daemonFalso, *OrquestradorDeFalhasDockerAPI) {
+	t.Helper()
+	d := &daemonFalso{corpos: map[string]string{}, rotas: map[string]http.HandlerFunc{}}
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	ouvinte, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	servidor := httptest.NewUnstartedServer(http.HandlerFunc(d.atender))
+	servidor.Listener = ouvinte
+	servidor.Start()
+	t.Cleanup(servidor.Close)
+	return d, NovoOrquestradorDeFalhasDockerAPI(socket)
+}
+
+func (d *daemonFalso) rota(metodoECaminho string, h http.HandlerFunc) { d.rotas[metodoECaminho] = h }
+
+func (d *daemonFalso) atender(w http.ResponseWriter, r *http.Request) {
+	caminho := strings.TrimPrefix(r.URL.Path, "/"+versaoDaApiDocker)
+	chave := r.Method + " " + caminho
+	corpo, _ := io.ReadAll(r.Body)
+	d.mu.Lock()
+	d.chamadas = append(d.chamadas, chave+"?"+r.URL.RawQuery)
+	d.corpos[chave] = string(corpo)
+	h, ok := d.rotas[chave]
+	d.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]string{"message": "No such container"})
+		return
+	}
+	h(w, r)
+}
+
+func (d *daemonFalso) registradas() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]string(nil), d.chamadas...)
+}
+
+func responder(status int, corpo any) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+		if corpo != nil {
+			json.NewEncoder(w).Encode(corpo)
+		}
+	}
+}
+
+func listaDeContainers(ids ...string) http.HandlerFunc {
+	var lista []map[string]any
+	for _, id := range ids {
+		lista = append(lista, map[string]any{"Id": id, "Names": []string{"/" + id}})
+	}
+	return responder(http.StatusOK, lista)
+}
+
+func TestPausarResolvePorServicoDoCompose(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	o.ProjetoCompose = "tcc"
+	var filtros string
+	d.rota("GET /containers/json", func(w http.ResponseWriter, r *http.Request) {
+		filtros = r.URL.Query().Get("filters")
+		listaDeContainers("abc123")(w, r)
+	})
+	d.rota("POST /containers/abc123/pause", responder(http.StatusNoContent, nil))
+
+	if err := o.PausarNo(context.Background(), "servico:cassandra2"); err != nil {
+		t.Fatalf("PausarNo: %v", err)
+	}
+	var f map[string][]string
+	if err := json.Unmarshal([]byte(filtros), &f); err != nil {
+		t.Fatalf("filtros invalidos %q: %v", filtros, err)
+	}
+	esperados := []string{"com.docker.compose.service=cassandra2", "com.docker.compose.project=tcc"}
+	if strings.Join(f["label"], ";") != strings.Join(esperados, ";") {
+		t.Fatalf("rotulos = %v, esperado %v", f["label"], esperados)
+	}
+}
+
+func TestResolucaoPorRotulo(t *testing.T) {
+	casos := []struct {
+		nome     string
+		ids      []string
+		esperado error
+	}{
+		{"unico", []string{"c1"}, nil},
+		{"nenhum", nil, ErrContainerNaoEncontrado},
+		{"ambiguo", []string{"c1", "c2"}, ErrAlvoAmbiguo},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			d, o := novoDaemonFalso(t)
+			d.rota("GET /containers/json", listaDeContainers(c.ids...))
+			d.rota("POST /containers/c1/start", responder(http.StatusNoContent, nil))
+			err := o.IniciarNo(context.Background(), "rotulo:papel=cassandra,dc=dc1")
+			if !errors.Is(err, c.esperado) {
+				t.Fatalf("erro = %v, esperado %v", err, c.esperado)
+			}
+		})
+	}
+}
+
+func TestErrosTipados(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	d.rota("POST /containers/cassandra2/unpause", responder(http.StatusConflict, map[string]string{"message": "Container is not paused"}))
+
+	err := o.ContinuarNo(context.Background(), "cassandra2")
+	if !errors.Is(err, ErrConflitoDeEstado) {
+		t.Fatalf("esperado ErrConflitoDeEstado, obtido %v", err)
+	}
+	var erroApi *ErroDaApiDocker
+	if !errors.As(err, &erroApi) || erroApi.Status != http.StatusConflict || erroApi.Mensagem != "Container is not paused" {
+		t.Fatalf("ErroDaApiDocker inesperado: %#v", erroApi)
+	}
+
+	if err := o.PausarNo(context.Background(), "inexistente"); !errors.Is(err, ErrContainerNaoEncontrado) {
+		t.Fatalf("esperado ErrContainerNaoEncontrado, obtido %v", err)
+	}
+}
+
+func TestPararEMatarEnviamParametros(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	d.rota("POST /containers/cassandra3/stop", responder(http.StatusNotModified, nil)) // já parado não é erro
+	d.rota("POST /containers/cassandra3/kill", responder(http.StatusNoContent, nil))
+
+	if err := o.PararNo(context.Background(), "cassandra3", 0); err != nil {
+		t.Fatalf("PararNo: %v", err)
+	}
+	if err := o.MatarNo(context.Background(), "cassandra3", ""); err != nil {
+		t.Fatalf("MatarNo: %v", err)
+	}
+	chamadas := d.registradas()
+	if chamadas[0] != "POST /containers/cassandra3/stop?t=10" || chamadas[1] != "POST /containers/cassandra3/kill?signal=SIGKILL" {
+		t.Fatalf("chamadas inesperadas: %v", chamadas)
+	}
+}
+
+func TestDesconectarDaRede(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	d.rota("POST /networks/tcc-net/disconnect", responder(http.StatusOK, nil))
+
+	if err := o.DesconectarNoDaRede(context.Background(), "cassandra2", "tcc-net", true); err != nil {
+		t.Fatalf("DesconectarNoDaRede: %v", err)
+	}
+	var corpo struct {
+		Container string
+		Force     bool
+	}
+	json.Unmarshal([]byte(d.corpos["POST /networks/tcc-net/disconnect"]), &corpo)
+	if corpo.Container != "cassandra2" || !corpo.Force {
+		t.Fatalf("corpo inesperado: %+v", corpo)
+	}
+}
+
+func TestNaoEncontradoPorOperacao(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	d.rota("POST /networks/rede-x/disconnect", responder(http.StatusNotFound, map[string]string{"message": "network rede-x not found"}))
+	d.rota("GET /networks/rede-y", responder(http.StatusNotFound, map[string]string{"message": "network rede-y not found"}))
+
+	err := o.DesconectarNoDaRede(context.Background(), "cassandra2", "rede-x", false)
+	if !errors.Is(err, ErrRedeNaoEncontrada) || errors.Is(err, ErrContainerNaoEncontrado) {
+		t.Fatalf("esperado ErrRedeNaoEncontrada, obtido %v", err)
+	}
+	if err := o.VerificarRede(context.Background(), "rede-y"); !errors.Is(err, ErrRedeNaoEncontrada) {
+		t.Fatalf("esperado ErrRedeNaoEncontrada, obtido %v", err)
+	}
+	// Container ausente na mesma rota continua sendo ErrContainerNaoEncontrado
+	if err := o.DesconectarNoDaRede(context.Background(), "cassandra9", "tcc-net", false); !errors.Is(err, ErrContainerNaoEncontrado) {
+		t.Fatalf("esperado ErrContainerNaoEncontrado, obtido %v", err)
+	}
+}
+
+func TestContainerAuxiliarBaixaImagemAusente(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	o.ImagemAuxiliarDeRede = "nicolaka/netshoot"
+	var baixada bool
+	d.rota("POST /containers/create", func(w http.ResponseWriter, r *http.Request) {
+		if !baixada {
+			responder(http.StatusNotFound, map[string]string{"message": "No such image: nicolaka/netshoot:latest"})(w, r)
+			return
+		}
+		responder(http.StatusCreated, map[string]string{"Id": "aux1"})(w, r)
+	})
+	d.rota("POST /images/create", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("fromImage") != "nicolaka/netshoot" || r.URL.Query().Get("tag") != "latest" {
+			t.Errorf("pull inesperado: %s", r.URL.RawQuery)
+		}
+		baixada = true
+		io.WriteString(w, `{"status":"Pulling from nicolaka/netshoot"}`+"\n"+`{"status":"Download complete"}`+"\n")
+	})
+	d.rota("POST /containers/aux1/start", responder(http.StatusNoContent, nil))
+	d.rota("POST /containers/aux1/wait", responder(http.StatusOK, map[string]int{"StatusCode": 0}))
+	d.rota("GET /containers/aux1/logs", responder(http.StatusOK, nil))
+	d.rota("DELETE /containers/aux1", responder(http.StatusNoContent, nil))
+
+	if err := o.AplicarDegradacaoDeRede(context.Background(), "cassandra1", p.DegradacaoDeRede{AtrasoMs: 10}); err != nil {
+		t.Fatalf("AplicarDegradacaoDeRede: %v", err)
+	}
+	if !baixada {
+		t.Fatalf("imagem nao foi baixada: %v", d.registradas())
+	}
+}
+
+func TestContainerAuxiliarComFalhaNoPull(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	o.ImagemAuxiliarDeRede = "imagem/inexistente:1.0"
+	d.rota("POST /containers/create", responder(http.StatusNotFound, map[string]string{"message": "No such image: imagem/inexistente:1.0"}))
+	d.rota("POST /images/create", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, `{"error":"pull access denied for imagem/inexistente"}`+"\n")
+	})
+
+	err := o.AplicarDegradacaoDeRede(context.Background(), "cassandra1", p.DegradacaoDeRede{AtrasoMs: 10})
+	if !errors.Is(err, ErrImagemNaoEncontrada) || !strings.Contains(err.Error(), "pull access denied") {
+		t.Fatalf("esperado ErrImagemNaoEncontrada com o erro do pull, obtido %v", err)
+	}
+}
+
+// Exec falso: registra o comando e devolve saída e código de saída configurados.
+func execFalso(d *daemonFalso, saida string, codigo int) *[][]string {
+	var comandos [][]string
+	d.rota("POST /containers/cassandra1/exec", func(w http.ResponseWriter, r *http.Request) {
+		var corpo struct{ Cmd []string }
+		d.mu.Lock()
+		json.Unmarshal([]byte(d.corpos["POST /containers/cassandra1/exec"]), &corpo)
+		comandos = append(comandos, corpo.Cmd)
+		d.mu.Unlock()
+		responder(http.StatusCreated, map[string]string{"Id": "exec1"})(w, r)
+	})
+	d.rota("POST /exec/exec1/start", func(w http.ResponseWriter, _ *http.Request) { io.WriteString(w, saida) })
+	d.rota("GET /exec/exec1/json", responder(http.StatusOK, map[string]int{"ExitCode": codigo}))
+	return &comandos
+}
+
+func TestDegradacaoDeRedeViaExec(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	comandos := execFalso(d, "", 0)
+
+	err := o.AplicarDegradacaoDeRede(context.Background(), "cassandra1", p.DegradacaoDeRede{AtrasoMs: 100, PerdaPercentual: 2.5})
+	if err != nil {
+		t.Fatalf("AplicarDegradacaoDeRede: %v", err)
+	}
+	esperado := "tc qdisc replace dev eth0 root netem delay 100ms loss 2.5%"
+	if len(*comandos) != 1 || strings.Join((*comandos)[0], " ") != esperado {
+		t.Fatalf("comandos = %v, esperado %q", *comandos, esperado)
+	}
+}
+
+func TestErroDeComandoNoContainer(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	execFalso(d, "RTNETLINK answers: Operation not permitted", 2)
+
+	err := o.AplicarDegradacaoDeRede(context.Background(), "cassandra1", p.DegradacaoDeRede{AtrasoMs: 10})
+	var erroCmd *ErroDeComando
+	if !errors.Is(err, ErrComandoNoContainer) || !errors.As(err, &erroCmd) || erroCmd.CodigoDeSaida != 2 {
+		t.Fatalf("esperado ErroDeComando com codigo 2, obtido %v", err)
+	}
+}
+
+func TestRemoverDegradacaoSemQdiscNaoEErro(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	execFalso(d, "Error: Cannot delete qdisc with handle of zero.", 2)
+
+	if err := o.RemoverDegradacaoDeRede(context.Background(), "cassandra1", ""); err != nil {
+		t.Fatalf("RemoverDegradacaoDeRede: %v", err)
+	}
+}
+
+func TestNodetoolEmSegundoPlanoNaoAguardaResultado(t *testing.T) {
+	d, o := novoDaemonFalso(t)
+	comandos := execFalso(d, "", 0)
+
+	if _, err := o.ExecutarNodetool(context.Background(), "cassandra1", true, "compact", "tcc"); err != nil {
+		t.Fatalf("ExecutarNodetool: %v", err)
+	}
+	if len(*comandos) != 1 || strings.Join((*comandos)[0], " ") != "nodetool compact tcc" {
+		t.Fatalf("comandos = %v", *comandos)
+	}
+	var inicio struct{ Detach bool }
+	json.Unmarshal([]byte(d.corpos["POST /exec/exec1/start"]), &inicio)
+	if !inicio.Detach {
+		t.Fatal("exec deveria ser iniciado com Detach")
+	}
+	for _, c := range d.registradas() {
+		if strings.HasPrefix(c, "GET /exec/") {
+			t.Fatalf("exec em segundo plano nao deveria ser inspecionado: %v", d.registradas())
+		}
+	}
+}
@@ -5,22 +5,43 @@ import (
 	"errors"
 	"fmt"
 	"os/exec"
+	"strings"
 	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
 )
 
-type OrquestradorDeFalhasDockerCLI struct{}
+type OrquestradorDeFalhasDockerCLI struct {
+	// Imagem com iproute2 (ex.: nicolaka/netshoot) executada no namespace de rede do alvo.
+	// Vazio = "docker exec" do tc no próprio container (exige tc instalado e NET_ADMIN).
+	ImagemAuxiliarDeRede string
+}
 
 func NovoOrquestradorDeFalhasDockerCLI() *OrquestradorDeFalhasDockerCLI {
 	return &OrquestradorDeFalhasDockerCLI{}
 }
 
 func (o *OrquestradorDeFalhasDockerCLI) run(ctx context.Context, args ...string) error {
+	_, err := o.executar(ctx, args...)
+	return err
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) executar(ctx context.Context, args ...string) (string, error) {
 	cmd := exec.CommandContext(ctx, "docker", args...)
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		return fmt.Errorf("docker %v: %w - %s", args, err, string(out))
+		return string(out), fmt.Errorf("docker %v: %w - %s", args, err, string(out))
 	}
-	return nil
+	return string(out), nil
+}
+
+// Executa um comando de rede (tc, iptables) no namespace de rede do container.
+func (o *OrquestradorDeFalhasDockerCLI) executarNaRedeDoNo(ctx context.Context, nomeDoContainer string, comando ...string) (string, error) {
+	if o.ImagemAuxiliarDeRede != "" {
+		args := append([]string{"run", "--rm", "--network", "container:" + nomeDoContainer, "--cap-add", "NET_ADMIN", o.ImagemAuxiliarDeRede}, comando...)
+		return o.executar(ctx, args...)
+	}
+	return o.executar(ctx, append([]string{"exec", nomeDoContainer}, comando...)...)
 }
 
 func (o *OrquestradorDeFalhasDockerCLI) PausarNo(ctx context.Context, nomeDoContainer string) error {
@@ -38,6 +59,24 @@ func (o *OrquestradorDeFalhasDockerCLI) PararNo(ctx context.Context, nomeDoConta
 	return o.run(ctx, "stop", "-t", fmt.Sprintf("%d", timeoutSegundos), nomeDoContainer)
 }
 
+func (o *OrquestradorDeFalhasDockerCLI) IniciarNo(ctx context.Context, nomeDoContainer string) error {
+	return o.run(ctx, "start", nomeDoContainer)
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) MatarNo(ctx context.Context, nomeDoContainer string, sinal string) error {
+	if sinal == "" {
+		sinal = "SIGKILL"
+	}
+	return o.run(ctx, "kill", "-s", sinal, nomeDoContainer)
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) ReiniciarNo(ctx context.Context, nomeDoContainer string, timeoutSegundos int) error {
+	if timeoutSegundos <= 0 {
+		timeoutSegundos = 10
+	}
+	return o.run(ctx, "restart", "-t", fmt.Sprintf("%d", timeoutSegundos), nomeDoContainer)
+}
+
 func (o *OrquestradorDeFalhasDockerCLI) DesconectarNoDaRede(ctx context.Context, nomeDoContainer string, nomeDaRede string, forcar bool) error {
 	if nomeDaRede == "" {
 		return errors.New("nome da rede obrigatorio")
@@ -66,3 +105,63 @@ func (o *OrquestradorDeFalhasDockerCLI) ReconectarNoARede(ctx context.Context, n
 	}
 	return ultimaErr
 }
+
+func (o *OrquestradorDeFalhasDockerCLI) VerificarRede(ctx context.Context, nomeDaRede string) error {
+	if _, err := o.executar(ctx, "network", "inspect", "-f", "{{.Name}}", nomeDaRede); err != nil {
+		return fmt.Errorf("rede %s nao encontrada: %w", nomeDaRede, err)
+	}
+	return nil
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) AplicarDegradacaoDeRede(ctx context.Context, nomeDoContainer string, degradacao p.DegradacaoDeRede) error {
+	return aplicarNetem(ctx, o.executarNaRedeDoNo, nomeDoContainer, degradacao)
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) RemoverDegradacaoDeRede(ctx context.Context, nomeDoContainer string, nomeDaInterface string) error {
+	return removerNetem(ctx, o.executarNaRedeDoNo, nomeDoContainer, nomeDaInterface)
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) ipsDoContainer(ctx context.Context, nomeDoContainer string) ([]string, error) {
+	saida, err := o.executar(ctx, "inspect", "-f", "{{range .NetworkSettings.Networks}}{{.IPAddress}} {{end}}", nomeDoContainer)
+	if err != nil {
+		return nil, err
+	}
+	ips := strings.Fields(saida)
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("container %s sem IP (parado ou desconectado?)", nomeDoContainer)
+	}
+	return ips, nil
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) BloquearTrafegoEntreNos(ctx context.Context, nomeDoContainer string, nomeDoContainerRemoto string, direcao string) ([]string, error) {
+	ips, err := o.ipsDoContainer(ctx, nomeDoContainerRemoto)
+	if err != nil {
+		return nil, err
+	}
+	return ips, bloquearTrafego(ctx, o.executarNaRedeDoNo, nomeDoContainer, ips, direcao)
+}
+
+// Usa os IPs gravados no bloqueio: o remoto pode ter reiniciado com outro IP (ou estar parado).
+func (o *OrquestradorDeFalhasDockerCLI) DesbloquearTrafegoEntreNos(ctx context.Context, nomeDoContainer string, nomeDoContainerRemoto string, direcao string, ipsDoRemoto []string) error {
+	if len(ipsDoRemoto) == 0 {
+		ips, err := o.ipsDoContainer(ctx, nomeDoContainerRemoto)
+		if err != nil {
+			return err
+		}
+		ipsDoRemoto = ips
+	}
+	return desbloquearTrafego(ctx, o.executarNaRedeDoNo, nomeDoContainer, ipsDoRemoto, direcao)
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) VerificarPrivilegiosDeRede(ctx context.Context, nomeDoContainer string, ferramenta string) error {
+	return verificarPrivilegiosDeRede(ctx, o.executarNaRedeDoNo, nomeDoContainer, ferramenta)
+}
+
+func (o *OrquestradorDeFalhasDockerCLI) ExecutarNodetool(ctx context.Context, nomeDoContainer string, emSegundoPlano bool, argumentos ...string) (string, error) {
+	args := []string{"exec"}
+	if emSegundoPlano {
+		args = append(args, "-d")
+	}
+	args = append(append(args, nomeDoContainer, "nodetool"), argumentos...)
+	return o.executar(ctx, args...)
+}
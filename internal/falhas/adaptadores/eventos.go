@@ -0,0 +1,107 @@
+package adaptadores
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Repassa cada evento a todos os registros (arquivo, métricas, Grafana, linha do tempo).
+type RegistrosDeEventos []p.PortaDeRegistroDeEventos
+
+func (r RegistrosDeEventos) RegistrarEvento(evento p.EventoDeEtapa) {
+	for _, registro := range r {
+		registro.RegistrarEvento(evento)
+	}
+}
+
+// Combina os registros de eventos pedidos (vazio quando nenhum foi configurado). O servidor de
+// métricas vem primeiro: porta ocupada falha antes de abrir o JSONL.
+func NovosRegistrosDeEventos(arquivo, metricas, grafanaURL, grafanaToken string, tags []string) (RegistrosDeEventos, func(), error) {
+	var registros RegistrosDeEventos
+	var fechamentos []func()
+	if metricas != "" {
+		if err := IniciarServidorDeMetricasDeFalhas(metricas); err != nil {
+			return nil, nil, err
+		}
+		registros = append(registros, NovoRegistroDeEventosPrometheus())
+	}
+	if arquivo != "" {
+		jsonl, err := NovoRegistroDeEventosJSONL(arquivo)
+		if err != nil {
+			return nil, nil, err
+		}
+		registros = append(registros, jsonl)
+		fechamentos = append(fechamentos, func() { jsonl.Fechar() })
+	}
+	if grafanaURL != "" {
+		grafana := NovoRegistroDeEventosGrafana(grafanaURL, grafanaToken, tags)
+		registros = append(registros, grafana)
+		fechamentos = append(fechamentos, grafana.Fechar)
+	}
+	return registros, func() {
+		for _, f := range fechamentos {
+			f()
+		}
+	}, nil
+}
+
+// Linha do arquivo JSONL: o evento com a duração e o atraso também em ms.
+type linhaDeEventoJSONL struct {
+	p.EventoDeEtapa
+	DuracaoMs float64 `json:"duracao_ms"`
+	AtrasoMs  float64 `json:"atraso_ms"` // momento real - momento planejado
+}
+
+// Um evento JSON por linha, em modo append (várias execuções no mesmo arquivo).
+type RegistroDeEventosJSONL struct {
+	mu      sync.Mutex
+	arquivo *os.File
+	codif   *json.Encoder
+}
+
+func NovoRegistroDeEventosJSONL(caminho string) (*RegistroDeEventosJSONL, error) {
+	arquivo, err := os.OpenFile(caminho, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &RegistroDeEventosJSONL{arquivo: arquivo, codif: json.NewEncoder(arquivo)}, nil
+}
+
+func (r *RegistroDeEventosJSONL) RegistrarEvento(evento p.EventoDeEtapa) {
+	linha := linhaDeEventoJSONL{
+		EventoDeEtapa: evento,
+		DuracaoMs:     float64(evento.Duracao) / float64(time.Millisecond),
+		AtrasoMs:      float64(evento.MomentoReal.Sub(evento.MomentoPlanejado)) / float64(time.Millisecond),
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.codif.Encode(linha); err != nil {
+		fmt.Printf("[eventos] erro ao gravar %s: %v\n", r.arquivo.Name(), err)
+	}
+}
+
+func (r *RegistroDeEventosJSONL) Fechar() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.arquivo.Close()
+}
+
+// Rótulos comuns às métricas e anotações de um evento.
+func origemDoEvento(evento p.EventoDeEtapa) string {
+	if evento.Reversao {
+		return "reversao"
+	}
+	return "plano"
+}
+
+func resultadoDoEvento(evento p.EventoDeEtapa) string {
+	if evento.Erro != "" {
+		return "erro"
+	}
+	return "ok"
+}
@@ -0,0 +1,125 @@
+package adaptadores
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Envia cada etapa como anotação pela API HTTP do Grafana (POST /api/annotations).
+// O envio é assíncrono para não atrasar o plano; Fechar aguarda as anotações pendentes.
+type RegistroDeEventosGrafana struct {
+	url     string
+	token   string   // token de service account; vazio = sem autenticação (ou usuário:senha na URL)
+	tags    []string // somadas às tags da etapa (ex.: nome do cenário)
+	cliente *http.Client
+	fila    chan p.EventoDeEtapa
+	espera  sync.WaitGroup
+}
+
+func NovoRegistroDeEventosGrafana(url, token string, tags []string) *RegistroDeEventosGrafana {
+	r := &RegistroDeEventosGrafana{
+		url:     strings.TrimSuffix(url, "/"),
+		token:   token,
+		tags:    tags,
+		cliente: &http.Client{Timeout: 5 * time.Second},
+		fila:    make(chan p.EventoDeEtapa, 256),
+	}
+	r.espera.Add(1)
+	go r.enviarFila()
+	return r
+}
+
+func (r *RegistroDeEventosGrafana) RegistrarEvento(evento p.EventoDeEtapa) {
+	select {
+	case r.fila <- evento:
+	default:
+		fmt.Printf("[grafana] fila cheia, anotacao descartada: %s %s\n", evento.Etapa.Acao, evento.Etapa.NomeDoContainer)
+	}
+}
+
+func (r *RegistroDeEventosGrafana) Fechar() {
+	close(r.fila)
+	r.espera.Wait()
+}
+
+func (r *RegistroDeEventosGrafana) enviarFila() {
+	defer r.espera.Done()
+	for evento := range r.fila {
+		if err := r.enviar(context.Background(), evento); err != nil {
+			fmt.Printf("[grafana] erro ao anotar %s %s: %v\n", evento.Etapa.Acao, evento.Etapa.NomeDoContainer, err)
+		}
+	}
+}
+
+// Corpo aceito por /api/annotations; sem dashboardUID a anotação é global (filtrada por tags).
+type anotacaoGrafana struct {
+	Time    int64    `json:"time"`
+	TimeEnd int64    `json:"timeEnd,omitempty"`
+	Tags    []string `json:"tags"`
+	Text    string   `json:"text"`
+}
+
+func (r *RegistroDeEventosGrafana) enviar(ctx context.Context, evento p.EventoDeEtapa) error {
+	corpo, err := json.Marshal(anotacaoDoEvento(evento, r.tags))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url+"/api/annotations", bytes.NewReader(corpo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+	resp, err := r.cliente.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		mensagem, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(mensagem)))
+	}
+	return nil
+}
+
+func anotacaoDoEvento(evento p.EventoDeEtapa, tagsExtras []string) anotacaoGrafana {
+	e := evento.Etapa
+	tags := append([]string{"falhas", e.Acao, e.NomeDoContainer, origemDoEvento(evento), resultadoDoEvento(evento)}, tagsExtras...)
+	texto := e.Acao + " " + e.NomeDoContainer
+	if e.NomeDoContainerRemoto != "" {
+		texto += " remoto=" + e.NomeDoContainerRemoto
+	}
+	if e.NomeDaRede != "" {
+		texto += " rede=" + e.NomeDaRede
+	}
+	if e.Condicao != "" {
+		texto += " ate=" + e.Condicao
+	}
+	if evento.TempoAteRecuperacao > 0 {
+		texto += " recuperacao=" + evento.TempoAteRecuperacao.Round(time.Millisecond).String()
+	}
+	if evento.Reversao {
+		texto += " (reversao)"
+	}
+	if evento.Erro != "" {
+		texto += " erro=" + evento.Erro
+	}
+	inicio := evento.MomentoReal.UnixMilli()
+	anotacao := anotacaoGrafana{Time: inicio, Tags: tags, Text: texto}
+	// Etapas longas (ex.: parar com timeout) viram anotações de intervalo
+	if fim := evento.MomentoReal.Add(evento.Duracao).UnixMilli(); fim-inicio >= 1000 {
+		anotacao.TimeEnd = fim
+	}
+	return anotacao
+}
@@ -0,0 +1,74 @@
+package adaptadores
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Métricas das etapas de falha. "falhas_etapa_momento_segundos" guarda o horário (unix) da última
+// execução de cada etapa e é usado pelo Grafana como anotação ("Series value as timestamp").
+type RegistroDeEventosPrometheus struct {
+	etapas  *prometheus.CounterVec
+	momento *prometheus.GaugeVec
+	duracao *prometheus.GaugeVec
+	atraso  *prometheus.GaugeVec
+	// Só etapas aguardar: tempo até o cluster convergir, por condição e container
+	recuperacao *prometheus.GaugeVec
+}
+
+func NovoRegistroDeEventosPrometheus() *RegistroDeEventosPrometheus {
+	rotulos := []string{"acao", "container", "remoto", "origem", "resultado"}
+	r := &RegistroDeEventosPrometheus{
+		etapas: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "falhas_etapas_total",
+			Help: "Etapas de falha executadas por acao, container, origem (plano|reversao) e resultado (ok|erro)",
+		}, rotulos),
+		momento: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "falhas_etapa_momento_segundos",
+			Help: "Horario unix da ultima execucao da etapa (usado como anotacao no Grafana)",
+		}, rotulos),
+		duracao: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "falhas_etapa_duracao_segundos",
+			Help: "Duracao da ultima execucao da etapa",
+		}, rotulos),
+		atraso: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "falhas_etapa_atraso_segundos",
+			Help: "Atraso da ultima execucao em relacao ao momento planejado",
+		}, rotulos),
+		recuperacao: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "falhas_tempo_ate_recuperacao_segundos",
+			Help: "Tempo da ultima falha ate a condicao de convergencia da etapa aguardar ser atendida",
+		}, []string{"condicao", "container"}),
+	}
+	prometheus.MustRegister(r.etapas, r.momento, r.duracao, r.atraso, r.recuperacao)
+	return r
+}
+
+func (r *RegistroDeEventosPrometheus) RegistrarEvento(evento p.EventoDeEtapa) {
+	valores := []string{evento.Etapa.Acao, evento.Etapa.NomeDoContainer, evento.Etapa.NomeDoContainerRemoto, origemDoEvento(evento), resultadoDoEvento(evento)}
+	r.etapas.WithLabelValues(valores...).Inc()
+	r.momento.WithLabelValues(valores...).Set(float64(evento.MomentoReal.UnixMilli()) / 1000)
+	r.duracao.WithLabelValues(valores...).Set(evento.Duracao.Seconds())
+	r.atraso.WithLabelValues(valores...).Set(evento.MomentoReal.Sub(evento.MomentoPlanejado).Seconds())
+	if evento.TempoAteRecuperacao > 0 {
+		r.recuperacao.WithLabelValues(evento.Etapa.Condicao, evento.Etapa.NomeDoContainer).Set(evento.TempoAteRecuperacao.Seconds())
+	}
+}
+
+// Expõe /metrics num servidor próprio (o injetor e o experimento não têm outro servidor HTTP).
+// A porta é reservada antes de retornar, para que endereço ocupado seja erro e não métricas perdidas.
+func IniciarServidorDeMetricasDeFalhas(endereco string) error {
+	ouvinte, err := net.Listen("tcp", endereco)
+	if err != nil {
+		return fmt.Errorf("servidor de metricas em %s: %w", endereco, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	go http.Serve(ouvinte, mux)
+	return nil
+}
@@ -0,0 +1,109 @@
+package adaptadores
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+func eventoDeExemplo() p.EventoDeEtapa {
+	planejado := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	return p.EventoDeEtapa{
+		Etapa:            p.EtapaDoPlano{Acao: "parar", NomeDoContainer: "cassandra2", TimeoutSegundos: 10},
+		MomentoPlanejado: planejado,
+		MomentoReal:      planejado.Add(250 * time.Millisecond),
+		Duracao:          3 * time.Second,
+		Erro:             "timeout",
+	}
+}
+
+func TestRegistroJSONLAcrescentaUmaLinhaPorEvento(t *testing.T) {
+	caminho := filepath.Join(t.TempDir(), "eventos.jsonl")
+	for i := 0; i < 2; i++ { // reabrir não sobrescreve
+		r, err := NovoRegistroDeEventosJSONL(caminho)
+		if err != nil {
+			t.Fatalf("NovoRegistroDeEventosJSONL: %v", err)
+		}
+		RegistrosDeEventos{r}.RegistrarEvento(eventoDeExemplo())
+		r.Fechar()
+	}
+
+	arquivo, _ := os.Open(caminho)
+	defer arquivo.Close()
+	var linhas []map[string]any
+	leitor := bufio.NewScanner(arquivo)
+	for leitor.Scan() {
+		var linha map[string]any
+		if err := json.Unmarshal(leitor.Bytes(), &linha); err != nil {
+			t.Fatalf("linha invalida %q: %v", leitor.Text(), err)
+		}
+		linhas = append(linhas, linha)
+	}
+	if len(linhas) != 2 {
+		t.Fatalf("linhas = %d, esperado 2", len(linhas))
+	}
+	if linhas[0]["duracao_ms"] != 3000.0 || linhas[0]["atraso_ms"] != 250.0 || linhas[0]["erro"] != "timeout" {
+		t.Fatalf("linha inesperada: %v", linhas[0])
+	}
+	if etapa := linhas[0]["etapa"].(map[string]any); etapa["acao"] != "parar" || etapa["container"] != "cassandra2" {
+		t.Fatalf("etapa inesperada: %v", etapa)
+	}
+}
+
+func TestRegistroGrafanaEnviaAnotacao(t *testing.T) {
+	var (
+		mu          sync.Mutex
+		recebidas   []anotacaoGrafana
+		autorizacao string
+	)
+	servidor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/annotations" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var a anotacaoGrafana
+		json.NewDecoder(r.Body).Decode(&a)
+		mu.Lock()
+		recebidas = append(recebidas, a)
+		autorizacao = r.Header.Get("Authorization")
+		mu.Unlock()
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer servidor.Close()
+
+	r := NovoRegistroDeEventosGrafana(servidor.URL+"/", "segredo", []string{"cenario1"})
+	evento := eventoDeExemplo()
+	r.RegistrarEvento(evento)
+	r.Fechar()
+
+	if len(recebidas) != 1 {
+		t.Fatalf("anotacoes = %d, esperado 1", len(recebidas))
+	}
+	a := recebidas[0]
+	if autorizacao != "Bearer segredo" {
+		t.Fatalf("Authorization = %q", autorizacao)
+	}
+	if a.Time != evento.MomentoReal.UnixMilli() || a.TimeEnd != evento.MomentoReal.Add(evento.Duracao).UnixMilli() {
+		t.Fatalf("intervalo inesperado: %+v", a)
+	}
+	esperadas := []string{"falhas", "parar", "cassandra2", "plano", "erro", "cenario1"}
+	if len(a.Tags) != len(esperadas) {
+		t.Fatalf("tags = %v, esperado %v", a.Tags, esperadas)
+	}
+	for i := range esperadas {
+		if a.Tags[i] != esperadas[i] {
+			t.Fatalf("tags = %v, esperado %v", a.Tags, esperadas)
+		}
+	}
+	if a.Text != "parar cassandra2 erro=timeout" {
+		t.Fatalf("texto = %q", a.Text)
+	}
+}
@@ -0,0 +1,130 @@
+package adaptadores
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Bit de CAP_NET_ADMIN em CapEff (/proc/self/status).
+const capNetAdmin = 12
+
+// Regras (sem -A/-D) que bloqueiam o tráfego com o IP remoto na direção pedida.
+func regrasDeBloqueio(ipRemoto, direcao string) ([][]string, error) {
+	saida := []string{"OUTPUT", "-d", ipRemoto, "-j", "DROP"}
+	entrada := []string{"INPUT", "-s", ipRemoto, "-j", "DROP"}
+	switch direcao {
+	case "", p.DirecaoAmbas:
+		return [][]string{saida, entrada}, nil
+	case p.DirecaoSaida:
+		return [][]string{saida}, nil
+	case p.DirecaoEntrada:
+		return [][]string{entrada}, nil
+	default:
+		return nil, fmt.Errorf("direcao invalida: %s", direcao)
+	}
+}
+
+// "iptables -D" de regra inexistente não é erro na remoção.
+func regraInexistente(saida string) bool {
+	return strings.Contains(saida, "Bad rule") || strings.Contains(saida, "does a matching rule exist")
+}
+
+// Interpreta CapEff de /proc/self/status e indica se NET_ADMIN está presente.
+func possuiNetAdmin(status string) (bool, error) {
+	for _, linha := range strings.Split(status, "\n") {
+		if !strings.HasPrefix(linha, "CapEff:") {
+			continue
+		}
+		mascara, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(linha, "CapEff:")), 16, 64)
+		if err != nil {
+			return false, fmt.Errorf("CapEff invalido: %w", err)
+		}
+		return mascara&(1<<capNetAdmin) != 0, nil
+	}
+	return false, fmt.Errorf("CapEff ausente em /proc/self/status")
+}
+
+func comandoDeVersao(ferramenta string) ([]string, error) {
+	switch ferramenta {
+	case "tc":
+		return []string{"tc", "-V"}, nil
+	case "iptables":
+		return []string{"iptables", "--version"}, nil
+	default:
+		return nil, fmt.Errorf("ferramenta desconhecida: %s", ferramenta)
+	}
+}
+
+// Em caso de falha remove as regras já inseridas: um bloqueio parcial não fica registrado como
+// falha pendente e, portanto, não seria revertido ao concluir.
+func bloquearTrafego(ctx context.Context, executar executorNaRede, nomeDoContainer string, ipsRemotos []string, direcao string) error {
+	var inseridas [][]string
+	for _, ip := range ipsRemotos {
+		regras, err := regrasDeBloqueio(ip, direcao)
+		if err != nil {
+			return errors.Join(err, removerRegras(ctx, executar, nomeDoContainer, inseridas))
+		}
+		for _, regra := range regras {
+			// Remove antes de inserir para não duplicar a regra em repetições
+			executar(ctx, nomeDoContainer, append([]string{"iptables", "-D"}, regra...)...)
+			if _, err := executar(ctx, nomeDoContainer, append([]string{"iptables", "-I"}, regra...)...); err != nil {
+				return errors.Join(err, removerRegras(ctx, executar, nomeDoContainer, inseridas))
+			}
+			inseridas = append(inseridas, regra)
+		}
+	}
+	return nil
+}
+
+// Desfaz um bloqueio parcial, da última regra inserida para a primeira.
+func removerRegras(ctx context.Context, executar executorNaRede, nomeDoContainer string, regras [][]string) error {
+	var erros []error
+	for i := len(regras) - 1; i >= 0; i-- {
+		if saida, err := executar(ctx, nomeDoContainer, append([]string{"iptables", "-D"}, regras[i]...)...); err != nil && !regraInexistente(saida) {
+			erros = append(erros, fmt.Errorf("desfazer bloqueio parcial %v: %w", regras[i], err))
+		}
+	}
+	return errors.Join(erros...)
+}
+
+func desbloquearTrafego(ctx context.Context, executar executorNaRede, nomeDoContainer string, ipsRemotos []string, direcao string) error {
+	for _, ip := range ipsRemotos {
+		regras, err := regrasDeBloqueio(ip, direcao)
+		if err != nil {
+			return err
+		}
+		for _, regra := range regras {
+			if saida, err := executar(ctx, nomeDoContainer, append([]string{"iptables", "-D"}, regra...)...); err != nil && !regraInexistente(saida) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func verificarPrivilegiosDeRede(ctx context.Context, executar executorNaRede, nomeDoContainer string, ferramenta string) error {
+	versao, err := comandoDeVersao(ferramenta)
+	if err != nil {
+		return err
+	}
+	status, err := executar(ctx, nomeDoContainer, "cat", "/proc/self/status")
+	if err != nil {
+		return fmt.Errorf("container %s: nao foi possivel ler as capabilities: %w", nomeDoContainer, err)
+	}
+	ok, err := possuiNetAdmin(status)
+	if err != nil {
+		return fmt.Errorf("container %s: %w", nomeDoContainer, err)
+	}
+	if !ok {
+		return fmt.Errorf("container %s sem NET_ADMIN (adicione cap_add: NET_ADMIN ou use uma imagem auxiliar de rede)", nomeDoContainer)
+	}
+	if _, err := executar(ctx, nomeDoContainer, versao...); err != nil {
+		return fmt.Errorf("container %s: %s indisponivel: %w", nomeDoContainer, ferramenta, err)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package adaptadores
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Executor que registra os comandos iptables e falha na n-ésima inserção (-I).
+type executorDeIptablesFalho struct {
+	falharNaInsercao int
+	insercoes        int
+	ativas           map[string]bool
+}
+
+func (e *executorDeIptablesFalho) executar(_ context.Context, _ string, comando ...string) (string, error) {
+	regra := strings.Join(comando[2:], " ")
+	switch comando[1] {
+	case "-I":
+		e.insercoes++
+		if e.insercoes == e.falharNaInsercao {
+			return "iptables: Resource temporarily unavailable.", errors.New("exit status 4")
+		}
+		e.ativas[regra] = true
+	case "-D":
+		if !e.ativas[regra] {
+			return "iptables: Bad rule (does a matching rule exist in that chain?).", errors.New("exit status 1")
+		}
+		delete(e.ativas, regra)
+	}
+	return "", nil
+}
+
+func TestBloquearTrafegoDesfazBloqueioParcial(t *testing.T) {
+	ips := []string{"172.18.0.3", "172.18.0.4"}
+	casos := []struct {
+		nome             string
+		direcao          string
+		falharNaInsercao int
+		ativasAoFinal    int
+	}{
+		{"sem falha", "ambas", 0, 4},
+		{"falha na primeira insercao", "ambas", 1, 0},
+		{"falha na direcao de entrada", "ambas", 2, 0},
+		{"falha no segundo ip", "ambas", 3, 0},
+		{"falha na ultima insercao", "saida", 2, 0},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			executor := &executorDeIptablesFalho{falharNaInsercao: c.falharNaInsercao, ativas: map[string]bool{}}
+			err := bloquearTrafego(context.Background(), executor.executar, "cassandra1", ips, c.direcao)
+			if (err != nil) != (c.falharNaInsercao > 0) {
+				t.Fatalf("err = %v", err)
+			}
+			if len(executor.ativas) != c.ativasAoFinal {
+				t.Fatalf("regras ativas = %v, esperado %d", executor.ativas, c.ativasAoFinal)
+			}
+		})
+	}
+}
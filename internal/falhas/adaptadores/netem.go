@@ -0,0 +1,64 @@
+package adaptadores
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Executa um comando no namespace de rede do container; a saída combinada é devolvida também em erro.
+type executorNaRede func(ctx context.Context, nomeDoContainer string, comando ...string) (string, error)
+
+func aplicarNetem(ctx context.Context, executar executorNaRede, nomeDoContainer string, degradacao p.DegradacaoDeRede) error {
+	_, err := executar(ctx, nomeDoContainer, argumentosNetem(degradacao)...)
+	return err
+}
+
+func removerNetem(ctx context.Context, executar executorNaRede, nomeDoContainer string, nomeDaInterface string) error {
+	saida, err := executar(ctx, nomeDoContainer, "tc", "qdisc", "del", "dev", interfaceOuPadrao(nomeDaInterface), "root")
+	if err != nil && semQdiscConfigurada(saida) {
+		return nil // nada a remover
+	}
+	return err
+}
+
+func interfaceOuPadrao(nome string) string {
+	if nome == "" {
+		return "eth0"
+	}
+	return nome
+}
+
+// Monta "tc qdisc replace dev <if> root netem ..." (replace é idempotente).
+func argumentosNetem(d p.DegradacaoDeRede) []string {
+	args := []string{"tc", "qdisc", "replace", "dev", interfaceOuPadrao(d.Interface), "root", "netem"}
+	if d.AtrasoMs > 0 {
+		args = append(args, "delay", strconv.Itoa(d.AtrasoMs)+"ms")
+		if d.VariacaoMs > 0 {
+			args = append(args, strconv.Itoa(d.VariacaoMs)+"ms", "distribution", "normal")
+		}
+	}
+	if d.PerdaPercentual > 0 {
+		args = append(args, "loss", percentual(d.PerdaPercentual))
+	}
+	if d.DuplicacaoPercentual > 0 {
+		args = append(args, "duplicate", percentual(d.DuplicacaoPercentual))
+	}
+	if d.ReordenacaoPercentual > 0 {
+		args = append(args, "reorder", percentual(d.ReordenacaoPercentual))
+	}
+	if d.LimiteDeBandaKbit > 0 {
+		args = append(args, "rate", strconv.Itoa(d.LimiteDeBandaKbit)+"kbit")
+	}
+	return args
+}
+
+func percentual(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) + "%" }
+
+// "tc qdisc del" falha quando não há qdisc raiz configurada; isso não é erro na remoção.
+func semQdiscConfigurada(saida string) bool {
+	return strings.Contains(saida, "Cannot delete qdisc with handle of zero") ||
+		strings.Contains(saida, "No such file or directory")
+}
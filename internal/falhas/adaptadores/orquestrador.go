@@ -0,0 +1,33 @@
+package adaptadores
+
+import (
+	"fmt"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Orquestrador escolhido por nome (flag -orquestrador do injetor e do experimento): cli, api ou processos.
+func NovoOrquestradorDeFalhas(tipo, socket, projeto, imagemDeRede, inventario string) (p.PortaDeOrquestracaoDeFalhas, error) {
+	switch tipo {
+	case "cli":
+		orq := NovoOrquestradorDeFalhasDockerCLI()
+		orq.ImagemAuxiliarDeRede = imagemDeRede
+		return orq, nil
+	case "api":
+		orq := NovoOrquestradorDeFalhasDockerAPI(socket)
+		orq.ProjetoCompose = projeto
+		orq.ImagemAuxiliarDeRede = imagemDeRede
+		return orq, nil
+	case "processos":
+		if inventario == "" {
+			return nil, fmt.Errorf("orquestrador processos exige -inventario")
+		}
+		nos, err := CarregarInventarioDeProcessos(inventario)
+		if err != nil {
+			return nil, err
+		}
+		return NovoOrquestradorDeFalhasProcessos(nos)
+	default:
+		return nil, fmt.Errorf("orquestrador invalido: %s (use cli, api ou processos)", tipo)
+	}
+}
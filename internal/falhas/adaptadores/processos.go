@@ -0,0 +1,319 @@
+package adaptadores
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrOperacaoNaoSuportada = errors.New("operacao nao suportada por este orquestrador")
+	ErrNoNaoEncontrado      = errors.New("no nao encontrado no inventario")
+	ErrNoNaoEstaExecutando  = errors.New("processo do no nao esta em execucao")
+)
+
+// Nó controlado como processo local (Cassandra em VM ou cluster estilo ccm).
+// O PID vem, nesta ordem, do processo iniciado pelo orquestrador, do arquivo de PID ou do PID fixo.
+type NoDeProcesso struct {
+	Nome         string   `yaml:"nome" json:"nome"`
+	ArquivoDePid string   `yaml:"arquivo_pid,omitempty" json:"arquivo_pid,omitempty"`
+	Pid          int      `yaml:"pid,omitempty" json:"pid,omitempty"`
+	Comando      []string `yaml:"comando,omitempty" json:"comando,omitempty"` // usado por iniciar/reiniciar
+	Diretorio    string   `yaml:"diretorio,omitempty" json:"diretorio,omitempty"`
+	Ambiente     []string `yaml:"ambiente,omitempty" json:"ambiente,omitempty"` // KEY=valor, somados ao ambiente atual
+	Nodetool     []string `yaml:"nodetool,omitempty" json:"nodetool,omitempty"` // padrão [nodetool]; ex.: [ccm, node1, nodetool] ou [nodetool, -p, "7200"]
+}
+
+type InventarioDeProcessos struct {
+	Nos []NoDeProcesso `yaml:"nos" json:"nos"`
+}
+
+func CarregarInventarioDeProcessos(caminho string) (InventarioDeProcessos, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return InventarioDeProcessos{}, err
+	}
+	var inventario InventarioDeProcessos
+	if err := yaml.Unmarshal(conteudo, &inventario); err != nil {
+		return InventarioDeProcessos{}, fmt.Errorf("inventario %s invalido: %w", caminho, err)
+	}
+	// Caminhos aceitam variáveis de ambiente (ex.: $HOME/.ccm/...)
+	for i := range inventario.Nos {
+		inventario.Nos[i].ArquivoDePid = os.ExpandEnv(inventario.Nos[i].ArquivoDePid)
+		inventario.Nos[i].Diretorio = os.ExpandEnv(inventario.Nos[i].Diretorio)
+	}
+	return inventario, nil
+}
+
+// Processo filho iniciado pelo orquestrador; "terminou" fecha quando o processo é colhido.
+type processoIniciado struct {
+	cmd      *exec.Cmd
+	terminou chan struct{}
+}
+
+type OrquestradorDeFalhasProcessos struct {
+	mu        sync.Mutex
+	nos       map[string]NoDeProcesso
+	iniciados map[string]*processoIniciado
+}
+
+func NovoOrquestradorDeFalhasProcessos(inventario InventarioDeProcessos) (*OrquestradorDeFalhasProcessos, error) {
+	o := &OrquestradorDeFalhasProcessos{nos: map[string]NoDeProcesso{}, iniciados: map[string]*processoIniciado{}}
+	for i, no := range inventario.Nos {
+		if strings.TrimSpace(no.Nome) == "" {
+			return nil, fmt.Errorf("no %d do inventario sem nome", i+1)
+		}
+		if _, repetido := o.nos[no.Nome]; repetido {
+			return nil, fmt.Errorf("no %s repetido no inventario", no.Nome)
+		}
+		if no.ArquivoDePid == "" && no.Pid <= 0 && len(no.Comando) == 0 {
+			return nil, fmt.Errorf("no %s sem arquivo_pid, pid ou comando", no.Nome)
+		}
+		o.nos[no.Nome] = no
+	}
+	return o, nil
+}
+
+// PID atual do nó e se o sinal deve ir ao grupo de processos (processos iniciados pelo orquestrador).
+func (o *OrquestradorDeFalhasProcessos) PidDoNo(nome string) (int, bool, error) {
+	o.mu.Lock()
+	no, ok := o.nos[nome]
+	iniciado := o.iniciados[nome]
+	o.mu.Unlock()
+	if !ok {
+		return 0, false, fmt.Errorf("%w: %s", ErrNoNaoEncontrado, nome)
+	}
+	if iniciado != nil {
+		select {
+		case <-iniciado.terminou:
+		default:
+			return iniciado.cmd.Process.Pid, true, nil
+		}
+	}
+	if no.ArquivoDePid != "" {
+		conteudo, err := os.ReadFile(no.ArquivoDePid)
+		if err == nil {
+			pid, errPid := strconv.Atoi(strings.TrimSpace(string(conteudo)))
+			if errPid != nil {
+				return 0, false, fmt.Errorf("arquivo de pid %s invalido: %w", no.ArquivoDePid, errPid)
+			}
+			if processoVivo(pid) {
+				return pid, false, nil
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return 0, false, err
+		}
+	}
+	if no.Pid > 0 && processoVivo(no.Pid) {
+		return no.Pid, false, nil
+	}
+	return 0, false, fmt.Errorf("%w: %s", ErrNoNaoEstaExecutando, nome)
+}
+
+func (o *OrquestradorDeFalhasProcessos) sinalizar(nome, sinal string) error {
+	pid, grupo, err := o.PidDoNo(nome)
+	if err != nil {
+		return err
+	}
+	return enviarSinal(pid, grupo, sinal)
+}
+
+func (o *OrquestradorDeFalhasProcessos) PausarNo(_ context.Context, nomeDoContainer string) error {
+	return o.sinalizar(nomeDoContainer, "SIGSTOP")
+}
+
+func (o *OrquestradorDeFalhasProcessos) ContinuarNo(_ context.Context, nomeDoContainer string) error {
+	return o.sinalizar(nomeDoContainer, "SIGCONT")
+}
+
+func (o *OrquestradorDeFalhasProcessos) MatarNo(ctx context.Context, nomeDoContainer string, sinal string) error {
+	if sinal == "" {
+		sinal = "SIGKILL"
+	}
+	pid, grupo, err := o.PidDoNo(nomeDoContainer)
+	if err != nil {
+		return err
+	}
+	if err := enviarSinal(pid, grupo, sinal); err != nil {
+		return err
+	}
+	if normalizarSinal(sinal) == "SIGKILL" {
+		o.aguardarTermino(ctx, nomeDoContainer, pid, 5*time.Second)
+	}
+	return nil
+}
+
+// SIGTERM (com SIGCONT, caso esteja pausado) e, se não terminar no prazo, SIGKILL.
+func (o *OrquestradorDeFalhasProcessos) PararNo(ctx context.Context, nomeDoContainer string, timeoutSegundos int) error {
+	if timeoutSegundos <= 0 {
+		timeoutSegundos = 10
+	}
+	pid, grupo, err := o.PidDoNo(nomeDoContainer)
+	if errors.Is(err, ErrNoNaoEstaExecutando) {
+		return nil // já parado
+	}
+	if err != nil {
+		return err
+	}
+	if err := enviarSinal(pid, grupo, "SIGTERM"); err != nil {
+		return err
+	}
+	enviarSinal(pid, grupo, "SIGCONT")
+	if o.aguardarTermino(ctx, nomeDoContainer, pid, time.Duration(timeoutSegundos)*time.Second) {
+		return nil
+	}
+	if err := enviarSinal(pid, grupo, "SIGKILL"); err != nil {
+		return err
+	}
+	if !o.aguardarTermino(ctx, nomeDoContainer, pid, 5*time.Second) {
+		return fmt.Errorf("processo %d do no %s nao terminou apos SIGKILL", pid, nomeDoContainer)
+	}
+	return nil
+}
+
+func (o *OrquestradorDeFalhasProcessos) IniciarNo(_ context.Context, nomeDoContainer string) error {
+	if _, _, err := o.PidDoNo(nomeDoContainer); err == nil {
+		return nil // já em execução
+	} else if !errors.Is(err, ErrNoNaoEstaExecutando) {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	no := o.nos[nomeDoContainer]
+	if len(no.Comando) == 0 {
+		return fmt.Errorf("no %s sem comando para iniciar", nomeDoContainer)
+	}
+	// Sem CommandContext: o nó deve sobreviver ao fim do plano
+	cmd := exec.Command(no.Comando[0], no.Comando[1:]...)
+	cmd.Dir = no.Diretorio
+	cmd.Env = append(os.Environ(), no.Ambiente...)
+	configurarGrupoDeProcesso(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("iniciar no %s: %w", nomeDoContainer, err)
+	}
+	iniciado := &processoIniciado{cmd: cmd, terminou: make(chan struct{})}
+	go func() {
+		cmd.Wait()
+		close(iniciado.terminou)
+	}()
+	o.iniciados[nomeDoContainer] = iniciado
+	return nil
+}
+
+func (o *OrquestradorDeFalhasProcessos) ReiniciarNo(ctx context.Context, nomeDoContainer string, timeoutSegundos int) error {
+	if err := o.PararNo(ctx, nomeDoContainer, timeoutSegundos); err != nil {
+		return err
+	}
+	return o.IniciarNo(ctx, nomeDoContainer)
+}
+
+// Espera o processo terminar; para filhos próprios usa o Wait (processo zumbi ainda responde a sinais).
+func (o *OrquestradorDeFalhasProcessos) aguardarTermino(ctx context.Context, nome string, pid int, prazo time.Duration) bool {
+	o.mu.Lock()
+	iniciado := o.iniciados[nome]
+	o.mu.Unlock()
+	limite := time.NewTimer(prazo)
+	defer limite.Stop()
+	if iniciado != nil && iniciado.cmd.Process.Pid == pid {
+		select {
+		case <-iniciado.terminou:
+			return true
+		case <-limite.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+	tique := time.NewTicker(100 * time.Millisecond)
+	defer tique.Stop()
+	for processoVivo(pid) {
+		select {
+		case <-limite.C:
+			return false
+		case <-ctx.Done():
+			return false
+		case <-tique.C:
+		}
+	}
+	return true
+}
+
+func (o *OrquestradorDeFalhasProcessos) DesconectarNoDaRede(context.Context, string, string, bool) error {
+	return fmt.Errorf("desconectar: %w", ErrOperacaoNaoSuportada)
+}
+
+func (o *OrquestradorDeFalhasProcessos) ReconectarNoARede(context.Context, string, string) error {
+	return fmt.Errorf("reconectar: %w", ErrOperacaoNaoSuportada)
+}
+
+func (o *OrquestradorDeFalhasProcessos) AplicarDegradacaoDeRede(context.Context, string, p.DegradacaoDeRede) error {
+	return fmt.Errorf("degradar_rede: %w", ErrOperacaoNaoSuportada)
+}
+
+func (o *OrquestradorDeFalhasProcessos) RemoverDegradacaoDeRede(context.Context, string, string) error {
+	return fmt.Errorf("limpar_rede: %w", ErrOperacaoNaoSuportada)
+}
+
+func (o *OrquestradorDeFalhasProcessos) BloquearTrafegoEntreNos(context.Context, string, string, string) ([]string, error) {
+	return nil, fmt.Errorf("bloquear_trafego: %w", ErrOperacaoNaoSuportada)
+}
+
+func (o *OrquestradorDeFalhasProcessos) DesbloquearTrafegoEntreNos(context.Context, string, string, string, []string) error {
+	return fmt.Errorf("desbloquear_trafego: %w", ErrOperacaoNaoSuportada)
+}
+
+func (o *OrquestradorDeFalhasProcessos) VerificarPrivilegiosDeRede(context.Context, string, string) error {
+	return fmt.Errorf("falhas de rede: %w", ErrOperacaoNaoSuportada)
+}
+
+func (o *OrquestradorDeFalhasProcessos) ExecutarNodetool(ctx context.Context, nomeDoContainer string, emSegundoPlano bool, argumentos ...string) (string, error) {
+	o.mu.Lock()
+	no, ok := o.nos[nomeDoContainer]
+	o.mu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoNaoEncontrado, nomeDoContainer)
+	}
+	comando := no.Nodetool
+	if len(comando) == 0 {
+		comando = []string{"nodetool"}
+	}
+	comando = append(append([]string(nil), comando...), argumentos...)
+	if emSegundoPlano {
+		cmd := exec.Command(comando[0], comando[1:]...)
+		cmd.Dir = no.Diretorio
+		cmd.Env = append(os.Environ(), no.Ambiente...)
+		if err := cmd.Start(); err != nil {
+			return "", fmt.Errorf("%v: %w", comando, err)
+		}
+		go cmd.Wait()
+		return "", nil
+	}
+	cmd := exec.CommandContext(ctx, comando[0], comando[1:]...)
+	cmd.Dir = no.Diretorio
+	cmd.Env = append(os.Environ(), no.Ambiente...)
+	saida, err := cmd.CombinedOutput()
+	if err != nil {
+		return string(saida), fmt.Errorf("%v: %w - %s", comando, err, strings.TrimSpace(string(saida)))
+	}
+	return string(saida), nil
+}
+
+// Aceita "SIGKILL", "KILL", "kill" ou o número ("9").
+func normalizarSinal(sinal string) string {
+	sinal = strings.ToUpper(strings.TrimSpace(sinal))
+	if sinal != "" && !strings.HasPrefix(sinal, "SIG") {
+		if _, err := strconv.Atoi(sinal); err != nil {
+			sinal = "SIG" + sinal
+		}
+	}
+	return sinal
+}
@@ -0,0 +1,178 @@
+//go:build unix
+
+package adaptadores
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func novoOrquestradorDeTeste(t *testing.T, nos ...NoDeProcesso) *OrquestradorDeFalhasProcessos {
+	t.Helper()
+	o, err := NovoOrquestradorDeFalhasProcessos(InventarioDeProcessos{Nos: nos})
+	if err != nil {
+		t.Fatalf("NovoOrquestradorDeFalhasProcessos: %v", err)
+	}
+	t.Cleanup(func() {
+		for _, no := range nos {
+			if len(no.Comando) > 0 { // só processos iniciados pelo próprio teste
+				o.MatarNo(context.Background(), no.Nome, "SIGKILL")
+			}
+		}
+	})
+	return o
+}
+
+// Estado do processo segundo o ps ("T" = parado por sinal).
+func estadoDoProcesso(t *testing.T, pid int) string {
+	t.Helper()
+	saida, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		t.Fatalf("ps -p %d: %v", pid, err)
+	}
+	return strings.TrimSpace(string(saida))
+}
+
+func pidObrigatorio(t *testing.T, o *OrquestradorDeFalhasProcessos, nome string) int {
+	t.Helper()
+	pid, _, err := o.PidDoNo(nome)
+	if err != nil {
+		t.Fatalf("PidDoNo(%s): %v", nome, err)
+	}
+	return pid
+}
+
+func TestPausarEContinuarProcesso(t *testing.T) {
+	ctx := context.Background()
+	o := novoOrquestradorDeTeste(t, NoDeProcesso{Nome: "no1", Comando: []string{"sleep", "60"}})
+	if err := o.IniciarNo(ctx, "no1"); err != nil {
+		t.Fatalf("IniciarNo: %v", err)
+	}
+	pid := pidObrigatorio(t, o, "no1")
+
+	if err := o.PausarNo(ctx, "no1"); err != nil {
+		t.Fatalf("PausarNo: %v", err)
+	}
+	if estado := estadoDoProcesso(t, pid); !strings.HasPrefix(estado, "T") {
+		t.Fatalf("estado apos SIGSTOP = %q, esperado T", estado)
+	}
+	if err := o.ContinuarNo(ctx, "no1"); err != nil {
+		t.Fatalf("ContinuarNo: %v", err)
+	}
+	if estado := estadoDoProcesso(t, pid); strings.HasPrefix(estado, "T") {
+		t.Fatalf("estado apos SIGCONT = %q, processo ainda parado", estado)
+	}
+}
+
+func TestPararEscalonaParaSigkill(t *testing.T) {
+	ctx := context.Background()
+	// O shell ignora SIGTERM; o sleep filho está no mesmo grupo e também recebe o SIGKILL
+	o := novoOrquestradorDeTeste(t, NoDeProcesso{Nome: "teimoso", Comando: []string{"sh", "-c", "trap '' TERM; sleep 60 & wait"}})
+	if err := o.IniciarNo(ctx, "teimoso"); err != nil {
+		t.Fatalf("IniciarNo: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond) // tempo para o trap ser instalado
+
+	inicio := time.Now()
+	if err := o.PararNo(ctx, "teimoso", 1); err != nil {
+		t.Fatalf("PararNo: %v", err)
+	}
+	if decorrido := time.Since(inicio); decorrido < time.Second {
+		t.Fatalf("parou em %v, esperado aguardar o timeout antes do SIGKILL", decorrido)
+	}
+	if _, _, err := o.PidDoNo("teimoso"); !errors.Is(err, ErrNoNaoEstaExecutando) {
+		t.Fatalf("esperado ErrNoNaoEstaExecutando, obtido %v", err)
+	}
+	// Parar um nó já parado não é erro
+	if err := o.PararNo(ctx, "teimoso", 1); err != nil {
+		t.Fatalf("PararNo repetido: %v", err)
+	}
+}
+
+func TestMatarEReiniciarProcesso(t *testing.T) {
+	ctx := context.Background()
+	o := novoOrquestradorDeTeste(t, NoDeProcesso{Nome: "no2", Comando: []string{"sleep", "60"}})
+	if err := o.IniciarNo(ctx, "no2"); err != nil {
+		t.Fatalf("IniciarNo: %v", err)
+	}
+	primeiro := pidObrigatorio(t, o, "no2")
+
+	if err := o.ReiniciarNo(ctx, "no2", 2); err != nil {
+		t.Fatalf("ReiniciarNo: %v", err)
+	}
+	segundo := pidObrigatorio(t, o, "no2")
+	if segundo == primeiro {
+		t.Fatalf("pid nao mudou apos reiniciar: %d", segundo)
+	}
+
+	if err := o.MatarNo(ctx, "no2", "kill"); err != nil {
+		t.Fatalf("MatarNo: %v", err)
+	}
+	if _, _, err := o.PidDoNo("no2"); !errors.Is(err, ErrNoNaoEstaExecutando) {
+		t.Fatalf("esperado ErrNoNaoEstaExecutando, obtido %v", err)
+	}
+	if err := o.MatarNo(ctx, "no2", "SIGKILL"); !errors.Is(err, ErrNoNaoEstaExecutando) {
+		t.Fatalf("matar no parado: esperado ErrNoNaoEstaExecutando, obtido %v", err)
+	}
+}
+
+func TestNoPorArquivoDePid(t *testing.T) {
+	ctx := context.Background()
+	externo := exec.Command("sleep", "60")
+	if err := externo.Start(); err != nil {
+		t.Fatalf("iniciar sleep: %v", err)
+	}
+	terminou := make(chan struct{})
+	go func() { externo.Wait(); close(terminou) }()
+	t.Cleanup(func() { externo.Process.Kill() })
+
+	arquivo := filepath.Join(t.TempDir(), "cassandra.pid")
+	os.WriteFile(arquivo, []byte(strconv.Itoa(externo.Process.Pid)+"\n"), 0o644)
+	o := novoOrquestradorDeTeste(t, NoDeProcesso{Nome: "externo", ArquivoDePid: arquivo})
+
+	if pid := pidObrigatorio(t, o, "externo"); pid != externo.Process.Pid {
+		t.Fatalf("pid = %d, esperado %d", pid, externo.Process.Pid)
+	}
+	if err := o.MatarNo(ctx, "externo", "SIGTERM"); err != nil {
+		t.Fatalf("MatarNo: %v", err)
+	}
+	select {
+	case <-terminou:
+	case <-time.After(5 * time.Second):
+		t.Fatal("processo externo nao terminou apos SIGTERM")
+	}
+	if err := o.IniciarNo(ctx, "externo"); err == nil {
+		t.Fatal("esperado erro ao iniciar no sem comando")
+	}
+}
+
+func TestOperacoesNaoSuportadasEInventario(t *testing.T) {
+	o := novoOrquestradorDeTeste(t, NoDeProcesso{Nome: "no3", Pid: os.Getpid()})
+	ctx := context.Background()
+	if _, err := o.BloquearTrafegoEntreNos(ctx, "no3", "no4", "ambas"); !errors.Is(err, ErrOperacaoNaoSuportada) {
+		t.Fatalf("esperado ErrOperacaoNaoSuportada, obtido %v", err)
+	}
+	if err := o.PausarNo(ctx, "desconhecido"); !errors.Is(err, ErrNoNaoEncontrado) {
+		t.Fatalf("esperado ErrNoNaoEncontrado, obtido %v", err)
+	}
+	if err := o.MatarNo(ctx, "no3", "SIGNADA"); err == nil || !strings.Contains(err.Error(), "sinal desconhecido") {
+		t.Fatalf("esperado erro de sinal desconhecido, obtido %v", err)
+	}
+
+	arquivo := filepath.Join(t.TempDir(), "inventario.yaml")
+	os.WriteFile(arquivo, []byte("nos:\n  - nome: a\n    comando: [sleep, '1']\n  - nome: a\n    pid: 10\n"), 0o644)
+	inventario, err := CarregarInventarioDeProcessos(arquivo)
+	if err != nil {
+		t.Fatalf("CarregarInventarioDeProcessos: %v", err)
+	}
+	if _, err := NovoOrquestradorDeFalhasProcessos(inventario); err == nil || !strings.Contains(err.Error(), "repetido") {
+		t.Fatalf("esperado erro de no repetido, obtido %v", err)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package adaptadores
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// Sinais POSIX indisponíveis: o orquestrador de processos só funciona em sistemas unix.
+func enviarSinal(int, bool, string) error {
+	return fmt.Errorf("sinais: %w", ErrOperacaoNaoSuportada)
+}
+
+func processoVivo(int) bool { return false }
+
+func configurarGrupoDeProcesso(*exec.Cmd) {}
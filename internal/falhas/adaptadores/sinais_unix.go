@@ -0,0 +1,59 @@
+//go:build unix
+
+package adaptadores
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"syscall"
+)
+
+var sinaisPorNome = map[string]syscall.Signal{
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGSTOP": syscall.SIGSTOP,
+	"SIGCONT": syscall.SIGCONT,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+func converterSinal(sinal string) (syscall.Signal, error) {
+	nome := normalizarSinal(sinal)
+	if s, ok := sinaisPorNome[nome]; ok {
+		return s, nil
+	}
+	if n, err := strconv.Atoi(nome); err == nil && n > 0 {
+		return syscall.Signal(n), nil
+	}
+	return 0, fmt.Errorf("sinal desconhecido: %s", sinal)
+}
+
+// Envia o sinal ao processo ou, se "grupo", a todo o grupo do processo (scripts que iniciam o Java).
+func enviarSinal(pid int, grupo bool, sinal string) error {
+	s, err := converterSinal(sinal)
+	if err != nil {
+		return err
+	}
+	alvo := pid
+	if grupo {
+		alvo = -pid
+	}
+	if err := syscall.Kill(alvo, s); err != nil {
+		return fmt.Errorf("%s para pid %d: %w", normalizarSinal(sinal), pid, err)
+	}
+	return nil
+}
+
+func processoVivo(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+func configurarGrupoDeProcesso(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,108 @@
+package adaptadores
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gocql/gocql"
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Verifica a convergência do cluster: estados e hints pelo nodetool do orquestrador,
+// schema e consultas pela sessão CQL (opcional; sem ela só nos_un e hints_drenados funcionam).
+type VerificadorDeClusterCassandra struct {
+	Sessao       *gocql.Session
+	Orquestrador p.PortaDeOrquestracaoDeFalhas
+}
+
+func NovoVerificadorDeClusterCassandra(sessao *gocql.Session, orquestrador p.PortaDeOrquestracaoDeFalhas) *VerificadorDeClusterCassandra {
+	return &VerificadorDeClusterCassandra{Sessao: sessao, Orquestrador: orquestrador}
+}
+
+// Linha de nó do nodetool status, ex.: "UN  172.18.0.2  1.2 MiB  16  100.0%  5762b140-...  rack1".
+var linhaDeStatus = regexp.MustCompile(`^([UD][NLJM])\s+(\S+)`)
+
+// Linha da tabela do nodetool listpendinghints (começa pelo host id).
+var linhaDeHint = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\s`)
+
+func (v *VerificadorDeClusterCassandra) EstadosDosNos(ctx context.Context, nomeDoContainer string) (map[string]string, error) {
+	saida, err := v.Orquestrador.ExecutarNodetool(ctx, nomeDoContainer, false, "status")
+	if err != nil {
+		return nil, err
+	}
+	return estadosDoStatus(saida), nil
+}
+
+func estadosDoStatus(saida string) map[string]string {
+	estados := map[string]string{}
+	for _, linha := range strings.Split(saida, "\n") {
+		if m := linhaDeStatus.FindStringSubmatch(strings.TrimSpace(linha)); m != nil {
+			estados[m[2]] = m[1]
+		}
+	}
+	return estados
+}
+
+func (v *VerificadorDeClusterCassandra) NosComHintsPendentes(ctx context.Context, nomeDoContainer string) (int, error) {
+	saida, err := v.Orquestrador.ExecutarNodetool(ctx, nomeDoContainer, false, "listpendinghints")
+	if err != nil {
+		return 0, err
+	}
+	return nosComHints(saida), nil
+}
+
+func nosComHints(saida string) int {
+	if strings.Contains(saida, "does not have any pending hints") {
+		return 0
+	}
+	var nos int
+	for _, linha := range strings.Split(saida, "\n") {
+		if linhaDeHint.MatchString(strings.TrimSpace(linha)) {
+			nos++
+		}
+	}
+	return nos
+}
+
+func (v *VerificadorDeClusterCassandra) VersoesDeSchema(ctx context.Context) ([]string, error) {
+	if v.Sessao == nil {
+		return nil, fmt.Errorf("schema_acordo exige sessao CQL")
+	}
+	versoes := map[string]bool{}
+	var versao gocql.UUID
+	if err := v.Sessao.Query(`SELECT schema_version FROM system.local`).WithContext(ctx).Scan(&versao); err != nil {
+		return nil, fmt.Errorf("system.local: %w", err)
+	}
+	versoes[versao.String()] = true
+	iter := v.Sessao.Query(`SELECT schema_version FROM system.peers`).WithContext(ctx).Iter()
+	for iter.Scan(&versao) {
+		versoes[versao.String()] = true
+	}
+	if err := iter.Close(); err != nil {
+		return nil, fmt.Errorf("system.peers: %w", err)
+	}
+	lista := make([]string, 0, len(versoes))
+	for versao := range versoes {
+		lista = append(lista, versao)
+	}
+	sort.Strings(lista)
+	return lista, nil
+}
+
+func (v *VerificadorDeClusterCassandra) ExecutarConsulta(ctx context.Context, consulta string, consistencia string) error {
+	if v.Sessao == nil {
+		return fmt.Errorf("consulta exige sessao CQL")
+	}
+	nivel, err := gocql.ParseConsistencyWrapper(consistencia)
+	if err != nil {
+		return err
+	}
+	// Sem retentativas: cada sondagem mede o estado atual do cluster
+	iter := v.Sessao.Query(consulta).WithContext(ctx).Consistency(nivel).RetryPolicy(nil).Iter()
+	for iter.Scan() {
+	}
+	return iter.Close()
+}
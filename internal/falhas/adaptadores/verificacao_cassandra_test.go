@@ -0,0 +1,37 @@
+package adaptadores
+
+import "testing"
+
+func TestEstadosDoStatus(t *testing.T) {
+	saida := `Datacenter: datacenter1
+=======================
+Status=Up/Down
+|/ State=Normal/Leaving/Joining/Moving
+--  Address     Load       Tokens  Owns (effective)  Host ID                               Rack
+UN  172.18.0.2  1.2 MiB    16      66.7%             5762b140-3fdf-4057-9ca7-05c070ccc9c3  rack1
+DN  172.18.0.3  1.1 MiB    16      66.7%             9c1e7a2b-1d2e-4c3f-8a9b-0c1d2e3f4a5b  rack1
+UJ  172.18.0.4  90 KiB     16      ?                 1f2e3d4c-5b6a-4978-8695-a4b3c2d1e0f9  rack1
+`
+	estados := estadosDoStatus(saida)
+	esperados := map[string]string{"172.18.0.2": "UN", "172.18.0.3": "DN", "172.18.0.4": "UJ"}
+	if len(estados) != len(esperados) {
+		t.Fatalf("estados = %v", estados)
+	}
+	for endereco, estado := range esperados {
+		if estados[endereco] != estado {
+			t.Fatalf("estados = %v, esperado %v", estados, esperados)
+		}
+	}
+}
+
+func TestNosComHints(t *testing.T) {
+	if n := nosComHints("This node does not have any pending hints\n"); n != 0 {
+		t.Fatalf("sem hints = %d", n)
+	}
+	saida := `Host ID                               Address     Rack   DC           Status  Total files  Newest                   Oldest
+9c1e7a2b-1d2e-4c3f-8a9b-0c1d2e3f4a5b  172.18.0.3  rack1  datacenter1  DOWN    2            2024-05-01 12:00:03,120  2024-05-01 12:00:00,010
+`
+	if n := nosComHints(saida); n != 1 {
+		t.Fatalf("com hints = %d, esperado 1", n)
+	}
+}
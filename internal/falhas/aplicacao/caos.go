@@ -0,0 +1,210 @@
+package aplicacao
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+	"gopkg.in/yaml.v3"
+)
+
+// Parâmetros do modo caos: falhas sorteadas a partir de uma semente, com restrições de segurança.
+// A mesma semente e configuração geram sempre o mesmo plano.
+type ConfiguracaoDoCaos struct {
+	Semente            int64    `yaml:"semente" json:"semente"` // 0 = derivada do relógio (gravada no arquivo do plano)
+	DuracaoSegundos    int      `yaml:"duracao_s" json:"duracao_s"`
+	Nos                []string `yaml:"nos" json:"nos"`
+	NosProtegidos      []string `yaml:"protegidos,omitempty" json:"protegidos,omitempty"` // nunca alvo nem remoto (ex.: nó semente)
+	Acoes              []string `yaml:"acoes,omitempty" json:"acoes,omitempty"`           // só ações com inversa; padrão pausar, parar, matar
+	MaximoDeNosEmFalha int      `yaml:"max_nos_em_falha,omitempty" json:"max_nos_em_falha,omitempty"`
+
+	DuracaoMinimaSegundos   int                 `yaml:"duracao_min_s,omitempty" json:"duracao_min_s,omitempty"`
+	DuracaoMaximaSegundos   int                 `yaml:"duracao_max_s,omitempty" json:"duracao_max_s,omitempty"`
+	IntervaloMinimoSegundos int                 `yaml:"intervalo_min_s,omitempty" json:"intervalo_min_s,omitempty"` // entre inícios de falhas
+	IntervaloMaximoSegundos int                 `yaml:"intervalo_max_s,omitempty" json:"intervalo_max_s,omitempty"`
+	TimeoutSegundos         int                 `yaml:"timeout_s,omitempty" json:"timeout_s,omitempty"`
+	NomeDaRede              string              `yaml:"rede,omitempty" json:"rede,omitempty"`             // exigida por desconectar
+	Degradacao              *p.DegradacaoDeRede `yaml:"degradacao,omitempty" json:"degradacao,omitempty"` // exigida por degradar_rede
+}
+
+// Preenche os padrões; a semente resolvida fica na própria configuração.
+func (c *ConfiguracaoDoCaos) aplicarPadroes() {
+	if c.Semente == 0 {
+		c.Semente = time.Now().UnixNano()
+	}
+	if len(c.Acoes) == 0 {
+		c.Acoes = []string{"pausar", "parar", "matar"}
+	}
+	if c.MaximoDeNosEmFalha <= 0 {
+		c.MaximoDeNosEmFalha = 1
+	}
+	if c.DuracaoMinimaSegundos <= 0 {
+		c.DuracaoMinimaSegundos = 10
+	}
+	if c.DuracaoMaximaSegundos < c.DuracaoMinimaSegundos {
+		c.DuracaoMaximaSegundos = max(60, c.DuracaoMinimaSegundos)
+	}
+	if c.IntervaloMinimoSegundos <= 0 {
+		c.IntervaloMinimoSegundos = 5
+	}
+	if c.IntervaloMaximoSegundos < c.IntervaloMinimoSegundos {
+		c.IntervaloMaximoSegundos = max(30, c.IntervaloMinimoSegundos)
+	}
+}
+
+func (c ConfiguracaoDoCaos) validar() error {
+	var problemas []error
+	if c.DuracaoSegundos <= 0 {
+		problemas = append(problemas, fmt.Errorf("duracao_s deve ser positiva"))
+	}
+	protegidos := conjunto(c.NosProtegidos)
+	var elegiveis int
+	for _, no := range c.Nos {
+		if !protegidos[no] {
+			elegiveis++
+		}
+	}
+	if elegiveis == 0 {
+		problemas = append(problemas, fmt.Errorf("nenhum no elegivel (todos protegidos ou lista vazia)"))
+	}
+	for _, acao := range c.Acoes {
+		if _, ok := acoesInversas[acao]; !ok {
+			problemas = append(problemas, fmt.Errorf("acao %q sem inversa nao pode ser usada no caos", acao))
+		}
+		if acao == "desconectar" && c.NomeDaRede == "" {
+			problemas = append(problemas, fmt.Errorf("desconectar exige rede"))
+		}
+		if acao == "degradar_rede" {
+			if err := validarDegradacao(c.Degradacao); err != nil {
+				problemas = append(problemas, err)
+			}
+		}
+		if acaoEntreNos(acao) && elegiveis < 2 {
+			problemas = append(problemas, fmt.Errorf("%s exige ao menos dois nos elegiveis", acao))
+		}
+		// Sem isso a ação seria sorteada e sempre descartada, sem aviso
+		if acaoEntreNos(acao) && c.MaximoDeNosEmFalha < 2 {
+			problemas = append(problemas, fmt.Errorf("%s afeta dois nos e exige max_nos_em_falha >= 2 (atual %d)", acao, c.MaximoDeNosEmFalha))
+		}
+	}
+	if c.DuracaoMinimaSegundos >= c.DuracaoSegundos {
+		problemas = append(problemas, fmt.Errorf("duracao_min_s (%d) deve ser menor que duracao_s (%d)", c.DuracaoMinimaSegundos, c.DuracaoSegundos))
+	}
+	return errors.Join(problemas...)
+}
+
+// Falha já sorteada: nós afetados até "fim".
+type falhaSorteada struct {
+	nos []string
+	fim int
+}
+
+// Gera o plano do caos. Cada falha tem duração (inversa automática) e termina antes de DuracaoSegundos,
+// de modo que o cluster termina recuperado. Um nó sofre no máximo uma falha por vez, nunca há mais
+// de MaximoDeNosEmFalha nós afetados ao mesmo tempo e nós protegidos nunca são alvo nem remoto.
+func GerarPlanoDeCaos(cfg ConfiguracaoDoCaos) (ConfiguracaoDoCaos, []p.EtapaDoPlano, error) {
+	cfg.aplicarPadroes()
+	if err := cfg.validar(); err != nil {
+		return cfg, nil, fmt.Errorf("caos invalido: %w", err)
+	}
+	sorteio := rand.New(rand.NewSource(cfg.Semente))
+	entre := func(minimo, maximo int) int { return minimo + sorteio.Intn(maximo-minimo+1) }
+	protegidos := conjunto(cfg.NosProtegidos)
+
+	var plano []p.EtapaDoPlano
+	var ativas []falhaSorteada
+	momento := 0
+	for {
+		momento += entre(cfg.IntervaloMinimoSegundos, cfg.IntervaloMaximoSegundos)
+		if momento+cfg.DuracaoMinimaSegundos > cfg.DuracaoSegundos {
+			break
+		}
+		// Só contam as falhas ainda ativas neste momento
+		emFalha := map[string]bool{}
+		var vigentes []falhaSorteada
+		for _, f := range ativas {
+			if f.fim >= momento { // o nó recém-recuperado também fica de fora neste momento
+				vigentes = append(vigentes, f)
+				for _, no := range f.nos {
+					emFalha[no] = true
+				}
+			}
+		}
+		ativas = vigentes
+
+		acao := cfg.Acoes[sorteio.Intn(len(cfg.Acoes))]
+		var livres []string
+		for _, no := range cfg.Nos {
+			if !protegidos[no] && !emFalha[no] {
+				livres = append(livres, no)
+			}
+		}
+		afetados := 1
+		if acaoEntreNos(acao) {
+			afetados = 2
+		}
+		if len(livres) < afetados || len(emFalha)+afetados > cfg.MaximoDeNosEmFalha {
+			continue // restrição violada: sem falha neste momento
+		}
+		sorteio.Shuffle(len(livres), func(i, j int) { livres[i], livres[j] = livres[j], livres[i] })
+		duracao := min(entre(cfg.DuracaoMinimaSegundos, cfg.DuracaoMaximaSegundos), cfg.DuracaoSegundos-momento)
+
+		etapa := p.EtapaDoPlano{MomentoRelativoSegundos: momento, Acao: acao, NomeDoContainer: livres[0], DuracaoSegundos: duracao}
+		switch {
+		case acaoEntreNos(acao):
+			etapa.NomeDoContainerRemoto = livres[1]
+			etapa.Direcao = []string{p.DirecaoAmbas, p.DirecaoSaida, p.DirecaoEntrada}[sorteio.Intn(3)]
+		case acaoUsaRede(acao):
+			etapa.NomeDaRede = cfg.NomeDaRede
+		case acao == "degradar_rede":
+			degradacao := *cfg.Degradacao
+			etapa.Degradacao = &degradacao
+		case acao == "parar" || acao == "drenar":
+			etapa.TimeoutSegundos = cfg.TimeoutSegundos
+		}
+		plano = append(plano, etapa)
+		ativas = append(ativas, falhaSorteada{nos: livres[:afetados], fim: momento + duracao})
+	}
+	if len(plano) == 0 {
+		return cfg, nil, fmt.Errorf("caos sem falhas: aumente duracao_s ou reduza intervalo/duracao das falhas")
+	}
+	if err := ValidarPlano(plano); err != nil {
+		return cfg, nil, err
+	}
+	return cfg, plano, nil
+}
+
+// Grava o plano (e, no caos, a configuração com a semente) para reexecução exata com -plano.
+func SalvarPlanoEmArquivo(caminho string, arquivo ArquivoDePlano) error {
+	var b bytes.Buffer
+	codif := yaml.NewEncoder(&b)
+	codif.SetIndent(2)
+	if err := codif.Encode(arquivo); err != nil {
+		return err
+	}
+	return os.WriteFile(caminho, b.Bytes(), 0o644)
+}
+
+func conjunto(itens []string) map[string]bool {
+	m := make(map[string]bool, len(itens))
+	for _, item := range itens {
+		m[item] = true
+	}
+	return m
+}
+
+func CarregarConfiguracaoDoCaos(caminho string) (ConfiguracaoDoCaos, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return ConfiguracaoDoCaos{}, err
+	}
+	var cfg ConfiguracaoDoCaos
+	if err := yaml.Unmarshal(conteudo, &cfg); err != nil {
+		return ConfiguracaoDoCaos{}, fmt.Errorf("configuracao do caos %s invalida: %w", caminho, err)
+	}
+	return cfg, nil
+}
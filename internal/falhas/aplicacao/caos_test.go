@@ -0,0 +1,111 @@
+package aplicacao
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+func configuracaoDeTeste(semente int64) ConfiguracaoDoCaos {
+	return ConfiguracaoDoCaos{
+		Semente:            semente,
+		DuracaoSegundos:    600,
+		Nos:                []string{"cassandra1", "cassandra2", "cassandra3", "cassandra4", "cassandra5"},
+		NosProtegidos:      []string{"cassandra1"},
+		Acoes:              []string{"pausar", "parar", "matar", "bloquear_trafego", "degradar_rede"},
+		MaximoDeNosEmFalha: 2,
+		Degradacao:         &p.DegradacaoDeRede{AtrasoMs: 200},
+	}
+}
+
+// Nós afetados pela etapa do caos (alvo e, no bloqueio, o remoto).
+func nosDaEtapa(e p.EtapaDoPlano) []string {
+	if e.NomeDoContainerRemoto != "" {
+		return []string{e.NomeDoContainer, e.NomeDoContainerRemoto}
+	}
+	return []string{e.NomeDoContainer}
+}
+
+func TestCaosMesmaSementeMesmoPlano(t *testing.T) {
+	_, plano1, err := GerarPlanoDeCaos(configuracaoDeTeste(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, plano2, err := GerarPlanoDeCaos(configuracaoDeTeste(42))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(plano1, plano2) {
+		t.Fatalf("planos diferentes para a mesma semente:\n%+v\n%+v", plano1, plano2)
+	}
+	_, plano3, err := GerarPlanoDeCaos(configuracaoDeTeste(43))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reflect.DeepEqual(plano1, plano3) {
+		t.Fatalf("sementes 42 e 43 geraram o mesmo plano")
+	}
+}
+
+func TestCaosRespeitaAsRestricoes(t *testing.T) {
+	for semente := int64(1); semente <= 200; semente++ {
+		cfg, plano, err := GerarPlanoDeCaos(configuracaoDeTeste(semente))
+		if err != nil {
+			t.Fatalf("semente %d: %v", semente, err)
+		}
+		protegidos := conjunto(cfg.NosProtegidos)
+		for i, e := range plano {
+			fim := e.MomentoRelativoSegundos + e.DuracaoSegundos
+			if e.DuracaoSegundos <= 0 || fim > cfg.DuracaoSegundos {
+				t.Fatalf("semente %d: %s em %ds dura %ds, alem de duracao_s", semente, e.Acao, e.MomentoRelativoSegundos, e.DuracaoSegundos)
+			}
+			for _, no := range nosDaEtapa(e) {
+				if protegidos[no] {
+					t.Fatalf("semente %d: no protegido %s afetado por %s", semente, no, e.Acao)
+				}
+			}
+			// Contagem só cresce no início de uma falha: basta conferir cada início (intervalos fechados)
+			emFalha := map[string]int{}
+			for _, outra := range plano[:i+1] {
+				if outra.MomentoRelativoSegundos+outra.DuracaoSegundos >= e.MomentoRelativoSegundos {
+					for _, no := range nosDaEtapa(outra) {
+						emFalha[no]++
+					}
+				}
+			}
+			if len(emFalha) > cfg.MaximoDeNosEmFalha {
+				t.Fatalf("semente %d: %d nos em falha em %ds (max %d)", semente, len(emFalha), e.MomentoRelativoSegundos, cfg.MaximoDeNosEmFalha)
+			}
+			for no, n := range emFalha {
+				if n > 1 {
+					t.Fatalf("semente %d: %s com %d falhas simultaneas em %ds", semente, no, n, e.MomentoRelativoSegundos)
+				}
+			}
+		}
+	}
+}
+
+func TestCaosRejeitaConfiguracaoInvalida(t *testing.T) {
+	casos := []struct {
+		nome   string
+		mudar  func(*ConfiguracaoDoCaos)
+		trecho string
+	}{
+		{"bloqueio com um no em falha", func(c *ConfiguracaoDoCaos) { c.MaximoDeNosEmFalha = 0 }, "max_nos_em_falha >= 2"},
+		{"bloqueio sem dois elegiveis", func(c *ConfiguracaoDoCaos) { c.NosProtegidos = c.Nos[:4] }, "dois nos elegiveis"},
+		{"acao sem inversa", func(c *ConfiguracaoDoCaos) { c.Acoes = []string{"reiniciar"} }, "sem inversa"},
+		{"degradar sem degradacao", func(c *ConfiguracaoDoCaos) { c.Degradacao = nil }, "degrada"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			cfg := configuracaoDeTeste(1)
+			c.mudar(&cfg)
+			_, _, err := GerarPlanoDeCaos(cfg)
+			if err == nil || !strings.Contains(err.Error(), c.trecho) {
+				t.Fatalf("err = %v, esperado %q", err, c.trecho)
+			}
+		})
+	}
+}
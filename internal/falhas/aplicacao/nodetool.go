@@ -0,0 +1,117 @@
+package aplicacao
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Tempo máximo para coletar o estado do nó (um nó pausado não responde ao JMX).
+const tempoLimiteDoEstado = 15 * time.Second
+
+// Argumentos do nodetool para as ações que o usam e se rodam em segundo plano.
+func argumentosDoNodetool(e p.EtapaDoPlano) ([]string, bool, bool) {
+	switch e.Acao {
+	case "desabilitar_binario":
+		return []string{"disablebinary"}, false, true
+	case "habilitar_binario":
+		return []string{"enablebinary"}, false, true
+	case "desabilitar_gossip":
+		return []string{"disablegossip"}, false, true
+	case "habilitar_gossip":
+		return []string{"enablegossip"}, false, true
+	case "desabilitar_handoff":
+		return []string{"disablehandoff"}, false, true
+	case "habilitar_handoff":
+		return []string{"enablehandoff"}, false, true
+	case "drenar":
+		return []string{"drain"}, false, true
+	case "compactar":
+		// Compactação maior pode levar minutos: dispara e segue o plano
+		args := []string{"compact"}
+		if e.Keyspace != "" {
+			args = append(append(args, e.Keyspace), e.Tabelas...)
+		}
+		return args, true, true
+	case "interromper_compactacao":
+		return []string{"stop", "COMPACTION"}, false, true
+	default:
+		return nil, false, false
+	}
+}
+
+func acaoDoNodetool(acao string) bool {
+	_, _, ok := argumentosDoNodetool(p.EtapaDoPlano{Acao: acao})
+	return ok
+}
+
+// Resumo do estado do nó, ex.: "modo=NORMAL gossip=on binario=off handoff=on compactacoes_pendentes=3".
+// Consultas que falham aparecem como "?" para não esconder o restante.
+func estadoDoNo(ctx context.Context, orquestrador p.PortaDeOrquestracaoDeFalhas, nomeDoContainer string) string {
+	ctx, cancelar := context.WithTimeout(ctx, tempoLimiteDoEstado)
+	defer cancelar()
+	consultas := []string{"info", "statushandoff", "netstats", "compactionstats"}
+	saidas := make([]string, len(consultas))
+	var wg sync.WaitGroup
+	for i, consulta := range consultas {
+		wg.Add(1)
+		go func(i int, consulta string) {
+			defer wg.Done()
+			if saida, err := orquestrador.ExecutarNodetool(ctx, nomeDoContainer, false, consulta); err == nil {
+				saidas[i] = saida
+			}
+		}(i, consulta)
+	}
+	wg.Wait()
+	if strings.Join(saidas, "") == "" {
+		return "indisponivel"
+	}
+	return resumirEstadoDoNo(saidas[0], saidas[1], saidas[2], saidas[3])
+}
+
+func resumirEstadoDoNo(info, handoff, netstats, compactacoes string) string {
+	modo := valorDoNodetool(netstats, "Mode")
+	gossip := ligado(valorDoNodetool(info, "Gossip active"))
+	binario := ligado(valorDoNodetool(info, "Native Transport active"))
+	estadoDoHandoff := "?"
+	switch {
+	case strings.Contains(handoff, "not running"):
+		estadoDoHandoff = "off"
+	case strings.Contains(handoff, "running"):
+		estadoDoHandoff = "on"
+	}
+	pendentes := valorDoNodetool(compactacoes, "pending tasks")
+	if modo == "" {
+		modo = "?"
+	}
+	if pendentes == "" {
+		pendentes = "?"
+	}
+	return fmt.Sprintf("modo=%s gossip=%s binario=%s handoff=%s compactacoes_pendentes=%s", modo, gossip, binario, estadoDoHandoff, pendentes)
+}
+
+// Valor de linhas "Chave : valor" da saída do nodetool.
+func valorDoNodetool(saida, chave string) string {
+	for _, linha := range strings.Split(saida, "\n") {
+		nome, valor, ok := strings.Cut(linha, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(nome), chave) {
+			return strings.TrimSpace(valor)
+		}
+	}
+	return ""
+}
+
+func ligado(valor string) string {
+	switch strings.ToLower(valor) {
+	case "true":
+		return "on"
+	case "false":
+		return "off"
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,314 @@
+package aplicacao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+	"gopkg.in/yaml.v3"
+)
+
+// Ação inversa executada automaticamente ao fim da duração da etapa.
+var acoesInversas = map[string]string{
+	"pausar":           "continuar",
+	"parar":            "iniciar",
+	"matar":            "iniciar",
+	"desconectar":      "reconectar",
+	"degradar_rede":    "limpar_rede",
+	"bloquear_trafego": "desbloquear_trafego",
+	// Ações do nodetool; o drain só é desfeito reiniciando o nó
+	"desabilitar_binario": "habilitar_binario",
+	"desabilitar_gossip":  "habilitar_gossip",
+	"desabilitar_handoff": "habilitar_handoff",
+	"drenar":              "reiniciar",
+	"compactar":           "interromper_compactacao",
+}
+
+var acoesConhecidas = map[string]bool{
+	"pausar":                  true,
+	"continuar":               true,
+	"parar":                   true,
+	"iniciar":                 true,
+	"matar":                   true,
+	"reiniciar":               true,
+	"desconectar":             true,
+	"reconectar":              true,
+	"degradar_rede":           true,
+	"limpar_rede":             true,
+	"bloquear_trafego":        true,
+	"desbloquear_trafego":     true,
+	"desabilitar_binario":     true,
+	"habilitar_binario":       true,
+	"desabilitar_gossip":      true,
+	"habilitar_gossip":        true,
+	"desabilitar_handoff":     true,
+	"habilitar_handoff":       true,
+	"drenar":                  true,
+	"compactar":               true,
+	"interromper_compactacao": true,
+	"aguardar":                true, // espera a convergência do cluster (sem inversa)
+}
+
+func acaoEntreNos(acao string) bool {
+	return acao == "bloquear_trafego" || acao == "desbloquear_trafego"
+}
+
+// Ferramenta de rede exigida no namespace do container pela ação ("" se nenhuma).
+func ferramentaDeRede(acao string) string {
+	switch acao {
+	case "degradar_rede", "limpar_rede":
+		return "tc"
+	case "bloquear_trafego", "desbloquear_trafego":
+		return "iptables"
+	default:
+		return ""
+	}
+}
+
+func acaoUsaRede(acao string) bool { return acao == "desconectar" || acao == "reconectar" }
+
+// Formato do plano em arquivo (YAML ou JSON): objeto com "etapas" ou lista de etapas.
+// Planos gerados pelo caos guardam também a configuração e a semente usadas (só informativas).
+type ArquivoDePlano struct {
+	Caos   *ConfiguracaoDoCaos `yaml:"caos,omitempty" json:"caos,omitempty"`
+	Etapas []p.EtapaDoPlano    `yaml:"etapas" json:"etapas"`
+}
+
+func CarregarPlanoDeArquivo(caminho string) ([]p.EtapaDoPlano, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return nil, err
+	}
+	var arquivo ArquivoDePlano
+	if errObjeto := yaml.Unmarshal(conteudo, &arquivo); errObjeto != nil {
+		var etapas []p.EtapaDoPlano
+		if errLista := yaml.Unmarshal(conteudo, &etapas); errLista != nil {
+			return nil, fmt.Errorf("plano %s invalido: %w", caminho, errObjeto)
+		}
+		arquivo.Etapas = etapas
+	}
+	if len(arquivo.Etapas) == 0 {
+		return nil, fmt.Errorf("plano %s sem etapas", caminho)
+	}
+	return arquivo.Etapas, nil
+}
+
+// Valida todas as etapas e retorna todos os problemas encontrados de uma vez.
+func ValidarPlano(plano []p.EtapaDoPlano) error {
+	var problemas []error
+	for i, e := range plano {
+		invalida := func(formato string, args ...any) {
+			problemas = append(problemas, fmt.Errorf("etapa %d (%s %s): %s", i+1, e.Acao, e.NomeDoContainer, fmt.Sprintf(formato, args...)))
+		}
+		if !acoesConhecidas[e.Acao] {
+			invalida("acao desconhecida")
+		}
+		if strings.TrimSpace(e.NomeDoContainer) == "" && (e.Acao != "aguardar" || condicaoUsaNo(e.Condicao)) {
+			invalida("container obrigatorio")
+		}
+		if e.Acao == "aguardar" {
+			if !condicoesConhecidas[e.Condicao] {
+				invalida("condicao invalida %q (use nos_un, schema_acordo, hints_drenados ou consulta)", e.Condicao)
+			}
+			if e.Condicao == "consulta" && strings.TrimSpace(e.Consulta) == "" {
+				invalida("condicao consulta exige consulta")
+			}
+			if e.Consistencia != "" && !consistenciasConhecidas[strings.ToUpper(e.Consistencia)] {
+				invalida("consistencia invalida %q", e.Consistencia)
+			}
+		}
+		if acaoUsaRede(e.Acao) && strings.TrimSpace(e.NomeDaRede) == "" {
+			invalida("rede obrigatoria")
+		}
+		if e.MomentoRelativoSegundos < 0 {
+			invalida("momento negativo: %d", e.MomentoRelativoSegundos)
+		}
+		if e.TimeoutSegundos < 0 {
+			invalida("timeout negativo: %d", e.TimeoutSegundos)
+		}
+		if acaoEntreNos(e.Acao) {
+			if strings.TrimSpace(e.NomeDoContainerRemoto) == "" {
+				invalida("remoto obrigatorio")
+			} else if e.NomeDoContainerRemoto == e.NomeDoContainer {
+				invalida("remoto deve ser diferente do container")
+			}
+			switch e.Direcao {
+			case "", p.DirecaoAmbas, p.DirecaoSaida, p.DirecaoEntrada:
+			default:
+				invalida("direcao invalida %q (use ambas, saida ou entrada)", e.Direcao)
+			}
+		}
+		if e.Acao == "degradar_rede" {
+			if err := validarDegradacao(e.Degradacao); err != nil {
+				invalida("%v", err)
+			}
+		}
+		if len(e.Tabelas) > 0 && strings.TrimSpace(e.Keyspace) == "" {
+			invalida("tabelas exigem keyspace")
+		}
+		if e.Repeticoes < 0 || e.IntervaloEntreRepeticoesSegundos < 0 || e.DuracaoSegundos < 0 {
+			invalida("repeticoes, intervalo e duracao nao podem ser negativos")
+		}
+		if e.Repeticoes > 1 && e.IntervaloEntreRepeticoesSegundos <= 0 {
+			invalida("repeticoes exigem intervalo_s > 0")
+		}
+		if e.DuracaoSegundos > 0 {
+			if _, ok := acoesInversas[e.Acao]; !ok {
+				invalida("duracao_s nao suportada (acao sem inversa)")
+			}
+			if e.Repeticoes > 1 && e.DuracaoSegundos >= e.IntervaloEntreRepeticoesSegundos {
+				invalida("duracao_s (%d) deve ser menor que intervalo_s (%d)", e.DuracaoSegundos, e.IntervaloEntreRepeticoesSegundos)
+			}
+		}
+	}
+	return errors.Join(problemas...)
+}
+
+// Confirma pelo orquestrador que as redes usadas por desconectar/reconectar existem.
+// Orquestradores sem redes nomeadas (processos) não são checados aqui.
+func ValidarRedesDoPlano(ctx context.Context, orquestrador p.PortaDeOrquestracaoDeFalhas, plano []p.EtapaDoPlano) error {
+	verificador, ok := orquestrador.(p.PortaDeVerificacaoDeRede)
+	if !ok {
+		return nil
+	}
+	verificadas := map[string]bool{}
+	var problemas []error
+	for _, e := range plano {
+		if !acaoUsaRede(e.Acao) || strings.TrimSpace(e.NomeDaRede) == "" || verificadas[e.NomeDaRede] {
+			continue
+		}
+		verificadas[e.NomeDaRede] = true
+		if err := verificador.VerificarRede(ctx, e.NomeDaRede); err != nil {
+			problemas = append(problemas, err)
+		}
+	}
+	return errors.Join(problemas...)
+}
+
+// Resolve repetições e durações em etapas simples, ordenadas pelo momento de execução.
+func ExpandirPlano(plano []p.EtapaDoPlano) []p.EtapaDoPlano {
+	var etapas []p.EtapaDoPlano
+	for _, e := range plano {
+		repeticoes := e.Repeticoes
+		if repeticoes < 1 {
+			repeticoes = 1
+		}
+		for r := 0; r < repeticoes; r++ {
+			simples := e
+			simples.Repeticoes, simples.IntervaloEntreRepeticoesSegundos, simples.DuracaoSegundos = 0, 0, 0
+			simples.MomentoRelativoSegundos = e.MomentoRelativoSegundos + r*e.IntervaloEntreRepeticoesSegundos
+			etapas = append(etapas, simples)
+			if inversa, ok := acoesInversas[e.Acao]; ok && e.DuracaoSegundos > 0 {
+				volta := simples
+				volta.Acao = inversa
+				volta.MomentoRelativoSegundos += e.DuracaoSegundos
+				etapas = append(etapas, volta)
+			}
+		}
+	}
+	sort.SliceStable(etapas, func(i, j int) bool { return etapas[i].MomentoRelativoSegundos < etapas[j].MomentoRelativoSegundos })
+	return etapas
+}
+
+// Linha do tempo legível das etapas já expandidas (usada no dry-run).
+func FormatarLinhaDoTempo(etapas []p.EtapaDoPlano) string {
+	var b strings.Builder
+	for _, e := range etapas {
+		fmt.Fprintf(&b, "t+%-8s %-23s %s", time.Duration(e.MomentoRelativoSegundos)*time.Second, e.Acao, e.NomeDoContainer)
+		if e.Acao == "aguardar" {
+			fmt.Fprintf(&b, " ate=%s", e.Condicao)
+			if e.Condicao == "consulta" {
+				fmt.Fprintf(&b, " %q consistencia=%s", e.Consulta, consistenciaDaEtapa(e))
+			}
+		}
+		if e.NomeDaRede != "" {
+			fmt.Fprintf(&b, " rede=%s", e.NomeDaRede)
+		}
+		if (e.Acao == "parar" || e.Acao == "reiniciar" || e.Acao == "aguardar") && e.TimeoutSegundos > 0 {
+			fmt.Fprintf(&b, " timeout=%ds", e.TimeoutSegundos)
+		}
+		if e.Acao == "matar" && e.Sinal != "" {
+			fmt.Fprintf(&b, " sinal=%s", e.Sinal)
+		}
+		if acaoEntreNos(e.Acao) {
+			fmt.Fprintf(&b, " %s", descreverBloqueio(e))
+		}
+		if e.Acao == "degradar_rede" && e.Degradacao != nil {
+			fmt.Fprintf(&b, " %s", DescreverDegradacao(*e.Degradacao))
+		}
+		if e.Acao == "compactar" && e.Keyspace != "" {
+			fmt.Fprintf(&b, " %s", strings.Join(append([]string{e.Keyspace}, e.Tabelas...), " "))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func validarDegradacao(d *p.DegradacaoDeRede) error {
+	if d == nil {
+		return fmt.Errorf("degradacao obrigatoria")
+	}
+	if d.AtrasoMs < 0 || d.VariacaoMs < 0 || d.LimiteDeBandaKbit < 0 {
+		return fmt.Errorf("atraso, variacao e banda nao podem ser negativos")
+	}
+	for _, pct := range []float64{d.PerdaPercentual, d.DuplicacaoPercentual, d.ReordenacaoPercentual} {
+		if pct < 0 || pct > 100 {
+			return fmt.Errorf("percentuais devem estar entre 0 e 100")
+		}
+	}
+	if d.VariacaoMs > 0 && d.AtrasoMs == 0 {
+		return fmt.Errorf("variacao_ms exige atraso_ms")
+	}
+	if d.ReordenacaoPercentual > 0 && d.AtrasoMs == 0 {
+		return fmt.Errorf("reordenacao_pct exige atraso_ms")
+	}
+	if d.AtrasoMs == 0 && d.PerdaPercentual == 0 && d.DuplicacaoPercentual == 0 && d.LimiteDeBandaKbit == 0 {
+		return fmt.Errorf("degradacao sem efeito")
+	}
+	return nil
+}
+
+// Resumo legível da degradação (linha do tempo e anotações).
+func DescreverDegradacao(d p.DegradacaoDeRede) string {
+	var partes []string
+	if d.AtrasoMs > 0 {
+		atraso := fmt.Sprintf("atraso=%dms", d.AtrasoMs)
+		if d.VariacaoMs > 0 {
+			atraso += fmt.Sprintf("±%dms", d.VariacaoMs)
+		}
+		partes = append(partes, atraso)
+	}
+	if d.PerdaPercentual > 0 {
+		partes = append(partes, fmt.Sprintf("perda=%g%%", d.PerdaPercentual))
+	}
+	if d.DuplicacaoPercentual > 0 {
+		partes = append(partes, fmt.Sprintf("duplicacao=%g%%", d.DuplicacaoPercentual))
+	}
+	if d.ReordenacaoPercentual > 0 {
+		partes = append(partes, fmt.Sprintf("reordenacao=%g%%", d.ReordenacaoPercentual))
+	}
+	if d.LimiteDeBandaKbit > 0 {
+		partes = append(partes, fmt.Sprintf("banda=%dkbit", d.LimiteDeBandaKbit))
+	}
+	if d.Interface != "" {
+		partes = append(partes, "if="+d.Interface)
+	}
+	return strings.Join(partes, " ")
+}
+
+// Ex.: "-x- cassandra3" (ambas), "-x-> cassandra3" (saida), "<-x- cassandra3" (entrada).
+func descreverBloqueio(e p.EtapaDoPlano) string {
+	seta := "-x-"
+	switch e.Direcao {
+	case p.DirecaoSaida:
+		seta = "-x->"
+	case p.DirecaoEntrada:
+		seta = "<-x-"
+	}
+	return seta + " " + e.NomeDoContainerRemoto
+}
@@ -0,0 +1,184 @@
+package aplicacao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Condição não atendida até o timeout: registrada como resultado, sem interromper o plano.
+var ErrCondicaoNaoAtingida = errors.New("condicao nao atingida no prazo")
+
+const (
+	intervaloDeSondagem     = 2 * time.Second
+	timeoutPadraoDeAguardar = 5 * time.Minute
+)
+
+var condicoesConhecidas = map[string]bool{
+	"nos_un":         true, // todos os nós UN no nodetool status do container
+	"schema_acordo":  true, // uma única versão de schema em system.local/system.peers
+	"hints_drenados": true, // nenhum hint pendente em nenhum dos containers (vazio = NosDoCluster)
+	"consulta":       true, // a consulta responde na consistência pedida
+}
+
+var consistenciasConhecidas = map[string]bool{
+	"ANY": true, "ONE": true, "TWO": true, "THREE": true, "QUORUM": true, "ALL": true,
+	"LOCAL_QUORUM": true, "EACH_QUORUM": true, "LOCAL_ONE": true,
+}
+
+// Condições avaliadas com o nodetool de um nó específico.
+func condicaoUsaNo(condicao string) bool { return condicao == "nos_un" }
+
+// Os hints de um nó fora do ar ficam em todo coordenador que aceitou escritas para ele: a condição
+// soma os containers listados na etapa (separados por vírgula) ou, sem container, todos os nós do cluster.
+func (s *ServicoDeInjecaoDeFalhas) containersDosHints(e p.EtapaDoPlano) []string {
+	var containers []string
+	for _, nome := range strings.Split(e.NomeDoContainer, ",") {
+		if nome = strings.TrimSpace(nome); nome != "" {
+			containers = append(containers, nome)
+		}
+	}
+	if len(containers) == 0 {
+		return s.NosDoCluster
+	}
+	return containers
+}
+
+// Resultado de uma etapa aguardar: quanto tempo o cluster levou para convergir após a última ação.
+type MedicaoDeRecuperacao struct {
+	Condicao            string        `json:"condicao"`
+	NomeDoContainer     string        `json:"container,omitempty"`
+	InicioDaRecuperacao time.Time     `json:"inicio_da_recuperacao"` // momento da última ação antes da espera
+	Recuperado          bool          `json:"recuperado"`
+	TempoAteRecuperacao time.Duration `json:"tempo_ate_recuperacao_ns"` // sem recuperação: tempo até desistir
+	Tentativas          int           `json:"tentativas"`
+	UltimoDetalhe       string        `json:"ultimo_detalhe,omitempty"` // ex.: "UN=2 DN=1" ou o erro da consulta
+}
+
+// Cópia das medições de recuperação das etapas aguardar.
+func (s *ServicoDeInjecaoDeFalhas) Recuperacoes() []MedicaoDeRecuperacao {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]MedicaoDeRecuperacao(nil), s.recuperacoes...)
+}
+
+// Sonda a condição até ser atendida ou o timeout passar.
+func (s *ServicoDeInjecaoDeFalhas) aguardarCondicao(ctx context.Context, e p.EtapaDoPlano) (MedicaoDeRecuperacao, error) {
+	if s.Verificador == nil {
+		return MedicaoDeRecuperacao{}, fmt.Errorf("aguardar exige verificador do cluster")
+	}
+	timeout := timeoutPadraoDeAguardar
+	if e.TimeoutSegundos > 0 {
+		timeout = time.Duration(e.TimeoutSegundos) * time.Second
+	}
+	s.mu.Lock()
+	inicio := s.ultimaAcao
+	s.mu.Unlock()
+	if inicio.IsZero() {
+		inicio = time.Now()
+	}
+	medicao := MedicaoDeRecuperacao{Condicao: e.Condicao, NomeDoContainer: e.NomeDoContainer, InicioDaRecuperacao: inicio}
+	prazo := time.Now().Add(timeout)
+	for {
+		medicao.Tentativas++
+		ok, detalhe := s.avaliarCondicao(ctx, e)
+		medicao.UltimoDetalhe = detalhe
+		if ok {
+			medicao.Recuperado = true
+			medicao.TempoAteRecuperacao = time.Since(inicio)
+			return medicao, nil
+		}
+		if time.Now().Add(intervaloDeSondagem).After(prazo) {
+			medicao.TempoAteRecuperacao = time.Since(inicio)
+			return medicao, fmt.Errorf("%w: %s apos %s (%s)", ErrCondicaoNaoAtingida, e.Condicao, timeout, detalhe)
+		}
+		select {
+		case <-ctx.Done():
+			medicao.TempoAteRecuperacao = time.Since(inicio)
+			return medicao, ctx.Err()
+		case <-time.After(intervaloDeSondagem):
+		}
+	}
+}
+
+// Avalia a condição uma vez; o detalhe descreve o estado observado ou o erro.
+func (s *ServicoDeInjecaoDeFalhas) avaliarCondicao(ctx context.Context, e p.EtapaDoPlano) (bool, string) {
+	ctx, cancelar := context.WithTimeout(ctx, tempoLimiteDoEstado)
+	defer cancelar()
+	switch e.Condicao {
+	case "nos_un":
+		estados, err := s.Verificador.EstadosDosNos(ctx, e.NomeDoContainer)
+		if err != nil {
+			return false, err.Error()
+		}
+		return todosUN(estados), resumirEstados(estados)
+	case "hints_drenados":
+		containers := s.containersDosHints(e)
+		if len(containers) == 0 {
+			return false, "hints_drenados sem containers (informe container ou os nos do cluster)"
+		}
+		var total int
+		var porContainer []string
+		for _, nome := range containers {
+			pendentes, err := s.Verificador.NosComHintsPendentes(ctx, nome)
+			if err != nil {
+				return false, fmt.Sprintf("%s: %v", nome, err)
+			}
+			total += pendentes
+			porContainer = append(porContainer, fmt.Sprintf("%s=%d", nome, pendentes))
+		}
+		return total == 0, fmt.Sprintf("nos_com_hints=%d (%s)", total, strings.Join(porContainer, " "))
+	case "schema_acordo":
+		versoes, err := s.Verificador.VersoesDeSchema(ctx)
+		if err != nil {
+			return false, err.Error()
+		}
+		return len(versoes) == 1, fmt.Sprintf("versoes=%d", len(versoes))
+	case "consulta":
+		if err := s.Verificador.ExecutarConsulta(ctx, e.Consulta, consistenciaDaEtapa(e)); err != nil {
+			return false, err.Error()
+		}
+		return true, "consulta ok"
+	default:
+		return false, "condicao desconhecida: " + e.Condicao
+	}
+}
+
+// Consistência da consulta de sondagem; padrão QUORUM.
+func consistenciaDaEtapa(e p.EtapaDoPlano) string {
+	if e.Consistencia == "" {
+		return "QUORUM"
+	}
+	return strings.ToUpper(e.Consistencia)
+}
+
+func todosUN(estados map[string]string) bool {
+	if len(estados) == 0 {
+		return false
+	}
+	for _, estado := range estados {
+		if estado != "UN" {
+			return false
+		}
+	}
+	return true
+}
+
+// Ex.: "DN=1 UN=2".
+func resumirEstados(estados map[string]string) string {
+	contagem := map[string]int{}
+	for _, estado := range estados {
+		contagem[estado]++
+	}
+	var partes []string
+	for estado, n := range contagem {
+		partes = append(partes, fmt.Sprintf("%s=%d", estado, n))
+	}
+	sort.Strings(partes)
+	return strings.Join(partes, " ")
+}
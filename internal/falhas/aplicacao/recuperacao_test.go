@@ -0,0 +1,65 @@
+package aplicacao
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Verificador com hints pendentes fixos por container; registra quais containers foram consultados.
+type verificadorDeTeste struct {
+	hints      map[string]int
+	consultado []string
+}
+
+func (v *verificadorDeTeste) EstadosDosNos(context.Context, string) (map[string]string, error) {
+	return nil, nil
+}
+
+func (v *verificadorDeTeste) NosComHintsPendentes(_ context.Context, nomeDoContainer string) (int, error) {
+	v.consultado = append(v.consultado, nomeDoContainer)
+	return v.hints[nomeDoContainer], nil
+}
+
+func (v *verificadorDeTeste) VersoesDeSchema(context.Context) ([]string, error) { return nil, nil }
+
+func (v *verificadorDeTeste) ExecutarConsulta(context.Context, string, string) error { return nil }
+
+func TestHintsDrenadosSomaOsContainers(t *testing.T) {
+	nos := []string{"cassandra1", "cassandra2", "cassandra3"}
+	casos := []struct {
+		nome       string
+		container  string
+		hints      map[string]int
+		drenados   bool
+		consultado string
+	}{
+		{"todos os nos por padrao", "", map[string]int{"cassandra3": 1}, false, "cassandra1,cassandra2,cassandra3"},
+		{"todos drenados", "", nil, true, "cassandra1,cassandra2,cassandra3"},
+		{"lista da etapa", "cassandra1, cassandra3", map[string]int{"cassandra2": 4}, true, "cassandra1,cassandra3"},
+		{"um container da lista com hints", "cassandra1,cassandra3", map[string]int{"cassandra3": 2}, false, "cassandra1,cassandra3"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			verificador := &verificadorDeTeste{hints: c.hints}
+			s := &ServicoDeInjecaoDeFalhas{Verificador: verificador, NosDoCluster: nos}
+			drenados, detalhe := s.avaliarCondicao(context.Background(), p.EtapaDoPlano{Acao: "aguardar", Condicao: "hints_drenados", NomeDoContainer: c.container})
+			if drenados != c.drenados {
+				t.Fatalf("drenados = %v (%s), esperado %v", drenados, detalhe, c.drenados)
+			}
+			if consultado := strings.Join(verificador.consultado, ","); consultado != c.consultado {
+				t.Fatalf("consultados = %s, esperado %s", consultado, c.consultado)
+			}
+		})
+	}
+}
+
+func TestHintsDrenadosSemNosExigeLista(t *testing.T) {
+	s := &ServicoDeInjecaoDeFalhas{Verificador: &verificadorDeTeste{}}
+	plano := []p.EtapaDoPlano{{Acao: "aguardar", Condicao: "hints_drenados"}}
+	if err := s.VerificarPreRequisitos(context.Background(), plano); err == nil {
+		t.Fatal("esperado erro sem container nem nos do cluster")
+	}
+}
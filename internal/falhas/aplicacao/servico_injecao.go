@@ -2,21 +2,69 @@ package aplicacao
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"sort"
+	"sync"
 	"time"
 
 	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
 )
 
+// Tempo máximo da reversão automática (executada mesmo com o contexto do plano cancelado).
+const tempoLimiteDaReversao = 2 * time.Minute
+
 type ServicoDeInjecaoDeFalhas struct {
-	Orquestrador p.PortaDeOrquestracaoDeFalhas
+	Orquestrador       p.PortaDeOrquestracaoDeFalhas
+	Eventos            p.PortaDeRegistroDeEventos    // opcional
+	ReverterAoConcluir bool                          // também desfaz falhas pendentes quando o plano termina sem erro
+	Verificador        p.PortaDeVerificacaoDoCluster // exigido por etapas aguardar
+	NosDoCluster       []string                      // containers somados por aguardar hints_drenados sem container
+
+	mu           sync.Mutex
+	diario       []RegistroDoDiario
+	pendentes    []falhaPendente
+	recuperacoes []MedicaoDeRecuperacao
+	ultimaAcao   time.Time // início da recuperação medida pela próxima etapa aguardar
+}
+
+// Entrada do diário: toda ação aplicada (do plano ou da reversão), com o resultado.
+type RegistroDoDiario struct {
+	Momento      time.Time
+	Etapa        p.EtapaDoPlano
+	Reversao     bool
+	Erro         string
+	EstadoAntes  string // só nas ações do nodetool
+	EstadoDepois string
+	IpsDoRemoto  []string // só em bloquear/desbloquear_trafego
 }
 
-func (s *ServicoDeInjecaoDeFalhas) ExecutarPlano(ctx context.Context, plano []p.EtapaDoPlano) error {
-	// Ordena por MomentoRelativoSegundos para execução determinística
-	etapas := append([]p.EtapaDoPlano(nil), plano...)
-	sort.Slice(etapas, func(i, j int) bool { return etapas[i].MomentoRelativoSegundos < etapas[j].MomentoRelativoSegundos })
+// Falha aplicada e ainda não desfeita (ex.: container pausado).
+type falhaPendente struct {
+	chave       string
+	etapa       p.EtapaDoPlano
+	ipsDoRemoto []string // IPs bloqueados, reusados no desbloqueio
+}
+
+func (s *ServicoDeInjecaoDeFalhas) ExecutarPlano(ctx context.Context, plano []p.EtapaDoPlano) (err error) {
+	if err := ValidarPlano(plano); err != nil {
+		return fmt.Errorf("plano invalido: %w", err)
+	}
+	if err := s.VerificarPreRequisitos(ctx, plano); err != nil {
+		return fmt.Errorf("pre-checagem: %w", err)
+	}
+	// Expande repetições/durações e ordena por MomentoRelativoSegundos para execução determinística
+	etapas := ExpandirPlano(plano)
+
+	// Em erro, cancelamento (Ctrl-C) ou, se configurado, ao concluir: desfaz o que ficou pendente
+	defer func() {
+		if err == nil && !s.ReverterAoConcluir {
+			return
+		}
+		if errReversao := s.ReverterFalhasPendentes(ctx); errReversao != nil {
+			err = errors.Join(err, fmt.Errorf("reversao: %w", errReversao))
+		}
+	}()
+
 	inicio := time.Now()
 	for _, e := range etapas {
 		alvo := inicio.Add(time.Duration(e.MomentoRelativoSegundos) * time.Second)
@@ -27,24 +75,227 @@ func (s *ServicoDeInjecaoDeFalhas) ExecutarPlano(ctx context.Context, plano []p.
 			case <-time.After(atraso):
 			}
 		}
-		var err error
-		switch e.Acao {
-		case "pausar":
-			err = s.Orquestrador.PausarNo(ctx, e.NomeDoContainer)
-		case "continuar":
-			err = s.Orquestrador.ContinuarNo(ctx, e.NomeDoContainer)
-		case "parar":
-			err = s.Orquestrador.PararNo(ctx, e.NomeDoContainer, e.TimeoutSegundos)
-		case "desconectar":
-			err = s.Orquestrador.DesconectarNoDaRede(ctx, e.NomeDoContainer, e.NomeDaRede, true)
-		case "reconectar":
-			err = s.Orquestrador.ReconectarNoARede(ctx, e.NomeDoContainer, e.NomeDaRede)
-		default:
-			err = fmt.Errorf("acao desconhecida: %s", e.Acao)
-		}
-		if err != nil {
+		if err := s.executarEtapa(ctx, e, alvo, false); err != nil {
+			// Cluster sem convergir é um resultado do experimento, não motivo para abortar o plano
+			if errors.Is(err, ErrCondicaoNaoAtingida) {
+				fmt.Printf("[falhas] %v\n", err)
+				continue
+			}
 			return fmt.Errorf("falha na etapa %+v: %w", e, err)
 		}
 	}
 	return nil
 }
+
+// Desfaz, em ordem inversa, as falhas aplicadas e ainda pendentes. Usa um contexto próprio
+// para funcionar mesmo após o cancelamento do plano.
+func (s *ServicoDeInjecaoDeFalhas) ReverterFalhasPendentes(ctx context.Context) error {
+	ctxReversao, cancelar := context.WithTimeout(context.WithoutCancel(ctx), tempoLimiteDaReversao)
+	defer cancelar()
+	s.mu.Lock()
+	pendentes := append([]falhaPendente(nil), s.pendentes...)
+	s.mu.Unlock()
+
+	var erros []error
+	for i := len(pendentes) - 1; i >= 0; i-- {
+		inversa := pendentes[i].etapa
+		inversa.Acao = acoesInversas[inversa.Acao]
+		if err := s.executarEtapa(ctxReversao, inversa, time.Now(), true); err != nil {
+			erros = append(erros, fmt.Errorf("%s %s: %w", inversa.Acao, inversa.NomeDoContainer, err))
+		}
+	}
+	return errors.Join(erros...)
+}
+
+// Confere, antes de qualquer falha, os privilégios de rede dos containers usados por tc/iptables.
+func (s *ServicoDeInjecaoDeFalhas) VerificarPreRequisitos(ctx context.Context, plano []p.EtapaDoPlano) error {
+	verificados := map[string]bool{}
+	var erros []error
+	for _, e := range plano {
+		if e.Acao == "aguardar" && s.Verificador == nil && !verificados["aguardar"] {
+			verificados["aguardar"] = true
+			erros = append(erros, fmt.Errorf("etapas aguardar exigem verificador do cluster"))
+		}
+		if e.Acao == "aguardar" && e.Condicao == "hints_drenados" && len(s.containersDosHints(e)) == 0 && !verificados["hints"] {
+			verificados["hints"] = true
+			erros = append(erros, fmt.Errorf("aguardar hints_drenados sem container exige a lista de nos do cluster"))
+		}
+		ferramenta := ferramentaDeRede(e.Acao)
+		chave := e.NomeDoContainer + "/" + ferramenta
+		if ferramenta == "" || verificados[chave] {
+			continue
+		}
+		verificados[chave] = true
+		if err := s.Orquestrador.VerificarPrivilegiosDeRede(ctx, e.NomeDoContainer, ferramenta); err != nil {
+			erros = append(erros, err)
+		}
+	}
+	return errors.Join(erros...)
+}
+
+// Cópia do diário de ações aplicadas.
+func (s *ServicoDeInjecaoDeFalhas) Diario() []RegistroDoDiario {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]RegistroDoDiario(nil), s.diario...)
+}
+
+func (s *ServicoDeInjecaoDeFalhas) executarEtapa(ctx context.Context, e p.EtapaDoPlano, alvo time.Time, reversao bool) error {
+	registro := RegistroDoDiario{Etapa: e, Reversao: reversao}
+	comEstado := acaoDoNodetool(e.Acao)
+	if comEstado {
+		registro.EstadoAntes = estadoDoNo(ctx, s.Orquestrador, e.NomeDoContainer)
+	}
+	momentoReal := time.Now()
+	var (
+		err     error
+		medicao MedicaoDeRecuperacao
+	)
+	switch e.Acao {
+	case "aguardar":
+		medicao, err = s.aguardarCondicao(ctx, e)
+	case "bloquear_trafego":
+		registro.IpsDoRemoto, err = s.Orquestrador.BloquearTrafegoEntreNos(ctx, e.NomeDoContainer, e.NomeDoContainerRemoto, e.Direcao)
+	case "desbloquear_trafego":
+		registro.IpsDoRemoto = s.ipsBloqueados(e)
+		err = s.Orquestrador.DesbloquearTrafegoEntreNos(ctx, e.NomeDoContainer, e.NomeDoContainerRemoto, e.Direcao, registro.IpsDoRemoto)
+	default:
+		err = s.aplicarAcao(ctx, e)
+	}
+	duracao := time.Since(momentoReal)
+	if comEstado {
+		registro.EstadoDepois = estadoDoNo(ctx, s.Orquestrador, e.NomeDoContainer)
+	}
+
+	registro.Momento = momentoReal
+	if err != nil {
+		registro.Erro = err.Error()
+	}
+	s.mu.Lock()
+	s.diario = append(s.diario, registro)
+	if e.Acao == "aguardar" {
+		if medicao.Tentativas > 0 {
+			s.recuperacoes = append(s.recuperacoes, medicao)
+		}
+	} else {
+		s.ultimaAcao = momentoReal
+	}
+	if err == nil {
+		s.atualizarPendentes(e, registro.IpsDoRemoto)
+	}
+	s.mu.Unlock()
+
+	if s.Eventos != nil {
+		evento := p.EventoDeEtapa{
+			Etapa: e, MomentoPlanejado: alvo, MomentoReal: momentoReal, Duracao: duracao, Erro: registro.Erro, Reversao: reversao,
+			EstadoAntes: registro.EstadoAntes, EstadoDepois: registro.EstadoDepois,
+		}
+		if medicao.Recuperado {
+			evento.TempoAteRecuperacao = medicao.TempoAteRecuperacao
+		}
+		s.Eventos.RegistrarEvento(evento)
+	}
+	return err
+}
+
+func (s *ServicoDeInjecaoDeFalhas) aplicarAcao(ctx context.Context, e p.EtapaDoPlano) error {
+	switch e.Acao {
+	case "pausar":
+		return s.Orquestrador.PausarNo(ctx, e.NomeDoContainer)
+	case "continuar":
+		return s.Orquestrador.ContinuarNo(ctx, e.NomeDoContainer)
+	case "parar":
+		return s.Orquestrador.PararNo(ctx, e.NomeDoContainer, e.TimeoutSegundos)
+	case "iniciar":
+		return s.Orquestrador.IniciarNo(ctx, e.NomeDoContainer)
+	case "matar":
+		return s.Orquestrador.MatarNo(ctx, e.NomeDoContainer, e.Sinal)
+	case "reiniciar":
+		return s.Orquestrador.ReiniciarNo(ctx, e.NomeDoContainer, e.TimeoutSegundos)
+	case "desconectar":
+		return s.Orquestrador.DesconectarNoDaRede(ctx, e.NomeDoContainer, e.NomeDaRede, true)
+	case "reconectar":
+		return s.Orquestrador.ReconectarNoARede(ctx, e.NomeDoContainer, e.NomeDaRede)
+	case "degradar_rede":
+		if e.Degradacao == nil {
+			return fmt.Errorf("degradacao obrigatoria")
+		}
+		return s.Orquestrador.AplicarDegradacaoDeRede(ctx, e.NomeDoContainer, *e.Degradacao)
+	case "limpar_rede":
+		interfaceDeRede := ""
+		if e.Degradacao != nil {
+			interfaceDeRede = e.Degradacao.Interface
+		}
+		return s.Orquestrador.RemoverDegradacaoDeRede(ctx, e.NomeDoContainer, interfaceDeRede)
+	default:
+		if argumentos, emSegundoPlano, ok := argumentosDoNodetool(e); ok {
+			_, err := s.Orquestrador.ExecutarNodetool(ctx, e.NomeDoContainer, emSegundoPlano, argumentos...)
+			return err
+		}
+		return fmt.Errorf("acao desconhecida: %s", e.Acao)
+	}
+}
+
+// Deve ser chamado com o mutex travado. Ações que causam falha entram na lista de pendentes;
+// ações que restauram o nó removem a falha correspondente.
+func (s *ServicoDeInjecaoDeFalhas) atualizarPendentes(e p.EtapaDoPlano, ipsDoRemoto []string) {
+	chave, aplica := chaveDaFalha(e)
+	if chave == "" {
+		return
+	}
+	for i, f := range s.pendentes {
+		if f.chave == chave {
+			if !aplica {
+				s.pendentes = append(s.pendentes[:i], s.pendentes[i+1:]...)
+			}
+			return
+		}
+	}
+	if aplica {
+		s.pendentes = append(s.pendentes, falhaPendente{chave: chave, etapa: e, ipsDoRemoto: ipsDoRemoto})
+	}
+}
+
+// IPs gravados pelo bloqueio pendente correspondente ao desbloqueio (nil se não houver).
+func (s *ServicoDeInjecaoDeFalhas) ipsBloqueados(e p.EtapaDoPlano) []string {
+	chave, _ := chaveDaFalha(e)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.pendentes {
+		if f.chave == chave {
+			return f.ipsDoRemoto
+		}
+	}
+	return nil
+}
+
+// Estado do nó afetado pela ação e se a ação o degrada (true) ou restaura (false).
+func chaveDaFalha(e p.EtapaDoPlano) (string, bool) {
+	switch e.Acao {
+	case "pausar", "continuar":
+		return "pausa/" + e.NomeDoContainer, e.Acao == "pausar"
+	case "parar", "matar", "drenar", "iniciar", "reiniciar":
+		// Nó drenado fica fora de serviço até reiniciar, como um nó parado
+		return "parado/" + e.NomeDoContainer, e.Acao == "parar" || e.Acao == "matar" || e.Acao == "drenar"
+	case "desconectar", "reconectar":
+		return "rede/" + e.NomeDoContainer + "/" + e.NomeDaRede, e.Acao == "desconectar"
+	case "degradar_rede", "limpar_rede":
+		return "netem/" + e.NomeDoContainer, e.Acao == "degradar_rede"
+	case "bloquear_trafego", "desbloquear_trafego":
+		direcao := e.Direcao
+		if direcao == "" {
+			direcao = p.DirecaoAmbas
+		}
+		return "iptables/" + e.NomeDoContainer + "/" + e.NomeDoContainerRemoto + "/" + direcao, e.Acao == "bloquear_trafego"
+	case "desabilitar_binario", "habilitar_binario":
+		return "binario/" + e.NomeDoContainer, e.Acao == "desabilitar_binario"
+	case "desabilitar_gossip", "habilitar_gossip":
+		return "gossip/" + e.NomeDoContainer, e.Acao == "desabilitar_gossip"
+	case "desabilitar_handoff", "habilitar_handoff":
+		return "handoff/" + e.NomeDoContainer, e.Acao == "desabilitar_handoff"
+	case "compactar", "interromper_compactacao":
+		return "compactacao/" + e.NomeDoContainer, e.Acao == "compactar"
+	default:
+		return "", false
+	}
+}
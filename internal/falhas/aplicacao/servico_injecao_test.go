@@ -0,0 +1,223 @@
+package aplicacao
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	p "github.com/pdrpinto/tcc-cassandra/internal/falhas/portas"
+)
+
+// Orquestrador que registra as ações aplicadas ("acao container[ detalhe]") e falha nas ações de "falhar".
+type orquestradorDeTeste struct {
+	acoes  []string
+	falhar map[string]bool // ex.: "bloquear_trafego cassandra1"
+}
+
+func (o *orquestradorDeTeste) registrar(acao, container, detalhe string) error {
+	chave := acao + " " + container
+	if o.falhar[chave] {
+		return fmt.Errorf("%s: falha simulada", chave)
+	}
+	if detalhe != "" {
+		chave += " " + detalhe
+	}
+	o.acoes = append(o.acoes, chave)
+	return nil
+}
+
+func (o *orquestradorDeTeste) PausarNo(_ context.Context, c string) error {
+	return o.registrar("pausar", c, "")
+}
+
+func (o *orquestradorDeTeste) ContinuarNo(_ context.Context, c string) error {
+	return o.registrar("continuar", c, "")
+}
+
+func (o *orquestradorDeTeste) PararNo(_ context.Context, c string, _ int) error {
+	return o.registrar("parar", c, "")
+}
+
+func (o *orquestradorDeTeste) IniciarNo(_ context.Context, c string) error {
+	return o.registrar("iniciar", c, "")
+}
+
+func (o *orquestradorDeTeste) MatarNo(_ context.Context, c string, _ string) error {
+	return o.registrar("matar", c, "")
+}
+
+func (o *orquestradorDeTeste) ReiniciarNo(_ context.Context, c string, _ int) error {
+	return o.registrar("reiniciar", c, "")
+}
+
+func (o *orquestradorDeTeste) DesconectarNoDaRede(_ context.Context, c, rede string, _ bool) error {
+	return o.registrar("desconectar", c, rede)
+}
+
+func (o *orquestradorDeTeste) ReconectarNoARede(_ context.Context, c, rede string) error {
+	return o.registrar("reconectar", c, rede)
+}
+
+func (o *orquestradorDeTeste) AplicarDegradacaoDeRede(_ context.Context, c string, _ p.DegradacaoDeRede) error {
+	return o.registrar("degradar_rede", c, "")
+}
+
+func (o *orquestradorDeTeste) RemoverDegradacaoDeRede(_ context.Context, c, _ string) error {
+	return o.registrar("limpar_rede", c, "")
+}
+
+func (o *orquestradorDeTeste) BloquearTrafegoEntreNos(_ context.Context, c, remoto, direcao string) ([]string, error) {
+	if err := o.registrar("bloquear_trafego", c, remoto+" "+direcao); err != nil {
+		return nil, err
+	}
+	return []string{"10.0.0." + strings.TrimPrefix(remoto, "cassandra")}, nil
+}
+
+func (o *orquestradorDeTeste) DesbloquearTrafegoEntreNos(_ context.Context, c, remoto, direcao string, ips []string) error {
+	return o.registrar("desbloquear_trafego", c, remoto+" "+direcao+" "+strings.Join(ips, ","))
+}
+
+func (o *orquestradorDeTeste) VerificarPrivilegiosDeRede(context.Context, string, string) error {
+	return nil
+}
+
+// Consultas de estado (info, netstats...) não são registradas; só as ações do nodetool.
+func (o *orquestradorDeTeste) ExecutarNodetool(_ context.Context, c string, _ bool, argumentos ...string) (string, error) {
+	switch argumentos[0] {
+	case "info", "statushandoff", "netstats", "compactionstats":
+		return "", nil
+	}
+	return "", o.registrar("nodetool", c, strings.Join(argumentos, " "))
+}
+
+func TestChaveDaFalhaCasaComAInversa(t *testing.T) {
+	for acao, inversa := range acoesInversas {
+		e := p.EtapaDoPlano{Acao: acao, NomeDoContainer: "cassandra2", NomeDoContainerRemoto: "cassandra3", NomeDaRede: "tcc-net"}
+		chave, aplica := chaveDaFalha(e)
+		e.Acao = inversa
+		chaveDaInversa, aplicaInversa := chaveDaFalha(e)
+		if chave == "" || chave != chaveDaInversa || !aplica || aplicaInversa {
+			t.Errorf("%s -> %s: chaves %q/%q aplica %v/%v", acao, inversa, chave, chaveDaInversa, aplica, aplicaInversa)
+		}
+	}
+	// Direção vazia é "ambas": o desbloqueio explícito encontra o bloqueio padrão
+	padrao, _ := chaveDaFalha(p.EtapaDoPlano{Acao: "bloquear_trafego", NomeDoContainer: "a", NomeDoContainerRemoto: "b"})
+	explicita, _ := chaveDaFalha(p.EtapaDoPlano{Acao: "desbloquear_trafego", NomeDoContainer: "a", NomeDoContainerRemoto: "b", Direcao: p.DirecaoAmbas})
+	if padrao != explicita {
+		t.Errorf("direcao padrao %q != ambas %q", padrao, explicita)
+	}
+}
+
+func TestReverterFalhasPendentes(t *testing.T) {
+	casos := []struct {
+		nome     string
+		etapas   []p.EtapaDoPlano
+		falhar   map[string]bool
+		reversao []string
+	}{
+		{
+			nome: "desfaz em ordem inversa",
+			etapas: []p.EtapaDoPlano{
+				{Acao: "pausar", NomeDoContainer: "cassandra2"},
+				{Acao: "bloquear_trafego", NomeDoContainer: "cassandra1", NomeDoContainerRemoto: "cassandra3", Direcao: p.DirecaoAmbas},
+				{Acao: "drenar", NomeDoContainer: "cassandra3"},
+				{Acao: "desconectar", NomeDoContainer: "cassandra2", NomeDaRede: "tcc-net"},
+			},
+			reversao: []string{
+				"reconectar cassandra2 tcc-net",
+				"reiniciar cassandra3",
+				"desbloquear_trafego cassandra1 cassandra3 ambas 10.0.0.3",
+				"continuar cassandra2",
+			},
+		},
+		{
+			nome: "falha ja restaurada pelo plano nao e revertida",
+			etapas: []p.EtapaDoPlano{
+				{Acao: "pausar", NomeDoContainer: "cassandra2"},
+				{Acao: "degradar_rede", NomeDoContainer: "cassandra3", Degradacao: &p.DegradacaoDeRede{AtrasoMs: 100}},
+				{Acao: "continuar", NomeDoContainer: "cassandra2"},
+			},
+			reversao: []string{"limpar_rede cassandra3"},
+		},
+		{
+			nome: "acao com erro nao fica pendente",
+			etapas: []p.EtapaDoPlano{
+				{Acao: "parar", NomeDoContainer: "cassandra2"},
+				{Acao: "bloquear_trafego", NomeDoContainer: "cassandra1", NomeDoContainerRemoto: "cassandra3"},
+			},
+			falhar:   map[string]bool{"bloquear_trafego cassandra1": true},
+			reversao: []string{"iniciar cassandra2"},
+		},
+		{
+			nome: "nodetool",
+			etapas: []p.EtapaDoPlano{
+				{Acao: "desabilitar_gossip", NomeDoContainer: "cassandra2"},
+				{Acao: "desabilitar_handoff", NomeDoContainer: "cassandra3"},
+				{Acao: "habilitar_handoff", NomeDoContainer: "cassandra3"},
+			},
+			reversao: []string{"nodetool cassandra2 enablegossip"},
+		},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			orq := &orquestradorDeTeste{falhar: c.falhar}
+			s := &ServicoDeInjecaoDeFalhas{Orquestrador: orq}
+			for _, e := range c.etapas {
+				_ = s.executarEtapa(context.Background(), e, time.Now(), false)
+			}
+			aplicadas := len(orq.acoes)
+			if err := s.ReverterFalhasPendentes(context.Background()); err != nil {
+				t.Fatalf("reversao: %v", err)
+			}
+			reversao := orq.acoes[aplicadas:]
+			if strings.Join(reversao, "; ") != strings.Join(c.reversao, "; ") {
+				t.Fatalf("reversao = %q\nesperada  %q", reversao, c.reversao)
+			}
+		})
+	}
+}
+
+func TestExecutarPlanoReverte(t *testing.T) {
+	plano := []p.EtapaDoPlano{
+		{Acao: "pausar", NomeDoContainer: "cassandra2"},
+		{Acao: "parar", NomeDoContainer: "cassandra3"},
+	}
+	casos := []struct {
+		nome       string
+		reverter   bool
+		falhar     map[string]bool
+		comErro    bool
+		ultimaAcao string
+	}{
+		{"ao concluir", true, nil, false, "continuar cassandra2"},
+		{"mantem ao concluir", false, nil, false, "parar cassandra3"},
+		{"sempre em erro", false, map[string]bool{"parar cassandra3": true}, true, "continuar cassandra2"},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			orq := &orquestradorDeTeste{falhar: c.falhar}
+			s := &ServicoDeInjecaoDeFalhas{Orquestrador: orq, ReverterAoConcluir: c.reverter}
+			err := s.ExecutarPlano(context.Background(), plano)
+			if (err != nil) != c.comErro {
+				t.Fatalf("err = %v", err)
+			}
+			if ultima := orq.acoes[len(orq.acoes)-1]; ultima != c.ultimaAcao {
+				t.Fatalf("acoes = %q, ultima esperada %q", orq.acoes, c.ultimaAcao)
+			}
+		})
+	}
+}
+
+func TestErroDaReversaoEPropagado(t *testing.T) {
+	orq := &orquestradorDeTeste{}
+	s := &ServicoDeInjecaoDeFalhas{Orquestrador: orq}
+	if err := s.executarEtapa(context.Background(), p.EtapaDoPlano{Acao: "pausar", NomeDoContainer: "cassandra2"}, time.Now(), false); err != nil {
+		t.Fatal(err)
+	}
+	orq.falhar = map[string]bool{"continuar cassandra2": true}
+	if err := s.ReverterFalhasPendentes(context.Background()); err == nil || !strings.Contains(err.Error(), "continuar cassandra2") {
+		t.Fatalf("err = %v", err)
+	}
+}
@@ -1,21 +1,105 @@
 package portas
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Porta para orquestração de falhas no ambiente (ex.: Docker Desktop)
 type PortaDeOrquestracaoDeFalhas interface {
 	PausarNo(ctx context.Context, nomeDoContainer string) error
 	ContinuarNo(ctx context.Context, nomeDoContainer string) error
 	PararNo(ctx context.Context, nomeDoContainer string, timeoutSegundos int) error
+	IniciarNo(ctx context.Context, nomeDoContainer string) error
+	MatarNo(ctx context.Context, nomeDoContainer string, sinal string) error // sinal vazio = SIGKILL
+	ReiniciarNo(ctx context.Context, nomeDoContainer string, timeoutSegundos int) error
 	DesconectarNoDaRede(ctx context.Context, nomeDoContainer string, nomeDaRede string, forcar bool) error
 	ReconectarNoARede(ctx context.Context, nomeDoContainer string, nomeDaRede string) error
+	AplicarDegradacaoDeRede(ctx context.Context, nomeDoContainer string, degradacao DegradacaoDeRede) error
+	RemoverDegradacaoDeRede(ctx context.Context, nomeDoContainer string, nomeDaInterface string) error
+	// Retorna os IPs do remoto bloqueados; o desbloqueio recebe os mesmos IPs (vazio = resolve os atuais).
+	BloquearTrafegoEntreNos(ctx context.Context, nomeDoContainer string, nomeDoContainerRemoto string, direcao string) ([]string, error)
+	DesbloquearTrafegoEntreNos(ctx context.Context, nomeDoContainer string, nomeDoContainerRemoto string, direcao string, ipsDoRemoto []string) error
+	// Pré-checagem: container (ou imagem auxiliar) com NET_ADMIN e a ferramenta ("tc" ou "iptables") disponível.
+	VerificarPrivilegiosDeRede(ctx context.Context, nomeDoContainer string, ferramenta string) error
+	// Executa nodetool no nó; em segundo plano não aguarda o término (ex.: compactação maior).
+	ExecutarNodetool(ctx context.Context, nomeDoContainer string, emSegundoPlano bool, argumentos ...string) (string, error)
+}
+
+// Opcional: orquestradores com redes nomeadas (Docker) confirmam que a rede existe antes do plano.
+type PortaDeVerificacaoDeRede interface {
+	VerificarRede(ctx context.Context, nomeDaRede string) error
+}
+
+// Direções do bloqueio entre dois nós, do ponto de vista do container da etapa.
+const (
+	DirecaoAmbas   = "ambas"   // nenhum pacote entre os dois nós
+	DirecaoSaida   = "saida"   // o container não alcança o remoto, mas recebe dele
+	DirecaoEntrada = "entrada" // o container não recebe do remoto, mas o alcança
+)
+
+// Degradação de enlace aplicada com tc netem na saída da interface do container.
+// Por atuar só na saída, degradar um único nó produz um enlace assimétrico.
+type DegradacaoDeRede struct {
+	AtrasoMs              int     `yaml:"atraso_ms,omitempty" json:"atraso_ms,omitempty"`
+	VariacaoMs            int     `yaml:"variacao_ms,omitempty" json:"variacao_ms,omitempty"` // jitter do atraso
+	PerdaPercentual       float64 `yaml:"perda_pct,omitempty" json:"perda_pct,omitempty"`
+	DuplicacaoPercentual  float64 `yaml:"duplicacao_pct,omitempty" json:"duplicacao_pct,omitempty"`
+	ReordenacaoPercentual float64 `yaml:"reordenacao_pct,omitempty" json:"reordenacao_pct,omitempty"` // exige atraso
+	LimiteDeBandaKbit     int     `yaml:"banda_kbit,omitempty" json:"banda_kbit,omitempty"`
+	Interface             string  `yaml:"interface,omitempty" json:"interface,omitempty"` // padrão eth0
 }
 
 // Plano de injeção de falhas com etapas sequenciadas no tempo.
 type EtapaDoPlano struct {
-	MomentoRelativoSegundos int
-	Acao                    string // "pausar", "continuar", "parar", "desconectar", "reconectar"
-	NomeDoContainer         string
-	NomeDaRede              string // usado para desconectar/reconectar
-	TimeoutSegundos         int    // usado para parar
+	MomentoRelativoSegundos int               `yaml:"momento_s" json:"momento_s"`
+	Acao                    string            `yaml:"acao" json:"acao"`                                     // "pausar", "continuar", "parar", "iniciar", "matar", "reiniciar", "desconectar", "reconectar", "degradar_rede", "limpar_rede", "bloquear_trafego", "desbloquear_trafego", "desabilitar_binario", "habilitar_binario", "desabilitar_gossip", "habilitar_gossip", "desabilitar_handoff", "habilitar_handoff", "drenar", "compactar", "interromper_compactacao", "aguardar"
+	NomeDoContainer         string            `yaml:"container" json:"container"`                           // em aguardar hints_drenados: lista separada por vírgula (vazio = todos os nós)
+	NomeDaRede              string            `yaml:"rede,omitempty" json:"rede,omitempty"`                 // usado para desconectar/reconectar
+	TimeoutSegundos         int               `yaml:"timeout_s,omitempty" json:"timeout_s,omitempty"`       // usado para parar/reiniciar e aguardar
+	Sinal                   string            `yaml:"sinal,omitempty" json:"sinal,omitempty"`               // usado para matar (ex.: SIGKILL, SIGTERM)
+	NomeDoContainerRemoto   string            `yaml:"remoto,omitempty" json:"remoto,omitempty"`             // usado para bloquear/desbloquear_trafego
+	Direcao                 string            `yaml:"direcao,omitempty" json:"direcao,omitempty"`           // ambas (padrão), saida ou entrada
+	Degradacao              *DegradacaoDeRede `yaml:"degradacao,omitempty" json:"degradacao,omitempty"`     // usado para degradar_rede (interface também em limpar_rede)
+	Keyspace                string            `yaml:"keyspace,omitempty" json:"keyspace,omitempty"`         // usado para compactar (vazio = todos)
+	Tabelas                 []string          `yaml:"tabelas,omitempty" json:"tabelas,omitempty"`           // usado para compactar (exige keyspace)
+	Condicao                string            `yaml:"condicao,omitempty" json:"condicao,omitempty"`         // usado para aguardar: nos_un, schema_acordo, hints_drenados ou consulta
+	Consulta                string            `yaml:"consulta,omitempty" json:"consulta,omitempty"`         // usado para aguardar consulta (CQL)
+	Consistencia            string            `yaml:"consistencia,omitempty" json:"consistencia,omitempty"` // usado para aguardar consulta (padrão QUORUM)
+
+	// Opcionais: repetições da etapa e duração após a qual a ação inversa é executada.
+	Repeticoes                       int `yaml:"repeticoes,omitempty" json:"repeticoes,omitempty"` // total de execuções (0 ou 1 = uma vez)
+	IntervaloEntreRepeticoesSegundos int `yaml:"intervalo_s,omitempty" json:"intervalo_s,omitempty"`
+	DuracaoSegundos                  int `yaml:"duracao_s,omitempty" json:"duracao_s,omitempty"` // 0 = sem inversão automática
+}
+
+// Registro do que aconteceu em cada etapa executada.
+type EventoDeEtapa struct {
+	Etapa            EtapaDoPlano  `json:"etapa"`
+	MomentoPlanejado time.Time     `json:"momento_planejado"`
+	MomentoReal      time.Time     `json:"momento_real"`
+	Duracao          time.Duration `json:"duracao_ns"`
+	Erro             string        `json:"erro,omitempty"`
+	Reversao         bool          `json:"reversao,omitempty"` // ação executada pela reversão automática
+	// Estado do nó segundo o nodetool antes e depois das ações do nodetool (ex.: "modo=NORMAL gossip=on binario=off").
+	EstadoAntes  string `json:"estado_antes,omitempty"`
+	EstadoDepois string `json:"estado_depois,omitempty"`
+	// Só em aguardar: tempo desde a última ação do plano até a condição ser atendida.
+	TempoAteRecuperacao time.Duration `json:"tempo_ate_recuperacao_ns,omitempty"`
+}
+
+// Porta para verificar a convergência do cluster nas etapas "aguardar".
+type PortaDeVerificacaoDoCluster interface {
+	// Estado de cada nó (endereço -> UN, DN, UJ...) segundo o nodetool status executado no nó indicado.
+	EstadosDosNos(ctx context.Context, nomeDoContainer string) (map[string]string, error)
+	// Quantidade de nós destino com hints pendentes no nó indicado.
+	NosComHintsPendentes(ctx context.Context, nomeDoContainer string) (int, error)
+	// Versões de schema distintas vistas pelo coordenador (system.local e system.peers).
+	VersoesDeSchema(ctx context.Context) ([]string, error)
+	ExecutarConsulta(ctx context.Context, consulta string, consistencia string) error
+}
+
+// Porta para registrar eventos das etapas (linha do tempo, arquivos, métricas).
+type PortaDeRegistroDeEventos interface {
+	RegistrarEvento(evento EventoDeEtapa)
 }
@@ -0,0 +1,160 @@
+package historico
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Tipos de evento: toda operação gera uma invocação e, ao terminar, uma conclusão com o mesmo Op.
+const (
+	EventoInvocacao = "invocacao"
+	EventoConclusao = "conclusao"
+)
+
+const (
+	OperacaoEscrita = "escrita"
+	OperacaoLeitura = "leitura"
+)
+
+// Resultado da conclusão. Escrita indeterminada (timeout, erro de rede) pode ou não ter sido aplicada.
+const (
+	ResultadoOk            = "ok"
+	ResultadoFalha         = "falha"
+	ResultadoIndeterminado = "indeterminado"
+)
+
+// Evento do histórico (uma linha JSONL).
+type Evento struct {
+	Indice   int64     `json:"indice"` // ordem de gravação no arquivo
+	Tipo     string    `json:"tipo"`
+	Op       int64     `json:"op"` // liga invocação e conclusão
+	Cliente  int       `json:"cliente"`
+	Operacao string    `json:"operacao"`
+	Sensor   string    `json:"sensor"`
+	Instante time.Time `json:"instante"`
+	// Escrita: valor escrito (marcador único) e instante da leitura gravada, que decide a ordem no Cassandra.
+	// Leitura (na conclusão): valor e instante da última leitura devolvida ("" = sensor sem leituras).
+	Valor        string    `json:"valor,omitempty"`
+	InstanteDado time.Time `json:"instante_dado,omitempty"`
+	Resultado    string    `json:"resultado,omitempty"` // só na conclusão
+	Erro         string    `json:"erro,omitempty"`
+	Final        bool      `json:"final,omitempty"` // leitura da fase final, após a carga
+	// Leitura final: todos os valores presentes no sensor (mais novo primeiro); nil = só o mais novo foi lido.
+	Valores []string `json:"valores,omitempty"`
+	// Leitura final cortada pelo limite da consulta: instante da linha mais antiga devolvida.
+	TruncadaEm time.Time `json:"truncada_em,omitempty"`
+}
+
+// Grava o histórico em JSONL, na ordem em que os eventos acontecem; seguro para uso concorrente.
+type GravadorDeHistorico struct {
+	mu      sync.Mutex
+	arquivo *os.File
+	saida   *bufio.Writer
+	indice  int64
+	op      int64
+	erro    error
+}
+
+func NovoGravadorDeHistorico(caminho string) (*GravadorDeHistorico, error) {
+	arquivo, err := os.Create(caminho)
+	if err != nil {
+		return nil, err
+	}
+	return &GravadorDeHistorico{arquivo: arquivo, saida: bufio.NewWriter(arquivo)}, nil
+}
+
+// Registra a invocação e retorna o identificador da operação para a conclusão.
+func (g *GravadorDeHistorico) Invocar(cliente int, operacao, sensor, valor string, instanteDado time.Time) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.op++
+	g.gravar(Evento{Tipo: EventoInvocacao, Op: g.op, Cliente: cliente, Operacao: operacao, Sensor: sensor, Valor: valor, InstanteDado: instanteDado})
+	return g.op
+}
+
+// Registra a conclusão; em leituras, valor e instanteDado são o que foi lido.
+func (g *GravadorDeHistorico) Concluir(op int64, cliente int, operacao, sensor, resultado, valor string, instanteDado time.Time, erro error) {
+	g.concluir(Evento{Op: op, Cliente: cliente, Operacao: operacao, Sensor: sensor, Resultado: resultado, Valor: valor, InstanteDado: instanteDado}, erro)
+}
+
+// Conclusão da leitura final com todos os valores do sensor (valores[0] é o mais novo).
+func (g *GravadorDeHistorico) ConcluirLeituraFinal(op int64, cliente int, sensor string, valores []string, instanteDado, truncadaEm time.Time) {
+	evento := Evento{Op: op, Cliente: cliente, Operacao: OperacaoLeitura, Sensor: sensor, Resultado: ResultadoOk, Final: true,
+		InstanteDado: instanteDado, Valores: append([]string{}, valores...), TruncadaEm: truncadaEm}
+	if len(valores) > 0 {
+		evento.Valor = valores[0]
+	}
+	g.concluir(evento, nil)
+}
+
+func (g *GravadorDeHistorico) concluir(evento Evento, erro error) {
+	evento.Tipo = EventoConclusao
+	if erro != nil {
+		evento.Erro = erro.Error()
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gravar(evento)
+}
+
+// Marca a operação como leitura final (estado do cluster após a carga).
+func (g *GravadorDeHistorico) InvocarLeituraFinal(cliente int, sensor string) int64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.op++
+	g.gravar(Evento{Tipo: EventoInvocacao, Op: g.op, Cliente: cliente, Operacao: OperacaoLeitura, Sensor: sensor, Final: true})
+	return g.op
+}
+
+// Deve ser chamado com o mutex travado; o instante é tomado aqui para respeitar a ordem do arquivo.
+func (g *GravadorDeHistorico) gravar(evento Evento) {
+	g.indice++
+	evento.Indice = g.indice
+	evento.Instante = time.Now().UTC()
+	linha, err := json.Marshal(evento)
+	if err == nil {
+		linha = append(linha, '\n')
+		_, err = g.saida.Write(linha)
+	}
+	if err != nil && g.erro == nil {
+		g.erro = err
+	}
+}
+
+func (g *GravadorDeHistorico) Fechar() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if err := g.saida.Flush(); err != nil && g.erro == nil {
+		g.erro = err
+	}
+	if err := g.arquivo.Close(); err != nil && g.erro == nil {
+		g.erro = err
+	}
+	return g.erro
+}
+
+func CarregarHistorico(caminho string) ([]Evento, error) {
+	arquivo, err := os.Open(caminho)
+	if err != nil {
+		return nil, err
+	}
+	defer arquivo.Close()
+	var eventos []Evento
+	leitor := bufio.NewScanner(arquivo)
+	leitor.Buffer(make([]byte, 64*1024), 1<<20)
+	for linha := 1; leitor.Scan(); linha++ {
+		if len(leitor.Bytes()) == 0 {
+			continue
+		}
+		var evento Evento
+		if err := json.Unmarshal(leitor.Bytes(), &evento); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", caminho, linha, err)
+		}
+		eventos = append(eventos, evento)
+	}
+	return eventos, leitor.Err()
+}
@@ -0,0 +1,297 @@
+package historico
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Tipos de anomalia apontados pelo verificador.
+const (
+	AnomaliaEscritaPerdida       = "escrita_perdida"        // escrita confirmada ausente do estado final
+	AnomaliaLeituraDesatualizada = "leitura_desatualizada"  // leitura mais antiga que uma escrita confirmada há mais que o limite
+	AnomaliaLeituraNaoMonotonica = "leitura_nao_monotonica" // o mesmo cliente lê um valor mais antigo que o já lido
+	AnomaliaValorDesconhecido    = "valor_desconhecido"     // leitura de um valor que nenhuma escrita invocou
+)
+
+// Operação do histórico: invocação e conclusão combinadas.
+type Operacao struct {
+	Op           int64     `json:"op"`
+	Cliente      int       `json:"cliente"`
+	Operacao     string    `json:"operacao"`
+	Sensor       string    `json:"sensor"`
+	Valor        string    `json:"valor,omitempty"`
+	InstanteDado time.Time `json:"instante_dado,omitempty"`
+	Inicio       time.Time `json:"inicio"`
+	Fim          time.Time `json:"fim,omitempty"` // zero = sem conclusão no histórico
+	Resultado    string    `json:"resultado"`
+	Erro         string    `json:"erro,omitempty"`
+	Final        bool      `json:"final,omitempty"`
+	Valores      []string  `json:"valores,omitempty"`     // leitura final completa
+	TruncadaEm   time.Time `json:"truncada_em,omitempty"` // leitura final cortada: linha mais antiga devolvida
+}
+
+func (o Operacao) String() string {
+	fim := "?"
+	if !o.Fim.IsZero() {
+		fim = o.Fim.Format("15:04:05.000000")
+	}
+	valor := o.Valor
+	if valor == "" {
+		valor = "(vazio)"
+	}
+	texto := fmt.Sprintf("op=%d cliente=%d %s %s [%s .. %s] %s valor=%s", o.Op, o.Cliente, o.Operacao, o.Sensor,
+		o.Inicio.Format("15:04:05.000000"), fim, o.Resultado, valor)
+	if !o.InstanteDado.IsZero() {
+		texto += " dado=" + o.InstanteDado.Format(time.RFC3339Nano)
+	}
+	if o.Final {
+		texto += " (final)"
+	}
+	return texto
+}
+
+// Anomalia com o contraexemplo: as operações exatas envolvidas.
+type Anomalia struct {
+	Tipo            string        `json:"tipo"`
+	Sensor          string        `json:"sensor"`
+	Descricao       string        `json:"descricao"`
+	Operacoes       []Operacao    `json:"operacoes"`
+	AtrasoDaEscrita time.Duration `json:"atraso_ns,omitempty"` // leitura desatualizada: tempo desde a confirmação da escrita
+}
+
+type RelatorioDaVerificacao struct {
+	Operacoes               int            `json:"operacoes"`
+	EscritasConfirmadas     int            `json:"escritas_confirmadas"`
+	EscritasIncertas        int            `json:"escritas_incertas"` // indeterminadas ou sem conclusão
+	Leituras                int            `json:"leituras"`
+	SensoresSemLeituraFinal []string       `json:"sensores_sem_leitura_final,omitempty"` // escrita perdida não verificada
+	EscritasForaDaFinal     int            `json:"escritas_fora_da_final,omitempty"`     // anteriores ao corte de uma leitura final truncada
+	LimiteDeDesatualizacao  time.Duration  `json:"limite_de_desatualizacao_ns"`
+	AnomaliasPorTipo        map[string]int `json:"anomalias_por_tipo"`
+	Anomalias               []Anomalia     `json:"anomalias"`
+}
+
+// Combina invocações e conclusões pelo identificador da operação, na ordem das invocações.
+func MontarOperacoes(eventos []Evento) ([]Operacao, error) {
+	porOp := map[int64]int{}
+	var operacoes []Operacao
+	for _, e := range eventos {
+		switch e.Tipo {
+		case EventoInvocacao:
+			porOp[e.Op] = len(operacoes)
+			operacoes = append(operacoes, Operacao{Op: e.Op, Cliente: e.Cliente, Operacao: e.Operacao, Sensor: e.Sensor,
+				Valor: e.Valor, InstanteDado: e.InstanteDado, Inicio: e.Instante, Resultado: ResultadoIndeterminado, Final: e.Final})
+		case EventoConclusao:
+			i, ok := porOp[e.Op]
+			if !ok {
+				return nil, fmt.Errorf("evento %d: conclusao da op %d sem invocacao", e.Indice, e.Op)
+			}
+			o := &operacoes[i]
+			o.Fim, o.Resultado, o.Erro = e.Instante, e.Resultado, e.Erro
+			if o.Operacao == OperacaoLeitura {
+				o.Valor, o.InstanteDado, o.Valores, o.TruncadaEm = e.Valor, e.InstanteDado, e.Valores, e.TruncadaEm
+			}
+		default:
+			return nil, fmt.Errorf("evento %d: tipo desconhecido %q", e.Indice, e.Tipo)
+		}
+	}
+	return operacoes, nil
+}
+
+// Analisa o histórico. Uma leitura iniciada mais de "limite" após a confirmação de uma escrita
+// deve devolver essa escrita ou uma mais nova (pela ordem do instante do dado, como no Cassandra).
+func Verificar(eventos []Evento, limite time.Duration) (RelatorioDaVerificacao, error) {
+	operacoes, err := MontarOperacoes(eventos)
+	if err != nil {
+		return RelatorioDaVerificacao{}, err
+	}
+	relatorio := RelatorioDaVerificacao{Operacoes: len(operacoes), LimiteDeDesatualizacao: limite, AnomaliasPorTipo: map[string]int{}}
+	anotar := func(a Anomalia) {
+		relatorio.Anomalias = append(relatorio.Anomalias, a)
+		relatorio.AnomaliasPorTipo[a.Tipo]++
+	}
+
+	// Escritas por valor (qualquer resultado: uma escrita incerta pode ter sido aplicada)
+	escritas := map[string]Operacao{}
+	confirmadas := map[string][]Operacao{}
+	leituras := map[string][]Operacao{}
+	vistos := map[string]bool{}
+	var sensores []string
+	for _, o := range operacoes {
+		if !vistos[o.Sensor] {
+			vistos[o.Sensor] = true
+			sensores = append(sensores, o.Sensor)
+		}
+		switch {
+		case o.Operacao == OperacaoEscrita:
+			escritas[o.Valor] = o
+			if o.Resultado == ResultadoOk {
+				relatorio.EscritasConfirmadas++
+				confirmadas[o.Sensor] = append(confirmadas[o.Sensor], o)
+			} else if o.Resultado != ResultadoFalha {
+				relatorio.EscritasIncertas++
+			}
+		case o.Resultado == ResultadoOk:
+			relatorio.Leituras++
+			leituras[o.Sensor] = append(leituras[o.Sensor], o)
+		}
+	}
+
+	// Ordem do valor lido: a da escrita registrada (o instante devolvido pela API perde precisão)
+	ordemDaLeitura := func(l Operacao) time.Time {
+		if w, ok := escritas[l.Valor]; ok {
+			return w.InstanteDado
+		}
+		return l.InstanteDado
+	}
+
+	sort.Strings(sensores)
+	for _, sensor := range sensores {
+		lidas := leituras[sensor]
+		for _, l := range lidas {
+			if _, ok := escritas[l.Valor]; l.Valor != "" && !ok {
+				anotar(Anomalia{Tipo: AnomaliaValorDesconhecido, Sensor: sensor, Operacoes: []Operacao{l},
+					Descricao: fmt.Sprintf("leitura op=%d devolveu %q, que nenhuma escrita do historico invocou", l.Op, l.Valor)})
+			}
+		}
+		verificarDesatualizacao(sensor, confirmadas[sensor], lidas, limite, ordemDaLeitura, anotar)
+		verificarMonotonia(sensor, lidas, ordemDaLeitura, anotar)
+		if len(confirmadas[sensor]) == 0 {
+			continue
+		}
+		lidaFinal, foraDaFinal := verificarPerdas(sensor, confirmadas[sensor], lidas, ordemDaLeitura, anotar)
+		if !lidaFinal {
+			relatorio.SensoresSemLeituraFinal = append(relatorio.SensoresSemLeituraFinal, sensor)
+		}
+		relatorio.EscritasForaDaFinal += foraDaFinal
+	}
+	return relatorio, nil
+}
+
+// Varre as leituras por início, mantendo a escrita confirmada mais nova que já deveria ser visível.
+func verificarDesatualizacao(sensor string, confirmadas, lidas []Operacao, limite time.Duration,
+	ordem func(Operacao) time.Time, anotar func(Anomalia)) {
+	porFim := append([]Operacao(nil), confirmadas...)
+	sort.Slice(porFim, func(i, j int) bool { return porFim[i].Fim.Before(porFim[j].Fim) })
+	porInicio := append([]Operacao(nil), lidas...)
+	sort.Slice(porInicio, func(i, j int) bool { return porInicio[i].Inicio.Before(porInicio[j].Inicio) })
+
+	var maisNova *Operacao
+	proxima := 0
+	for _, l := range porInicio {
+		for proxima < len(porFim) && porFim[proxima].Fim.Add(limite).Before(l.Inicio) {
+			if maisNova == nil || porFim[proxima].InstanteDado.After(maisNova.InstanteDado) {
+				maisNova = &porFim[proxima]
+			}
+			proxima++
+		}
+		if l.Final || maisNova == nil || !ordem(l).Before(maisNova.InstanteDado) {
+			continue // leituras finais são avaliadas como escrita perdida
+		}
+		atraso := l.Inicio.Sub(maisNova.Fim)
+		anotar(Anomalia{Tipo: AnomaliaLeituraDesatualizada, Sensor: sensor, Operacoes: []Operacao{*maisNova, l}, AtrasoDaEscrita: atraso,
+			Descricao: fmt.Sprintf("leitura op=%d iniciada %s apos a confirmacao da escrita op=%d (limite %s) devolveu valor mais antigo",
+				l.Op, atraso.Round(time.Millisecond), maisNova.Op, limite)})
+	}
+}
+
+// Leituras de um mesmo cliente (sequencial) nunca devem voltar no tempo.
+func verificarMonotonia(sensor string, lidas []Operacao, ordem func(Operacao) time.Time, anotar func(Anomalia)) {
+	ultimaPorCliente := map[int]Operacao{}
+	porInicio := append([]Operacao(nil), lidas...)
+	sort.Slice(porInicio, func(i, j int) bool { return porInicio[i].Inicio.Before(porInicio[j].Inicio) })
+	for _, l := range porInicio {
+		anterior, ok := ultimaPorCliente[l.Cliente]
+		if ok && ordem(l).Before(ordem(anterior)) {
+			anotar(Anomalia{Tipo: AnomaliaLeituraNaoMonotonica, Sensor: sensor, Operacoes: []Operacao{anterior, l},
+				Descricao: fmt.Sprintf("cliente %d leu op=%d mais antigo que o ja lido em op=%d", l.Cliente, l.Op, anterior.Op)})
+			continue // mantém a leitura mais nova como referência
+		}
+		ultimaPorCliente[l.Cliente] = l
+	}
+}
+
+// Compara as escritas confirmadas com a última leitura final do sensor; false se não houve leitura final.
+// Com a lista completa de valores, qualquer escrita confirmada ausente é perdida (mesmo já superada por
+// outra); só com o valor mais novo, apenas as escritas mais novas que ele são detectadas. Se a leitura
+// final foi truncada, escritas até o corte não são verificáveis e só são contadas (segundo retorno).
+func verificarPerdas(sensor string, confirmadas, lidas []Operacao, ordem func(Operacao) time.Time, anotar func(Anomalia)) (bool, int) {
+	var final *Operacao
+	for i := range lidas {
+		if lidas[i].Final && (final == nil || lidas[i].Inicio.After(final.Inicio)) {
+			final = &lidas[i]
+		}
+	}
+	if final == nil {
+		return false, 0
+	}
+	vistas := map[string]int{}
+	for _, l := range lidas {
+		vistas[l.Valor]++
+	}
+	presentes := map[string]bool{}
+	for _, valor := range final.Valores {
+		presentes[valor] = true
+	}
+	foraDaFinal := 0
+	for _, w := range confirmadas {
+		if !w.Fim.Before(final.Inicio) {
+			continue
+		}
+		// No corte podem existir outras linhas com o mesmo instante que ficaram de fora
+		if !final.TruncadaEm.IsZero() && !w.InstanteDado.After(final.TruncadaEm) {
+			foraDaFinal++
+			continue
+		}
+		if final.Valores != nil && presentes[w.Valor] || final.Valores == nil && !ordem(*final).Before(w.InstanteDado) {
+			continue
+		}
+		anotar(Anomalia{Tipo: AnomaliaEscritaPerdida, Sensor: sensor, Operacoes: []Operacao{w, *final},
+			Descricao: fmt.Sprintf("escrita confirmada op=%d ausente da leitura final op=%d (lida %d vez(es) durante a carga)",
+				w.Op, final.Op, vistas[w.Valor])})
+	}
+	return true, foraDaFinal
+}
+
+// Resumo legível com os contraexemplos (no máximo "limite" por tipo; 0 = todos).
+func FormatarRelatorio(r RelatorioDaVerificacao, limitePorTipo int) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "operacoes=%d escritas_confirmadas=%d escritas_incertas=%d leituras=%d limite_desatualizacao=%s\n",
+		r.Operacoes, r.EscritasConfirmadas, r.EscritasIncertas, r.Leituras, r.LimiteDeDesatualizacao)
+	if len(r.SensoresSemLeituraFinal) > 0 {
+		fmt.Fprintf(&b, "aviso: sem leitura final (escritas perdidas nao verificadas): %s\n", strings.Join(r.SensoresSemLeituraFinal, ", "))
+	}
+	if r.EscritasForaDaFinal > 0 {
+		fmt.Fprintf(&b, "aviso: leitura final truncada; %d escritas confirmadas anteriores ao corte nao verificadas\n", r.EscritasForaDaFinal)
+	}
+	if len(r.Anomalias) == 0 {
+		b.WriteString("nenhuma anomalia encontrada\n")
+		return b.String()
+	}
+	tipos := make([]string, 0, len(r.AnomaliasPorTipo))
+	for tipo := range r.AnomaliasPorTipo {
+		tipos = append(tipos, tipo)
+	}
+	sort.Strings(tipos)
+	for _, tipo := range tipos {
+		fmt.Fprintf(&b, "%s: %d\n", tipo, r.AnomaliasPorTipo[tipo])
+		exibidas := 0
+		for _, a := range r.Anomalias {
+			if a.Tipo != tipo {
+				continue
+			}
+			if limitePorTipo > 0 && exibidas == limitePorTipo {
+				fmt.Fprintf(&b, "  ... (%d omitidas)\n", r.AnomaliasPorTipo[tipo]-exibidas)
+				break
+			}
+			exibidas++
+			fmt.Fprintf(&b, "  - %s\n", a.Descricao)
+			for _, o := range a.Operacoes {
+				fmt.Fprintf(&b, "      %s\n", o)
+			}
+		}
+	}
+	return b.String()
+}
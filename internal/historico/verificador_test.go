@@ -0,0 +1,216 @@
+package historico
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var inicioDoTeste = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Instante a "n" ms do início do teste.
+func ms(n int) time.Time { return inicioDoTeste.Add(time.Duration(n) * time.Millisecond) }
+
+func escritaOk(op int64, valor string, inicio, fim, dado int) Operacao {
+	return Operacao{Op: op, Operacao: OperacaoEscrita, Sensor: "s1", Valor: valor, InstanteDado: ms(dado),
+		Inicio: ms(inicio), Fim: ms(fim), Resultado: ResultadoOk}
+}
+
+func leituraOk(op int64, cliente int, valor string, inicio, fim, dado int) Operacao {
+	return Operacao{Op: op, Cliente: cliente, Operacao: OperacaoLeitura, Sensor: "s1", Valor: valor, InstanteDado: ms(dado),
+		Inicio: ms(inicio), Fim: ms(fim), Resultado: ResultadoOk}
+}
+
+func pelaOrdemDoDado(o Operacao) time.Time { return o.InstanteDado }
+
+func coletarAnomalias() (*[]Anomalia, func(Anomalia)) {
+	var anomalias []Anomalia
+	return &anomalias, func(a Anomalia) { anomalias = append(anomalias, a) }
+}
+
+func opsDe(anomalias []Anomalia) [][]int64 {
+	var ops [][]int64
+	for _, a := range anomalias {
+		var par []int64
+		for _, o := range a.Operacoes {
+			par = append(par, o.Op)
+		}
+		ops = append(ops, par)
+	}
+	return ops
+}
+
+func TestVerificarDesatualizacao(t *testing.T) {
+	w1 := escritaOk(1, "w1", 0, 10, 5)
+	w2 := escritaOk(2, "w2", 12, 20, 15)
+	lidas := []Operacao{
+		leituraOk(3, 1, "w1", 30, 32, 5),   // w2 confirmada há 10ms: dentro do limite
+		leituraOk(4, 1, "w1", 100, 102, 5), // w2 confirmada há 80ms: desatualizada
+		leituraOk(5, 2, "w2", 110, 112, 15),
+		leituraOk(6, 2, "", 5, 7, 0), // antes de qualquer confirmação
+	}
+	final := leituraOk(7, 0, "w1", 200, 210, 5)
+	final.Final = true // avaliada como escrita perdida, não aqui
+	lidas = append(lidas, final)
+
+	anomalias, anotar := coletarAnomalias()
+	verificarDesatualizacao("s1", []Operacao{w2, w1}, lidas, 50*time.Millisecond, pelaOrdemDoDado, anotar)
+	if len(*anomalias) != 1 {
+		t.Fatalf("anomalias = %v, esperada 1", opsDe(*anomalias))
+	}
+	a := (*anomalias)[0]
+	if a.Tipo != AnomaliaLeituraDesatualizada || a.Operacoes[0].Op != 2 || a.Operacoes[1].Op != 4 {
+		t.Fatalf("anomalia inesperada: %s %v", a.Tipo, opsDe(*anomalias))
+	}
+	if a.AtrasoDaEscrita != 80*time.Millisecond {
+		t.Fatalf("atraso = %s, esperado 80ms", a.AtrasoDaEscrita)
+	}
+}
+
+func TestVerificarMonotonia(t *testing.T) {
+	lidas := []Operacao{
+		leituraOk(1, 1, "w2", 10, 12, 15),
+		leituraOk(2, 2, "w1", 11, 13, 5),
+		leituraOk(3, 1, "w1", 20, 22, 5), // cliente 1 volta no tempo
+		leituraOk(4, 2, "w2", 21, 23, 15),
+		leituraOk(5, 1, "w2", 30, 32, 15), // referência do cliente 1 continua sendo w2
+	}
+	anomalias, anotar := coletarAnomalias()
+	verificarMonotonia("s1", lidas, pelaOrdemDoDado, anotar)
+	if len(*anomalias) != 1 {
+		t.Fatalf("anomalias = %v, esperada 1", opsDe(*anomalias))
+	}
+	if a := (*anomalias)[0]; a.Tipo != AnomaliaLeituraNaoMonotonica || a.Operacoes[0].Op != 1 || a.Operacoes[1].Op != 3 {
+		t.Fatalf("anomalia inesperada: %s %v", a.Tipo, opsDe(*anomalias))
+	}
+}
+
+func TestVerificarPerdas(t *testing.T) {
+	confirmadas := []Operacao{
+		escritaOk(1, "w1", 0, 10, 5),
+		escritaOk(2, "w2", 12, 20, 15),
+		escritaOk(3, "w3", 22, 30, 25),
+		escritaOk(4, "w4", 32, 40, 30),
+		escritaOk(5, "w5", 95, 150, 35), // confirmada depois do início da leitura final: não verificada
+	}
+	leituraFinal := func(valor string, dado int, valores []string, truncadaEm time.Time) Operacao {
+		l := leituraOk(9, 0, valor, 100, 110, dado)
+		l.Final, l.Valores, l.TruncadaEm = true, valores, truncadaEm
+		return l
+	}
+	casos := []struct {
+		nome        string
+		lidas       []Operacao
+		lidaFinal   bool
+		foraDaFinal int
+		perdidas    []int64
+	}{
+		{"sem leitura final", []Operacao{leituraOk(8, 1, "w4", 50, 51, 30)}, false, 0, nil},
+		{"todas presentes", []Operacao{leituraFinal("w4", 30, []string{"w4", "w3", "w2", "w1"}, time.Time{})}, true, 0, nil},
+		{"superada e ausente", []Operacao{leituraFinal("w4", 30, []string{"w4", "w3", "w1"}, time.Time{})}, true, 0, []int64{2}},
+		{"so o mais novo", []Operacao{leituraFinal("w2", 15, nil, time.Time{})}, true, 0, []int64{3, 4}},
+		// Corte em w2: w1 e w2 (mesmo instante do corte) não são verificáveis; w4 continua perdida
+		{"truncada", []Operacao{leituraFinal("w3", 25, []string{"w3", "w2"}, ms(15))}, true, 2, []int64{4}},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			anomalias, anotar := coletarAnomalias()
+			lidaFinal, foraDaFinal := verificarPerdas("s1", confirmadas, c.lidas, pelaOrdemDoDado, anotar)
+			if lidaFinal != c.lidaFinal || foraDaFinal != c.foraDaFinal {
+				t.Fatalf("lida_final=%v fora_da_final=%d, esperado %v/%d", lidaFinal, foraDaFinal, c.lidaFinal, c.foraDaFinal)
+			}
+			var perdidas []int64
+			for _, a := range *anomalias {
+				if a.Tipo != AnomaliaEscritaPerdida {
+					t.Fatalf("tipo inesperado %s", a.Tipo)
+				}
+				perdidas = append(perdidas, a.Operacoes[0].Op)
+			}
+			if len(perdidas) != len(c.perdidas) {
+				t.Fatalf("perdidas = %v, esperado %v", perdidas, c.perdidas)
+			}
+			for i := range perdidas {
+				if perdidas[i] != c.perdidas[i] {
+					t.Fatalf("perdidas = %v, esperado %v", perdidas, c.perdidas)
+				}
+			}
+		})
+	}
+}
+
+// Histórico em eventos: invocação e conclusão de cada operação, com instantes em ms.
+type historicoDeTeste struct{ eventos []Evento }
+
+func (h *historicoDeTeste) escrita(op int64, sensor, valor string, inicio, fim, dado int, resultado string) {
+	h.eventos = append(h.eventos,
+		Evento{Tipo: EventoInvocacao, Op: op, Cliente: 1, Operacao: OperacaoEscrita, Sensor: sensor, Valor: valor, InstanteDado: ms(dado), Instante: ms(inicio)},
+		Evento{Tipo: EventoConclusao, Op: op, Cliente: 1, Operacao: OperacaoEscrita, Sensor: sensor, Resultado: resultado, Instante: ms(fim)})
+}
+
+func (h *historicoDeTeste) leitura(op int64, sensor, valor string, inicio, fim, dado int) {
+	h.eventos = append(h.eventos,
+		Evento{Tipo: EventoInvocacao, Op: op, Cliente: 2, Operacao: OperacaoLeitura, Sensor: sensor, Instante: ms(inicio)},
+		Evento{Tipo: EventoConclusao, Op: op, Cliente: 2, Operacao: OperacaoLeitura, Sensor: sensor, Resultado: ResultadoOk,
+			Valor: valor, InstanteDado: ms(dado), Instante: ms(fim)})
+}
+
+func (h *historicoDeTeste) leituraFinal(op int64, sensor string, valores []string, inicio, fim, dado int, truncadaEm time.Time) {
+	h.eventos = append(h.eventos,
+		Evento{Tipo: EventoInvocacao, Op: op, Operacao: OperacaoLeitura, Sensor: sensor, Instante: ms(inicio), Final: true},
+		Evento{Tipo: EventoConclusao, Op: op, Operacao: OperacaoLeitura, Sensor: sensor, Resultado: ResultadoOk, Final: true,
+			Valor: valores[0], Valores: valores, InstanteDado: ms(dado), TruncadaEm: truncadaEm, Instante: ms(fim)})
+}
+
+func TestVerificar(t *testing.T) {
+	h := &historicoDeTeste{}
+	// a: a-1 confirmada e ausente da leitura final; a-2 indeterminada; uma leitura de valor desconhecido
+	h.escrita(1, "a", "a-1", 0, 5, 1, ResultadoOk)
+	h.escrita(2, "a", "a-2", 6, 8, 7, ResultadoIndeterminado)
+	h.leitura(3, "a", "x-9", 20, 25, 3)
+	h.leituraFinal(4, "a", []string{"a-2"}, 100, 110, 7, time.Time{})
+	// b: sem leitura final
+	h.escrita(5, "b", "b-1", 0, 5, 1, ResultadoOk)
+	// c: leitura final truncada em c-2; c-1 fica fora da verificação em vez de virar escrita perdida
+	h.escrita(6, "c", "c-1", 0, 5, 1, ResultadoOk)
+	h.escrita(7, "c", "c-2", 6, 10, 8, ResultadoOk)
+	h.escrita(8, "c", "c-3", 11, 15, 12, ResultadoFalha)
+	h.leituraFinal(9, "c", []string{"c-2"}, 100, 110, 8, ms(8))
+
+	r, err := Verificar(h.eventos, time.Second)
+	if err != nil {
+		t.Fatalf("Verificar: %v", err)
+	}
+	if r.Operacoes != 9 || r.EscritasConfirmadas != 4 || r.EscritasIncertas != 1 || r.Leituras != 3 {
+		t.Fatalf("contagens inesperadas: %+v", r)
+	}
+	if len(r.SensoresSemLeituraFinal) != 1 || r.SensoresSemLeituraFinal[0] != "b" {
+		t.Fatalf("sensores sem leitura final = %v", r.SensoresSemLeituraFinal)
+	}
+	if r.EscritasForaDaFinal != 2 {
+		t.Fatalf("escritas fora da final = %d, esperado 2 (c-1 e c-2 no corte)", r.EscritasForaDaFinal)
+	}
+	esperadas := map[string]int{AnomaliaValorDesconhecido: 1, AnomaliaEscritaPerdida: 1}
+	if len(r.AnomaliasPorTipo) != len(esperadas) {
+		t.Fatalf("anomalias = %v, esperado %v", r.AnomaliasPorTipo, esperadas)
+	}
+	for tipo, n := range esperadas {
+		if r.AnomaliasPorTipo[tipo] != n {
+			t.Fatalf("anomalias = %v, esperado %v", r.AnomaliasPorTipo, esperadas)
+		}
+	}
+	for _, a := range r.Anomalias {
+		if a.Tipo == AnomaliaEscritaPerdida && (a.Sensor != "a" || a.Operacoes[0].Op != 1) {
+			t.Fatalf("escrita perdida inesperada: %s %v", a.Sensor, opsDe([]Anomalia{a}))
+		}
+	}
+	if texto := FormatarRelatorio(r, 0); !strings.Contains(texto, "leitura final truncada") {
+		t.Fatalf("relatorio sem aviso de truncamento:\n%s", texto)
+	}
+}
+
+func TestVerificarConclusaoSemInvocacao(t *testing.T) {
+	eventos := []Evento{{Indice: 1, Tipo: EventoConclusao, Op: 7, Operacao: OperacaoLeitura, Resultado: ResultadoOk}}
+	if _, err := Verificar(eventos, time.Second); err == nil {
+		t.Fatal("esperado erro para conclusao sem invocacao")
+	}
+}
@@ -200,18 +200,33 @@ func manipuladorDeConsultaUltima(w http.ResponseWriter, r *http.Request, repo *s
 		return
 	}
 
-	// Estratégia sem tabela auxiliar: tenta hoje; se vazio, tenta ontem.
+	// Com data: consulta só esse dia. Sem data (estratégia sem tabela auxiliar): tenta hoje; se vazio, tenta ontem.
+	var dia time.Time
+	if s := strings.TrimSpace(r.URL.Query().Get("data")); s != "" {
+		parsed, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			http.Error(w, "parametro data invalido (use YYYY-MM-DD UTC)", http.StatusBadRequest)
+			return
+		}
+		dia = sensors.TruncarParaDiaUTC(parsed.UTC())
+	}
 	hoje := sensors.TruncarParaDiaUTC(time.Now().UTC())
 	ontem := hoje.Add(-24 * time.Hour)
 
 	ctx := context.Background()
 	inicio := time.Now()
 
-	// Tenta hoje
-	leituras, erro := repo.ConsultarUltimasLeiturasPorSensor(ctx, sensorID, hoje, 1, consistencia)
-	if erro == nil && len(leituras) == 0 {
-		// Tenta ontem
-		leituras, erro = repo.ConsultarUltimasLeiturasPorSensor(ctx, sensorID, ontem, 1, consistencia)
+	var leituras []sensors.LeituraDeSensor
+	var erro error
+	if !dia.IsZero() {
+		leituras, erro = repo.ConsultarUltimasLeiturasPorSensor(ctx, sensorID, dia, 1, consistencia)
+	} else {
+		// Tenta hoje
+		leituras, erro = repo.ConsultarUltimasLeiturasPorSensor(ctx, sensorID, hoje, 1, consistencia)
+		if erro == nil && len(leituras) == 0 {
+			// Tenta ontem
+			leituras, erro = repo.ConsultarUltimasLeiturasPorSensor(ctx, sensorID, ontem, 1, consistencia)
+		}
 	}
 	duracao := time.Since(inicio)
 
@@ -0,0 +1,68 @@
+package adaptadores
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/auditoria"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Repositório de escrita que registra cada escrita (confirmada ou não) de qualquer porta de escrita
+// para a auditoria de escritas perdidas. A troca de consistência é repassada à porta decorada e
+// passa a rotular os registros seguintes.
+type RepositorioDeEscritaAuditado struct {
+	portas.PortaDeEscrita
+	Gravador *auditoria.GravadorDeEscritas
+	Origem   string // ferramenta que escreveu (ex.: go-stress, carga)
+
+	mu           sync.RWMutex
+	consistencia string
+}
+
+func NovoRepositorioDeEscritaAuditado(repositorio portas.PortaDeEscrita, gravador *auditoria.GravadorDeEscritas, origem, consistencia string) *RepositorioDeEscritaAuditado {
+	return &RepositorioDeEscritaAuditado{PortaDeEscrita: repositorio, Gravador: gravador, Origem: origem, consistencia: strings.ToUpper(consistencia)}
+}
+
+func (r *RepositorioDeEscritaAuditado) GravarLeitura(ctx context.Context, leitura portas.LeituraDeSensor) error {
+	consistencia := r.rotulo()
+	err := r.PortaDeEscrita.GravarLeitura(ctx, leitura)
+	r.Gravador.RegistrarResultado(r.Origem, leitura.IdentificadorDoSensor, leitura.InstanteDoEvento, consistencia, err)
+	return err
+}
+
+// O lote não diz quais leituras falharam: com qualquer falha, todas ficam como não confirmadas.
+func (r *RepositorioDeEscritaAuditado) GravarLote(ctx context.Context, leituras []portas.LeituraDeSensor) error {
+	emLote, ok := r.PortaDeEscrita.(portas.PortaDeEscritaEmLote)
+	if !ok {
+		return fmt.Errorf("porta de escrita sem suporte a lote")
+	}
+	consistencia := r.rotulo()
+	err := emLote.GravarLote(ctx, leituras)
+	for _, leitura := range leituras {
+		r.Gravador.RegistrarResultado(r.Origem, leitura.IdentificadorDoSensor, leitura.InstanteDoEvento, consistencia, err)
+	}
+	return err
+}
+
+func (r *RepositorioDeEscritaAuditado) DefinirConsistencia(operacao portas.TipoDeOperacao, nivel string) error {
+	if ajustavel, ok := r.PortaDeEscrita.(portas.PortaDeConsistenciaAjustavel); ok {
+		if err := ajustavel.DefinirConsistencia(operacao, nivel); err != nil {
+			return err
+		}
+	}
+	if operacao == portas.OperacaoEscrita {
+		r.mu.Lock()
+		r.consistencia = strings.ToUpper(nivel)
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+func (r *RepositorioDeEscritaAuditado) rotulo() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.consistencia
+}
@@ -2,9 +2,12 @@ package adaptadores
 
 import (
 	"context"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gocql/gocql"
+	"github.com/pdrpinto/tcc-cassandra/internal/db"
 	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
 )
 
@@ -12,6 +15,7 @@ type RepositorioDeEscritaCassandra struct {
 	SessaoDoClusterCassandra *gocql.Session
 	NivelDeConsistencia      gocql.Consistency
 	TempoLimitePorOperacao   time.Duration
+	mu                       sync.RWMutex // protege NivelDeConsistencia (ajustável pela API de controle)
 }
 
 func NovoRepositorioDeEscritaCassandra(sessao *gocql.Session, consist gocql.Consistency, timeout time.Duration) *RepositorioDeEscritaCassandra {
@@ -34,6 +38,117 @@ func (r *RepositorioDeEscritaCassandra) GravarLeitura(ctx context.Context, leitu
 		leitura.UnidadeDeMedida,
 		leitura.EstadoDaLeitura,
 		leitura.AtributosAdicionais,
-	).Consistency(r.NivelDeConsistencia).WithContext(ctxComTempo)
+	).Consistency(r.consistencia()).WithContext(ctxComTempo)
 	return consulta.Exec()
 }
+
+func (r *RepositorioDeEscritaCassandra) consistencia() gocql.Consistency {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.NivelDeConsistencia
+}
+
+func (r *RepositorioDeEscritaCassandra) DefinirConsistencia(operacao portas.TipoDeOperacao, nivel string) error {
+	if operacao != portas.OperacaoEscrita {
+		return nil
+	}
+	consist, err := db.ConverterTextoParaNivelDeConsistencia(strings.ToUpper(nivel))
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.NivelDeConsistencia = consist
+	return nil
+}
+
+// Repositorio de consultas usado pela mistura de operações do stress.
+// Cada tipo de leitura tem seu próprio nível de consistência.
+type RepositorioDeLeituraCassandra struct {
+	SessaoDoClusterCassandra     *gocql.Session
+	NivelDeConsistenciaUltimas   gocql.Consistency
+	NivelDeConsistenciaIntervalo gocql.Consistency
+	TempoLimitePorOperacao       time.Duration
+	mu                           sync.RWMutex // protege os níveis de consistência
+}
+
+func NovoRepositorioDeLeituraCassandra(sessao *gocql.Session, consistUltimas, consistIntervalo gocql.Consistency, timeout time.Duration) *RepositorioDeLeituraCassandra {
+	return &RepositorioDeLeituraCassandra{
+		SessaoDoClusterCassandra:     sessao,
+		NivelDeConsistenciaUltimas:   consistUltimas,
+		NivelDeConsistenciaIntervalo: consistIntervalo,
+		TempoLimitePorOperacao:       timeout,
+	}
+}
+
+func (r *RepositorioDeLeituraCassandra) ConsultarUltimasLeituras(ctx context.Context, identificadorDoSensor string, dia time.Time, quantidade int) ([]portas.LeituraDeSensor, error) {
+	ctxComTempo, cancelar := context.WithTimeout(ctx, r.TempoLimitePorOperacao)
+	defer cancelar()
+	consulta := r.SessaoDoClusterCassandra.Query(
+		`SELECT ts, value, unit, status, tags FROM sensor_readings WHERE sensor_id = ? AND day_bucket = ? LIMIT ?`,
+		identificadorDoSensor, dia, quantidade,
+	).Consistency(r.consistencia(portas.OperacaoLeituraUltimas)).WithContext(ctxComTempo)
+	return lerLeituras(consulta.Iter(), identificadorDoSensor, dia, quantidade)
+}
+
+func (r *RepositorioDeLeituraCassandra) ConsultarLeiturasPorIntervalo(ctx context.Context, identificadorDoSensor string, dia, inicio, fim time.Time, quantidade int) ([]portas.LeituraDeSensor, error) {
+	ctxComTempo, cancelar := context.WithTimeout(ctx, r.TempoLimitePorOperacao)
+	defer cancelar()
+	consulta := r.SessaoDoClusterCassandra.Query(
+		`SELECT ts, value, unit, status, tags FROM sensor_readings WHERE sensor_id = ? AND day_bucket = ? AND ts >= ? AND ts <= ? LIMIT ?`,
+		identificadorDoSensor, dia, gocql.MinTimeUUID(inicio.UTC()), gocql.MaxTimeUUID(fim.UTC()), quantidade,
+	).Consistency(r.consistencia(portas.OperacaoLeituraIntervalo)).WithContext(ctxComTempo)
+	return lerLeituras(consulta.Iter(), identificadorDoSensor, dia, quantidade)
+}
+
+func (r *RepositorioDeLeituraCassandra) consistencia(operacao portas.TipoDeOperacao) gocql.Consistency {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if operacao == portas.OperacaoLeituraIntervalo {
+		return r.NivelDeConsistenciaIntervalo
+	}
+	return r.NivelDeConsistenciaUltimas
+}
+
+func (r *RepositorioDeLeituraCassandra) DefinirConsistencia(operacao portas.TipoDeOperacao, nivel string) error {
+	if operacao == portas.OperacaoEscrita {
+		return nil
+	}
+	consist, err := db.ConverterTextoParaNivelDeConsistencia(strings.ToUpper(nivel))
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if operacao == portas.OperacaoLeituraIntervalo {
+		r.NivelDeConsistenciaIntervalo = consist
+	} else {
+		r.NivelDeConsistenciaUltimas = consist
+	}
+	return nil
+}
+
+func lerLeituras(iterador *gocql.Iter, identificadorDoSensor string, dia time.Time, quantidade int) ([]portas.LeituraDeSensor, error) {
+	resultados := make([]portas.LeituraDeSensor, 0, quantidade)
+	var ts gocql.UUID
+	var valor float64
+	var unidade string
+	var estado int16
+	var tags map[string]string
+	for iterador.Scan(&ts, &valor, &unidade, &estado, &tags) {
+		resultados = append(resultados, portas.LeituraDeSensor{
+			IdentificadorDoSensor: identificadorDoSensor,
+			DiaDeAgrupamento:      dia,
+			InstanteDoEvento:      ts.Time(),
+			ValorMedido:           valor,
+			UnidadeDeMedida:       unidade,
+			EstadoDaLeitura:       estado,
+			AtributosAdicionais:   tags,
+		})
+		tags = nil
+	}
+	if err := iterador.Close(); err != nil {
+		return nil, err
+	}
+	return resultados, nil
+}
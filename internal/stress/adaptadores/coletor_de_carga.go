@@ -0,0 +1,97 @@
+package adaptadores
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/estatisticas"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Porta de métricas que agrega latências e erros em memória, por operação, no formato
+// de resultado das ferramentas de carga (estatisticas.ResumoDaCarga). As consultas com
+// sucesso também são agregadas por faixa de tamanho do resultado.
+type ColetorDeCarga struct {
+	mu          sync.Mutex
+	inicio      time.Time
+	porOperacao map[portas.TipoDeOperacao]*estatisticas.ColetorDeRequisicoes
+	porFaixa    map[portas.TipoDeOperacao]map[string]*estatisticas.ColetorDeRequisicoes
+}
+
+func NovoColetorDeCarga(inicio time.Time) *ColetorDeCarga {
+	return &ColetorDeCarga{inicio: inicio, porOperacao: map[portas.TipoDeOperacao]*estatisticas.ColetorDeRequisicoes{},
+		porFaixa: map[portas.TipoDeOperacao]map[string]*estatisticas.ColetorDeRequisicoes{}}
+}
+
+func (c *ColetorDeCarga) coletor(operacao portas.TipoDeOperacao) *estatisticas.ColetorDeRequisicoes {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	coletor, ok := c.porOperacao[operacao]
+	if !ok {
+		coletor = estatisticas.NovoColetorDeRequisicoes(c.inicio)
+		c.porOperacao[operacao] = coletor
+	}
+	return coletor
+}
+
+func (c *ColetorDeCarga) coletorDaFaixa(operacao portas.TipoDeOperacao, faixa string) *estatisticas.ColetorDeRequisicoes {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	faixas, ok := c.porFaixa[operacao]
+	if !ok {
+		faixas = map[string]*estatisticas.ColetorDeRequisicoes{}
+		c.porFaixa[operacao] = faixas
+	}
+	coletor, ok := faixas[faixa]
+	if !ok {
+		coletor = estatisticas.NovoColetorDeRequisicoes(c.inicio)
+		faixas[faixa] = coletor
+	}
+	return coletor
+}
+
+// Usado pelo serviço no lugar dos dois métodos abaixo: nas portas HTTP a amostra leva o status e o duracao_ms.
+func (c *ColetorDeCarga) RegistrarOperacao(operacao portas.TipoDeOperacao, latencia time.Duration, motivo string, resposta portas.RespostaDoServidor) {
+	amostra := estatisticas.AmostraDeRequisicao{
+		Instante: time.Now().Add(-latencia), Latencia: latencia, LatenciaNoServidor: resposta.DuracaoNoServidor,
+		Status: resposta.Status, Ok: motivo == "", Erro: motivo, Itens: resposta.Itens, ItensComFalha: resposta.ItensComFalha}
+	c.coletor(operacao).Registrar(amostra)
+	if amostra.Ok && operacao != portas.OperacaoEscrita {
+		c.coletorDaFaixa(operacao, estatisticas.FaixaDoResultado(resposta.Itens)).Registrar(amostra)
+	}
+}
+
+// Sem a resposta do servidor (chamadores fora do ServicoDeStress); o motivo do erro já traz o status (http_<status>).
+func (c *ColetorDeCarga) RegistrarLatenciaEmMs(operacao portas.TipoDeOperacao, _ string, duracao time.Duration) {
+	c.coletor(operacao).Registrar(estatisticas.AmostraDeRequisicao{
+		Instante: time.Now().Add(-duracao), Latencia: duracao, LatenciaNoServidor: -1, Status: -1, Ok: true})
+}
+
+func (c *ColetorDeCarga) RegistrarErro(operacao portas.TipoDeOperacao, motivo string) {
+	c.coletor(operacao).Registrar(estatisticas.AmostraDeRequisicao{
+		Instante: time.Now(), LatenciaNoServidor: -1, Status: -1, Erro: motivo})
+}
+
+func (c *ColetorDeCarga) Resumos(ferramenta string, duracao time.Duration) map[portas.TipoDeOperacao]estatisticas.ResumoDaCarga {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resumos := map[portas.TipoDeOperacao]estatisticas.ResumoDaCarga{}
+	for operacao, coletor := range c.porOperacao {
+		resumos[operacao] = coletor.Resumo(ferramenta, duracao)
+	}
+	return resumos
+}
+
+// Resumos das consultas com sucesso por faixa de tamanho do resultado (só operações de leitura).
+func (c *ColetorDeCarga) ResumosPorFaixa(ferramenta string, duracao time.Duration) map[portas.TipoDeOperacao]map[string]estatisticas.ResumoDaCarga {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resumos := map[portas.TipoDeOperacao]map[string]estatisticas.ResumoDaCarga{}
+	for operacao, faixas := range c.porFaixa {
+		resumos[operacao] = map[string]estatisticas.ResumoDaCarga{}
+		for faixa, coletor := range faixas {
+			resumos[operacao][faixa] = coletor.Resumo(ferramenta+" faixa "+faixa, duracao)
+		}
+	}
+	return resumos
+}
@@ -0,0 +1,129 @@
+package adaptadores
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/aplicacao"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+type requisicaoDeAjuste struct {
+	Taxa          *float64          `json:"rps,omitempty"`
+	Concorrencia  *int              `json:"concorrencia,omitempty"`
+	Consistencias map[string]string `json:"consistencias,omitempty"` // escrita|ultimas|intervalo -> nível
+}
+
+type requisicaoDeExecucao struct {
+	Nome    string  `json:"nome"`
+	Taxa    float64 `json:"rps,omitempty"`
+	Duracao string  `json:"duracao,omitempty"` // ex.: "5m"; vazio = duração configurada
+}
+
+// API de controle do go-stress:
+//
+//	GET  /controle/status
+//	POST /controle/ajustar   {"rps":2000,"concorrencia":64,"consistencias":{"escrita":"ONE"}}
+//	POST /controle/pausar | /controle/retomar | /controle/parar
+//	POST /controle/iniciar   {"nome":"exp-quorum-1","rps":1000,"duracao":"5m"}
+func RegistrarRotasDeControle(mux *http.ServeMux, controle *aplicacao.ControleDeExecucao) {
+	mux.HandleFunc("/controle/status", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "somente GET", http.StatusMethodNotAllowed)
+			return
+		}
+		responderJSON(w, http.StatusOK, controle.Status())
+	})
+	mux.HandleFunc("/controle/ajustar", somentePost(func(w http.ResponseWriter, r *http.Request) {
+		var req requisicaoDeAjuste
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json invalido: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.Taxa != nil {
+			if err := controle.AjustarTaxa(*req.Taxa); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		if req.Concorrencia != nil {
+			if err := controle.AjustarConcorrencia(*req.Concorrencia); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		for op, nivel := range req.Consistencias {
+			operacao := portas.TipoDeOperacao(strings.ToLower(strings.TrimSpace(op)))
+			switch operacao {
+			case portas.OperacaoEscrita, portas.OperacaoLeituraUltimas, portas.OperacaoLeituraIntervalo:
+			default:
+				http.Error(w, "operacao desconhecida: "+op, http.StatusBadRequest)
+				return
+			}
+			if err := controle.AjustarConsistencia(operacao, nivel); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		responderJSON(w, http.StatusOK, controle.Status())
+	}))
+	mux.HandleFunc("/controle/pausar", somentePost(func(w http.ResponseWriter, r *http.Request) {
+		controle.Pausar()
+		responderJSON(w, http.StatusOK, controle.Status())
+	}))
+	mux.HandleFunc("/controle/retomar", somentePost(func(w http.ResponseWriter, r *http.Request) {
+		controle.Retomar()
+		responderJSON(w, http.StatusOK, controle.Status())
+	}))
+	mux.HandleFunc("/controle/parar", somentePost(func(w http.ResponseWriter, r *http.Request) {
+		if err := controle.Parar(); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		responderJSON(w, http.StatusOK, controle.Status())
+	}))
+	mux.HandleFunc("/controle/iniciar", somentePost(func(w http.ResponseWriter, r *http.Request) {
+		var req requisicaoDeExecucao
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "json invalido: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		pedido := aplicacao.PedidoDeExecucao{Nome: req.Nome, Taxa: req.Taxa}
+		if req.Duracao != "" {
+			d, err := time.ParseDuration(req.Duracao)
+			if err != nil || d < 0 {
+				http.Error(w, "duracao invalida: "+req.Duracao, http.StatusBadRequest)
+				return
+			}
+			pedido.Duracao = d
+		}
+		if err := controle.SolicitarExecucao(pedido); err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, aplicacao.ErrExecucaoEmAndamento) || errors.Is(err, aplicacao.ErrPedidosDesativados) {
+				status = http.StatusConflict
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		responderJSON(w, http.StatusAccepted, map[string]string{"execucao": pedido.Nome})
+	}))
+}
+
+func somentePost(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "somente POST", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+func responderJSON(w http.ResponseWriter, status int, corpo any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(corpo)
+}
@@ -0,0 +1,253 @@
+package adaptadores
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/db"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Resposta HTTP fora de 2xx; o serviço de stress classifica o erro pelo status (motivo "http_<status>").
+type ErroHTTP struct {
+	Status   int
+	Mensagem string
+}
+
+func (e ErroHTTP) Error() string { return fmt.Sprintf("status %d: %s", e.Status, e.Mensagem) }
+
+func (e ErroHTTP) StatusHTTP() int { return e.Status }
+
+// Envia as escritas ao ingestor (POST /ingest?w= ou /ingest/lote) em vez de direto ao Cassandra.
+type RepositorioDeEscritaHTTP struct {
+	Cliente             *http.Client
+	URLBase             string // ex.: http://localhost:8080
+	NivelDeConsistencia string
+	ConcorrenciaDoLote  int          // gravações paralelas no servidor em /ingest/lote (?concorrencia=; 0 = padrão do servidor)
+	mu                  sync.RWMutex // protege NivelDeConsistencia (ajustável pela API de controle)
+}
+
+func NovoRepositorioDeEscritaHTTP(urlBase, consist string, timeout time.Duration) *RepositorioDeEscritaHTTP {
+	return &RepositorioDeEscritaHTTP{Cliente: &http.Client{Timeout: timeout}, URLBase: strings.TrimSuffix(urlBase, "/"), NivelDeConsistencia: consist}
+}
+
+type requisicaoDeIngestao struct {
+	IdentificadorDoSensor   string            `json:"identificador_do_sensor"`
+	InstanteDoEventoISO8601 string            `json:"instante_do_evento_iso8601"`
+	ValorMedido             float64           `json:"valor_medido"`
+	UnidadeDeMedida         string            `json:"unidade_de_medida,omitempty"`
+	EstadoDaLeitura         int16             `json:"estado_da_leitura,omitempty"`
+	AtributosAdicionais     map[string]string `json:"atributos_adicionais,omitempty"`
+}
+
+func novaRequisicaoDeIngestao(leitura portas.LeituraDeSensor) requisicaoDeIngestao {
+	return requisicaoDeIngestao{
+		IdentificadorDoSensor:   leitura.IdentificadorDoSensor,
+		InstanteDoEventoISO8601: leitura.InstanteDoEvento.UTC().Format(time.RFC3339Nano), // precisão total: é a chave (ts) auditada
+		ValorMedido:             leitura.ValorMedido,
+		UnidadeDeMedida:         leitura.UnidadeDeMedida,
+		EstadoDaLeitura:         leitura.EstadoDaLeitura,
+		AtributosAdicionais:     leitura.AtributosAdicionais,
+	}
+}
+
+func (r *RepositorioDeEscritaHTTP) GravarLeitura(ctx context.Context, leitura portas.LeituraDeSensor) error {
+	r.mu.RLock()
+	q := url.Values{"w": {r.NivelDeConsistencia}}
+	r.mu.RUnlock()
+	return r.enviar(ctx, "/ingest", q, novaRequisicaoDeIngestao(leitura))
+}
+
+// Um POST /ingest/lote; com falha parcial (502) as quantidades aceita e recusada ficam anotadas no contexto.
+func (r *RepositorioDeEscritaHTTP) GravarLote(ctx context.Context, leituras []portas.LeituraDeSensor) error {
+	lote := make([]requisicaoDeIngestao, len(leituras))
+	for i, leitura := range leituras {
+		lote[i] = novaRequisicaoDeIngestao(leitura)
+	}
+	r.mu.RLock()
+	q := url.Values{"w": {r.NivelDeConsistencia}}
+	r.mu.RUnlock()
+	if r.ConcorrenciaDoLote > 0 {
+		q.Set("concorrencia", strconv.Itoa(r.ConcorrenciaDoLote))
+	}
+	return r.enviar(ctx, "/ingest/lote", q, lote)
+}
+
+func (r *RepositorioDeEscritaHTTP) enviar(ctx context.Context, rota string, q url.Values, corpo any) error {
+	conteudo, err := json.Marshal(corpo)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.URLBase+rota+"?"+q.Encode(), bytes.NewReader(conteudo))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, err = executarRequisicao(r.Cliente, req)
+	return err
+}
+
+func (r *RepositorioDeEscritaHTTP) DefinirConsistencia(operacao portas.TipoDeOperacao, nivel string) error {
+	if operacao != portas.OperacaoEscrita {
+		return nil
+	}
+	if _, err := db.ConverterTextoParaNivelDeConsistencia(strings.ToUpper(nivel)); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.NivelDeConsistencia = strings.ToUpper(nivel)
+	return nil
+}
+
+// Consultas pelas rotas /leituras/ultimas e /leituras/intervalo do ingestor.
+type RepositorioDeLeituraHTTP struct {
+	Cliente                      *http.Client
+	URLBase                      string
+	NivelDeConsistenciaUltimas   string
+	NivelDeConsistenciaIntervalo string
+	mu                           sync.RWMutex
+}
+
+func NovoRepositorioDeLeituraHTTP(urlBase, consistUltimas, consistIntervalo string, timeout time.Duration) *RepositorioDeLeituraHTTP {
+	return &RepositorioDeLeituraHTTP{Cliente: &http.Client{Timeout: timeout}, URLBase: strings.TrimSuffix(urlBase, "/"),
+		NivelDeConsistenciaUltimas: consistUltimas, NivelDeConsistenciaIntervalo: consistIntervalo}
+}
+
+// Leitura como serializada pelo ingestor (sensors.LeituraDeSensor não tem tags json).
+type leituraHTTP struct {
+	InstanteDoEvento    time.Time
+	ValorMedido         float64
+	UnidadeDeMedida     string
+	EstadoDaLeitura     int16
+	AtributosAdicionais map[string]string
+}
+
+func (r *RepositorioDeLeituraHTTP) ConsultarUltimasLeituras(ctx context.Context, identificadorDoSensor string, dia time.Time, quantidade int) ([]portas.LeituraDeSensor, error) {
+	q := url.Values{}
+	q.Set("sensor_id", identificadorDoSensor)
+	q.Set("data", dia.UTC().Format("2006-01-02"))
+	q.Set("limite", strconv.Itoa(quantidade))
+	q.Set("r", r.consistencia(portas.OperacaoLeituraUltimas))
+	return r.consultar(ctx, "/leituras/ultimas", q, identificadorDoSensor, dia)
+}
+
+func (r *RepositorioDeLeituraHTTP) ConsultarLeiturasPorIntervalo(ctx context.Context, identificadorDoSensor string, dia, inicio, fim time.Time, quantidade int) ([]portas.LeituraDeSensor, error) {
+	q := url.Values{}
+	q.Set("sensor_id", identificadorDoSensor)
+	q.Set("data", dia.UTC().Format("2006-01-02"))
+	q.Set("inicio", inicio.UTC().Format(time.RFC3339Nano))
+	q.Set("fim", fim.UTC().Format(time.RFC3339Nano))
+	q.Set("limite", strconv.Itoa(quantidade))
+	q.Set("r", r.consistencia(portas.OperacaoLeituraIntervalo))
+	return r.consultar(ctx, "/leituras/intervalo", q, identificadorDoSensor, dia)
+}
+
+func (r *RepositorioDeLeituraHTTP) consultar(ctx context.Context, rota string, q url.Values, identificadorDoSensor string, dia time.Time) ([]portas.LeituraDeSensor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.URLBase+rota+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	conteudo, err := executarRequisicao(r.Cliente, req)
+	if err != nil {
+		return nil, err
+	}
+	var resposta struct {
+		Itens []leituraHTTP `json:"itens"`
+	}
+	if err := json.Unmarshal(conteudo, &resposta); err != nil {
+		return nil, fmt.Errorf("resposta invalida de %s: %w", rota, err)
+	}
+	resultados := make([]portas.LeituraDeSensor, 0, len(resposta.Itens))
+	for _, item := range resposta.Itens {
+		resultados = append(resultados, portas.LeituraDeSensor{
+			IdentificadorDoSensor: identificadorDoSensor,
+			DiaDeAgrupamento:      dia,
+			InstanteDoEvento:      item.InstanteDoEvento,
+			ValorMedido:           item.ValorMedido,
+			UnidadeDeMedida:       item.UnidadeDeMedida,
+			EstadoDaLeitura:       item.EstadoDaLeitura,
+			AtributosAdicionais:   item.AtributosAdicionais,
+		})
+	}
+	return resultados, nil
+}
+
+func (r *RepositorioDeLeituraHTTP) consistencia(operacao portas.TipoDeOperacao) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if operacao == portas.OperacaoLeituraIntervalo {
+		return r.NivelDeConsistenciaIntervalo
+	}
+	return r.NivelDeConsistenciaUltimas
+}
+
+func (r *RepositorioDeLeituraHTTP) DefinirConsistencia(operacao portas.TipoDeOperacao, nivel string) error {
+	if operacao == portas.OperacaoEscrita {
+		return nil
+	}
+	if _, err := db.ConverterTextoParaNivelDeConsistencia(strings.ToUpper(nivel)); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if operacao == portas.OperacaoLeituraIntervalo {
+		r.NivelDeConsistenciaIntervalo = strings.ToUpper(nivel)
+	} else {
+		r.NivelDeConsistenciaUltimas = strings.ToUpper(nivel)
+	}
+	return nil
+}
+
+// Lê o corpo inteiro; fora de 2xx retorna ErroHTTP com o campo "erro" (JSON) ou o texto da resposta.
+// Status, duracao_ms e, no lote, quantidade_aceita/quantidade_falha (quando o corpo é JSON) são
+// anotados no contexto da requisição.
+func executarRequisicao(cliente *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := cliente.Do(req)
+	if err != nil {
+		portas.AnotarResposta(req.Context(), 0, -1)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	conteudo, err := io.ReadAll(resp.Body)
+	if err != nil {
+		portas.AnotarResposta(req.Context(), resp.StatusCode, -1)
+		return nil, err
+	}
+	var resumo struct {
+		DuracaoMs        *int64 `json:"duracao_ms"`
+		QuantidadeAceita int64  `json:"quantidade_aceita"`
+		QuantidadeFalha  int64  `json:"quantidade_falha"`
+	}
+	duracaoNoServidor := time.Duration(-1)
+	if json.Unmarshal(conteudo, &resumo) == nil {
+		if resumo.DuracaoMs != nil {
+			duracaoNoServidor = time.Duration(*resumo.DuracaoMs) * time.Millisecond
+		}
+		// O lote com falha parcial ainda tem leituras aceitas
+		if resumo.QuantidadeAceita > 0 || resumo.QuantidadeFalha > 0 {
+			portas.AnotarItens(req.Context(), resumo.QuantidadeAceita, resumo.QuantidadeFalha)
+		}
+	}
+	portas.AnotarResposta(req.Context(), resp.StatusCode, duracaoNoServidor)
+	if resp.StatusCode/100 != 2 {
+		var corpo struct {
+			Erro string `json:"erro"`
+		}
+		mensagem := strings.TrimSpace(string(conteudo))
+		if json.Unmarshal(conteudo, &corpo) == nil && corpo.Erro != "" {
+			mensagem = corpo.Erro
+		}
+		return nil, ErroHTTP{Status: resp.StatusCode, Mensagem: mensagem}
+	}
+	return conteudo, nil
+}
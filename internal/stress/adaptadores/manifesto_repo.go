@@ -0,0 +1,50 @@
+package adaptadores
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/conteudo"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Registra no manifesto as escritas confirmadas de qualquer porta de escrita (HTTP ou Cassandra).
+type RepositorioDeEscritaComManifesto struct {
+	portas.PortaDeEscrita
+	Manifesto *conteudo.ManifestoDeParticoes
+}
+
+func NovoRepositorioDeEscritaComManifesto(repositorio portas.PortaDeEscrita, manifesto *conteudo.ManifestoDeParticoes) *RepositorioDeEscritaComManifesto {
+	return &RepositorioDeEscritaComManifesto{PortaDeEscrita: repositorio, Manifesto: manifesto}
+}
+
+func (r *RepositorioDeEscritaComManifesto) GravarLeitura(ctx context.Context, leitura portas.LeituraDeSensor) error {
+	err := r.PortaDeEscrita.GravarLeitura(ctx, leitura)
+	if err == nil {
+		r.Manifesto.Registrar(leitura.IdentificadorDoSensor, leitura.InstanteDoEvento)
+	}
+	return err
+}
+
+// Lote com falha parcial: não se sabe quais leituras existem, então nenhuma é registrada.
+func (r *RepositorioDeEscritaComManifesto) GravarLote(ctx context.Context, leituras []portas.LeituraDeSensor) error {
+	emLote, ok := r.PortaDeEscrita.(portas.PortaDeEscritaEmLote)
+	if !ok {
+		return fmt.Errorf("porta de escrita sem suporte a lote")
+	}
+	err := emLote.GravarLote(ctx, leituras)
+	if err == nil {
+		for _, leitura := range leituras {
+			r.Manifesto.Registrar(leitura.IdentificadorDoSensor, leitura.InstanteDoEvento)
+		}
+	}
+	return err
+}
+
+// Repassa o ajuste de consistência quando a porta decorada o suporta.
+func (r *RepositorioDeEscritaComManifesto) DefinirConsistencia(operacao portas.TipoDeOperacao, nivel string) error {
+	if ajustavel, ok := r.PortaDeEscrita.(portas.PortaDeConsistenciaAjustavel); ok {
+		return ajustavel.DefinirConsistencia(operacao, nivel)
+	}
+	return nil
+}
@@ -1,41 +1,78 @@
 package adaptadores
 
 import (
+	"fmt"
+	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/aplicacao"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 type RegistradorDeMetricasPrometheus struct {
-	HistLatenciaMs *prometheus.HistogramVec
-	CntErros       *prometheus.CounterVec
+	HistLatenciaMs        *prometheus.HistogramVec
+	HistLatenciaLeituraMs *prometheus.HistogramVec
+	CntErros              *prometheus.CounterVec
+	execucao              atomic.Value // nome da execução corrente (rótulo "execucao")
 }
 
 func NovoRegistradorDeMetricas() *RegistradorDeMetricasPrometheus {
+	buckets := []float64{1, 5, 10, 20, 50, 100, 200, 500, 1000, 2000}
 	h := prometheus.NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "stress_write_latency_ms",
 		Help:    "Latencia de escrita em ms",
-		Buckets: []float64{1, 5, 10, 20, 50, 100, 200, 500, 1000, 2000},
-	}, []string{"consistencia"})
+		Buckets: buckets,
+	}, []string{"execucao", "consistencia"})
+	hl := prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "stress_read_latency_ms",
+		Help:    "Latencia de leitura em ms por operacao (ultimas|intervalo)",
+		Buckets: buckets,
+	}, []string{"execucao", "operacao", "consistencia"})
 	c := prometheus.NewCounterVec(prometheus.CounterOpts{
 		Name: "stress_errors_total",
-		Help: "Erros totais por motivo",
-	}, []string{"motivo"})
-	prometheus.MustRegister(h, c)
-	return &RegistradorDeMetricasPrometheus{HistLatenciaMs: h, CntErros: c}
+		Help: "Erros totais por operacao e motivo",
+	}, []string{"execucao", "operacao", "motivo"})
+	prometheus.MustRegister(h, hl, c)
+	r := &RegistradorDeMetricasPrometheus{HistLatenciaMs: h, HistLatenciaLeituraMs: hl, CntErros: c}
+	r.execucao.Store("padrao")
+	return r
 }
 
-func (r *RegistradorDeMetricasPrometheus) RegistrarLatenciaEmMs(rotuloConsistencia string, dur time.Duration) {
-	r.HistLatenciaMs.WithLabelValues(rotuloConsistencia).Observe(float64(dur.Milliseconds()))
+func (r *RegistradorDeMetricasPrometheus) DefinirExecucao(nome string) {
+	r.execucao.Store(nome)
 }
 
-func (r *RegistradorDeMetricasPrometheus) RegistrarErro(motivo string) {
-	r.CntErros.WithLabelValues(motivo).Inc()
+func (r *RegistradorDeMetricasPrometheus) rotuloDeExecucao() string {
+	return r.execucao.Load().(string)
 }
 
-func IniciarServidorDeMetricas(endereco string) {
+func (r *RegistradorDeMetricasPrometheus) RegistrarLatenciaEmMs(operacao portas.TipoDeOperacao, rotuloConsistencia string, dur time.Duration) {
+	if operacao == portas.OperacaoEscrita {
+		r.HistLatenciaMs.WithLabelValues(r.rotuloDeExecucao(), rotuloConsistencia).Observe(float64(dur.Milliseconds()))
+		return
+	}
+	r.HistLatenciaLeituraMs.WithLabelValues(r.rotuloDeExecucao(), string(operacao), rotuloConsistencia).Observe(float64(dur.Milliseconds()))
+}
+
+func (r *RegistradorDeMetricasPrometheus) RegistrarErro(operacao portas.TipoDeOperacao, motivo string) {
+	r.CntErros.WithLabelValues(r.rotuloDeExecucao(), string(operacao), motivo).Inc()
+}
+
+// Expõe /metrics e, se houver controle, a API /controle/* no mesmo endereço. A porta é aberta antes
+// de retornar: porta ocupada é erro, e não uma API de controle que nunca responde.
+func IniciarServidorDeMetricas(endereco string, controle *aplicacao.ControleDeExecucao) error {
+	ouvinte, err := net.Listen("tcp", endereco)
+	if err != nil {
+		return fmt.Errorf("servidor de metricas em %s: %w", endereco, err)
+	}
 	http.Handle("/metrics", promhttp.Handler())
-	go http.ListenAndServe(endereco, nil)
+	if controle != nil {
+		RegistrarRotasDeControle(http.DefaultServeMux, controle)
+	}
+	go http.Serve(ouvinte, nil)
+	return nil
 }
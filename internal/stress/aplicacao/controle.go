@@ -0,0 +1,310 @@
+package aplicacao
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+var (
+	ErrExecucaoEmAndamento = errors.New("ja existe uma execucao em andamento")
+	ErrNenhumaExecucao     = errors.New("nenhuma execucao em andamento")
+	ErrPedidosDesativados  = errors.New("novas execucoes desativadas (inicie com -keep-alive ou -wait-start)")
+)
+
+// Limite para entregar um pedido já reservado a quem está em AguardarPedido.
+const prazoDaEntregaDoPedido = 5 * time.Second
+
+// Pedido de início de uma nova execução nomeada (API de controle).
+type PedidoDeExecucao struct {
+	Nome    string        `json:"nome"`
+	Taxa    float64       `json:"rps,omitempty"`
+	Duracao time.Duration `json:"-"`
+}
+
+type StatusDaExecucao struct {
+	Execucao      string            `json:"execucao"`
+	EmExecucao    bool              `json:"em_execucao"`
+	Pausado       bool              `json:"pausado"`
+	TaxaAlvo      float64           `json:"rps_alvo"`
+	Concorrencia  int               `json:"concorrencia"`
+	Consistencias map[string]string `json:"consistencias"`
+	Total         int64             `json:"total"`
+	Ok            int64             `json:"ok"`
+	IniciadaEm    time.Time         `json:"iniciada_em,omitempty"`
+	DecorridoSeg  float64           `json:"decorrido_s"`
+}
+
+// Estado ajustável de uma execução do ServicoDeStress, compartilhado com a API de controle.
+// Taxa sobrescrita 0 significa seguir a configuração (ou o perfil de carga).
+type ControleDeExecucao struct {
+	mu              sync.Mutex
+	nome            string
+	taxaSobrescrita float64
+	taxaAtual       float64
+	pausado         bool
+	emExecucao      bool
+	iniciadaEm      time.Time
+	consistencias   map[portas.TipoDeOperacao]string
+	ajustaveis      []portas.PortaDeConsistenciaAjustavel
+	semaforo        *semaforoAjustavel
+	concorrencia    int
+	cancelar        context.CancelFunc
+	total, ok       *atomic.Int64
+	pedidos         chan PedidoDeExecucao
+	aceitaPedidos   bool
+	aguardando      bool // há alguém em AguardarPedido
+}
+
+func NovoControleDeExecucao(nomeInicial string) *ControleDeExecucao {
+	return &ControleDeExecucao{
+		nome:          nomeInicial,
+		consistencias: map[portas.TipoDeOperacao]string{},
+		pedidos:       make(chan PedidoDeExecucao),
+	}
+}
+
+func (c *ControleDeExecucao) NomeDaExecucao() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.nome
+}
+
+func (c *ControleDeExecucao) Status() StatusDaExecucao {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := StatusDaExecucao{
+		Execucao:      c.nome,
+		EmExecucao:    c.emExecucao,
+		Pausado:       c.pausado,
+		TaxaAlvo:      c.taxaAtual,
+		Concorrencia:  c.concorrencia,
+		Consistencias: map[string]string{},
+		IniciadaEm:    c.iniciadaEm,
+	}
+	for op, nivel := range c.consistencias {
+		st.Consistencias[string(op)] = nivel
+	}
+	if c.total != nil {
+		st.Total, st.Ok = c.total.Load(), c.ok.Load()
+	}
+	if c.emExecucao {
+		st.DecorridoSeg = time.Since(c.iniciadaEm).Seconds()
+	}
+	return st
+}
+
+func (c *ControleDeExecucao) AjustarTaxa(taxa float64) error {
+	if taxa < 0 {
+		return fmt.Errorf("rps negativo: %.1f", taxa)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.taxaSobrescrita = taxa
+	return nil
+}
+
+func (c *ControleDeExecucao) AjustarConcorrencia(n int) error {
+	if n <= 0 {
+		return fmt.Errorf("concorrencia deve ser positiva: %d", n)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.concorrencia = n
+	if c.semaforo != nil {
+		c.semaforo.DefinirLimite(n)
+	}
+	return nil
+}
+
+// Troca o nível de consistência de uma operação em todos os adaptadores que suportam ajuste.
+func (c *ControleDeExecucao) AjustarConsistencia(operacao portas.TipoDeOperacao, nivel string) error {
+	nivel = strings.ToUpper(strings.TrimSpace(nivel))
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.ajustaveis) == 0 {
+		return fmt.Errorf("adaptadores atuais nao permitem ajustar a consistencia")
+	}
+	for _, a := range c.ajustaveis {
+		if err := a.DefinirConsistencia(operacao, nivel); err != nil {
+			return err
+		}
+	}
+	c.consistencias[operacao] = nivel
+	return nil
+}
+
+func (c *ControleDeExecucao) Pausar()  { c.definirPausa(true) }
+func (c *ControleDeExecucao) Retomar() { c.definirPausa(false) }
+
+func (c *ControleDeExecucao) definirPausa(pausado bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pausado = pausado
+}
+
+// Encerra a execução em andamento (o serviço retorna normalmente com os contadores parciais).
+func (c *ControleDeExecucao) Parar() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.emExecucao || c.cancelar == nil {
+		return ErrNenhumaExecucao
+	}
+	c.cancelar()
+	return nil
+}
+
+// Habilita SolicitarExecucao; chamado apenas quando há um laço em AguardarPedido.
+func (c *ControleDeExecucao) AceitarPedidos() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.aceitaPedidos = true
+}
+
+// Solicita uma nova execução nomeada; atendida por quem estiver em AguardarPedido. A execução fica
+// reservada (emExecucao) sob o mutex antes da entrega, então um segundo pedido recebe
+// ErrExecucaoEmAndamento em vez de ficar na fila para a execução seguinte.
+func (c *ControleDeExecucao) SolicitarExecucao(pedido PedidoDeExecucao) error {
+	pedido.Nome = strings.TrimSpace(pedido.Nome)
+	if pedido.Nome == "" {
+		return fmt.Errorf("nome da execucao obrigatorio")
+	}
+	c.mu.Lock()
+	switch {
+	case !c.aceitaPedidos:
+		c.mu.Unlock()
+		return ErrPedidosDesativados
+	case c.emExecucao || !c.aguardando:
+		c.mu.Unlock()
+		return ErrExecucaoEmAndamento
+	}
+	c.emExecucao, c.aguardando = true, false
+	c.mu.Unlock()
+	select {
+	case c.pedidos <- pedido:
+		return nil
+	case <-time.After(prazoDaEntregaDoPedido):
+		// Quem aguardava desistiu (contexto cancelado)
+		c.mu.Lock()
+		c.emExecucao = false
+		c.mu.Unlock()
+		return ErrExecucaoEmAndamento
+	}
+}
+
+// Bloqueia até a próxima solicitação de execução (ou cancelamento do contexto).
+func (c *ControleDeExecucao) AguardarPedido(ctx context.Context) (PedidoDeExecucao, bool) {
+	c.mu.Lock()
+	c.aguardando = true
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.aguardando = false
+		c.mu.Unlock()
+	}()
+	select {
+	case <-ctx.Done():
+		return PedidoDeExecucao{}, false
+	case p := <-c.pedidos:
+		c.mu.Lock()
+		c.nome = p.Nome
+		c.taxaSobrescrita = p.Taxa
+		c.pausado = false
+		c.mu.Unlock()
+		return p, true
+	}
+}
+
+// Chamado pelo serviço no início de cada execução.
+func (c *ControleDeExecucao) vincular(cfg ConfiguracaoDoTesteDeStress, cancelar context.CancelFunc, semaforo *semaforoAjustavel,
+	total, ok *atomic.Int64, ajustaveis []portas.PortaDeConsistenciaAjustavel) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.emExecucao = true
+	c.iniciadaEm = time.Now()
+	c.cancelar = cancelar
+	c.semaforo = semaforo
+	c.concorrencia = cfg.GrauDeConcorrencia
+	c.total, c.ok = total, ok
+	c.ajustaveis = ajustaveis
+	for _, op := range []portas.TipoDeOperacao{portas.OperacaoEscrita, portas.OperacaoLeituraUltimas, portas.OperacaoLeituraIntervalo} {
+		if _, definido := c.consistencias[op]; !definido {
+			c.consistencias[op] = cfg.ConsistenciaDaOperacao(op)
+		}
+	}
+}
+
+func (c *ControleDeExecucao) desvincular() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.emExecucao = false
+	c.cancelar = nil
+	c.semaforo = nil
+}
+
+// Aplica pausa e taxa sobrescrita sobre a taxa da configuração/perfil.
+func (c *ControleDeExecucao) taxaEfetiva(taxaConfigurada float64) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	taxa := taxaConfigurada
+	if c.taxaSobrescrita > 0 {
+		taxa = c.taxaSobrescrita
+	}
+	if c.pausado {
+		taxa = 0
+	}
+	c.taxaAtual = taxa
+	return taxa
+}
+
+func (c *ControleDeExecucao) consistenciaDaOperacao(operacao portas.TipoDeOperacao, padrao string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if nivel, ok := c.consistencias[operacao]; ok && nivel != "" {
+		return nivel
+	}
+	return padrao
+}
+
+// Semáforo cujo limite pode ser alterado durante a execução.
+type semaforoAjustavel struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	limite int
+	emUso  int
+}
+
+func novoSemaforoAjustavel(limite int) *semaforoAjustavel {
+	s := &semaforoAjustavel{limite: limite}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *semaforoAjustavel) Adquirir() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.emUso >= s.limite {
+		s.cond.Wait()
+	}
+	s.emUso++
+}
+
+func (s *semaforoAjustavel) Liberar() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emUso--
+	s.cond.Broadcast()
+}
+
+func (s *semaforoAjustavel) DefinirLimite(limite int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limite = limite
+	s.cond.Broadcast()
+}
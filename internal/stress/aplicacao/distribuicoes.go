@@ -0,0 +1,287 @@
+package aplicacao
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Escolhe o índice do sensor (0..N-1) de cada operação. Implementações são seguras para uso concorrente.
+type DistribuicaoDeSensores interface {
+	ProximoSensor() int
+}
+
+// Define o instante do evento de cada escrita a partir do relógio atual.
+type DistribuicaoDeInstantes interface {
+	ProximoInstante(agora time.Time) time.Time
+}
+
+type ParametrosDeDistribuicaoDeSensores struct {
+	Tipo            string  // uniforme|zipf|hotspot|ponderada
+	ExpoenteZipf    float64 // zipf: s > 1 (quanto maior, mais concentrado)
+	FracaoQuente    float64 // hotspot: fração dos sensores considerados quentes
+	FracaoDoTrafego float64 // hotspot: fração do tráfego destinada aos sensores quentes
+	TaxasPorSensor  string  // ponderada: "0:50,1-9:5" (pesos relativos; demais sensores = 1)
+}
+
+type ParametrosDeDistribuicaoDeInstantes struct {
+	Tipo                    string        // atual|desordenado|retroativo
+	AtrasoMaximo            time.Duration // desordenado: atraso uniforme em [0, AtrasoMaximo]
+	ProbabilidadeRetroativa float64       // retroativo: fração das escritas enviadas para dias anteriores
+	DiasRetroativosMaximos  int           // retroativo: até quantos dias para trás
+	InclinacaoDosDias       float64       // retroativo: peso do dia k proporcional a 1/k^inclinacao
+}
+
+// Fonte aleatória protegida por mutex (rand.Rand não é seguro para uso concorrente).
+type fonteAleatoria struct {
+	mu sync.Mutex
+	r  *rand.Rand
+}
+
+func novaFonteAleatoria(semente int64) *fonteAleatoria {
+	return &fonteAleatoria{r: rand.New(rand.NewSource(semente))}
+}
+
+func (f *fonteAleatoria) Intn(n int) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.r.Intn(n)
+}
+
+func (f *fonteAleatoria) Float64() float64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.r.Float64()
+}
+
+func (f *fonteAleatoria) Int63n(n int64) int64 {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.r.Int63n(n)
+}
+
+// Gera uma semente a partir do relógio quando nenhuma foi informada.
+func SementeOuAleatoria(semente int64) int64 {
+	if semente != 0 {
+		return semente
+	}
+	return time.Now().UnixNano()
+}
+
+func NovaDistribuicaoDeSensores(p ParametrosDeDistribuicaoDeSensores, quantidade int, semente int64) (DistribuicaoDeSensores, error) {
+	if quantidade <= 0 {
+		return nil, fmt.Errorf("quantidade de sensores deve ser positiva: %d", quantidade)
+	}
+	tipo := strings.ToLower(strings.TrimSpace(p.Tipo))
+	if tipo == "" || tipo == "uniforme" {
+		if strings.TrimSpace(p.TaxasPorSensor) != "" {
+			tipo = "ponderada"
+		} else {
+			tipo = "uniforme"
+		}
+	}
+	fonte := novaFonteAleatoria(semente)
+	switch tipo {
+	case "uniforme":
+		return &distribuicaoUniforme{fonte: fonte, quantidade: quantidade}, nil
+	case "zipf":
+		s := p.ExpoenteZipf
+		if s <= 1 {
+			return nil, fmt.Errorf("expoente zipf deve ser > 1: %.3f", s)
+		}
+		return &distribuicaoZipf{zipf: rand.NewZipf(fonte.r, s, 1, uint64(quantidade-1)), fonte: fonte}, nil
+	case "hotspot":
+		if p.FracaoQuente <= 0 || p.FracaoQuente >= 1 || p.FracaoDoTrafego < 0 || p.FracaoDoTrafego > 1 {
+			return nil, fmt.Errorf("hotspot exige 0<fracao_quente<1 e 0<=fracao_trafego<=1 (recebido %.3f/%.3f)", p.FracaoQuente, p.FracaoDoTrafego)
+		}
+		if quantidade < 2 {
+			return nil, fmt.Errorf("hotspot exige ao menos 2 sensores (um quente e um frio): %d", quantidade)
+		}
+		quentes := int(math.Ceil(float64(quantidade) * p.FracaoQuente))
+		if quentes >= quantidade {
+			quentes = quantidade - 1
+		}
+		return &distribuicaoHotspot{fonte: fonte, quantidade: quantidade, quentes: quentes, fracaoDoTrafego: p.FracaoDoTrafego}, nil
+	case "ponderada":
+		pesos, err := parsearTaxasPorSensor(p.TaxasPorSensor, quantidade)
+		if err != nil {
+			return nil, err
+		}
+		return novaDistribuicaoPonderada(fonte, pesos), nil
+	default:
+		return nil, fmt.Errorf("distribuicao de sensores desconhecida: %s", p.Tipo)
+	}
+}
+
+type distribuicaoUniforme struct {
+	fonte      *fonteAleatoria
+	quantidade int
+}
+
+func (d *distribuicaoUniforme) ProximoSensor() int { return d.fonte.Intn(d.quantidade) }
+
+type distribuicaoZipf struct {
+	zipf  *rand.Zipf
+	fonte *fonteAleatoria // o Zipf compartilha o rand.Rand da fonte
+}
+
+func (d *distribuicaoZipf) ProximoSensor() int {
+	d.fonte.mu.Lock()
+	defer d.fonte.mu.Unlock()
+	return int(d.zipf.Uint64())
+}
+
+// Uma fração pequena dos sensores (os primeiros índices) recebe a maior parte do tráfego.
+type distribuicaoHotspot struct {
+	fonte           *fonteAleatoria
+	quantidade      int
+	quentes         int
+	fracaoDoTrafego float64
+}
+
+func (d *distribuicaoHotspot) ProximoSensor() int {
+	if d.fonte.Float64() < d.fracaoDoTrafego {
+		return d.fonte.Intn(d.quentes)
+	}
+	return d.quentes + d.fonte.Intn(d.quantidade-d.quentes)
+}
+
+// Seleção proporcional a pesos por sensor (taxas relativas), via busca binária no acumulado.
+type distribuicaoPonderada struct {
+	fonte     *fonteAleatoria
+	acumulado []float64
+}
+
+func novaDistribuicaoPonderada(fonte *fonteAleatoria, pesos []float64) *distribuicaoPonderada {
+	acumulado := make([]float64, len(pesos))
+	soma := 0.0
+	for i, p := range pesos {
+		soma += p
+		acumulado[i] = soma
+	}
+	return &distribuicaoPonderada{fonte: fonte, acumulado: acumulado}
+}
+
+func (d *distribuicaoPonderada) ProximoSensor() int {
+	alvo := d.fonte.Float64() * d.acumulado[len(d.acumulado)-1]
+	// Primeiro acumulado > alvo: um sorteio exatamente na fronteira não cai em sensor de peso zero
+	return sort.Search(len(d.acumulado), func(i int) bool { return d.acumulado[i] > alvo })
+}
+
+// Converte "0:50,1-9:5" em pesos por índice (demais sensores com peso 1).
+func parsearTaxasPorSensor(texto string, quantidade int) ([]float64, error) {
+	pesos := make([]float64, quantidade)
+	for i := range pesos {
+		pesos[i] = 1
+	}
+	for _, parte := range strings.Split(texto, ",") {
+		parte = strings.TrimSpace(parte)
+		if parte == "" {
+			continue
+		}
+		faixa, pesoTexto, ok := strings.Cut(parte, ":")
+		if !ok {
+			return nil, fmt.Errorf("taxa por sensor invalida %q (use indice:peso ou inicio-fim:peso)", parte)
+		}
+		peso, err := strconv.ParseFloat(strings.TrimSpace(pesoTexto), 64)
+		if err != nil || peso < 0 {
+			return nil, fmt.Errorf("peso invalido em %q", parte)
+		}
+		inicioTexto, fimTexto, ehFaixa := strings.Cut(faixa, "-")
+		inicio, err := strconv.Atoi(strings.TrimSpace(inicioTexto))
+		if err != nil {
+			return nil, fmt.Errorf("indice invalido em %q", parte)
+		}
+		fim := inicio
+		if ehFaixa {
+			if fim, err = strconv.Atoi(strings.TrimSpace(fimTexto)); err != nil {
+				return nil, fmt.Errorf("indice invalido em %q", parte)
+			}
+		}
+		if inicio < 0 || fim >= quantidade || fim < inicio {
+			return nil, fmt.Errorf("faixa fora de 0..%d em %q", quantidade-1, parte)
+		}
+		for i := inicio; i <= fim; i++ {
+			pesos[i] = peso
+		}
+	}
+	soma := 0.0
+	for _, p := range pesos {
+		soma += p
+	}
+	if soma <= 0 {
+		return nil, fmt.Errorf("taxas por sensor sem peso positivo")
+	}
+	return pesos, nil
+}
+
+func NovaDistribuicaoDeInstantes(p ParametrosDeDistribuicaoDeInstantes, semente int64) (DistribuicaoDeInstantes, error) {
+	fonte := novaFonteAleatoria(semente)
+	switch strings.ToLower(strings.TrimSpace(p.Tipo)) {
+	case "", "atual":
+		return instantesAtuais{}, nil
+	case "desordenado":
+		if p.AtrasoMaximo <= 0 {
+			return nil, fmt.Errorf("desordenado exige atraso maximo positivo")
+		}
+		return &instantesDesordenados{fonte: fonte, atrasoMaximo: p.AtrasoMaximo}, nil
+	case "retroativo":
+		if p.ProbabilidadeRetroativa < 0 || p.ProbabilidadeRetroativa > 1 {
+			return nil, fmt.Errorf("probabilidade retroativa fora de [0,1]: %.3f", p.ProbabilidadeRetroativa)
+		}
+		if p.DiasRetroativosMaximos <= 0 {
+			return nil, fmt.Errorf("retroativo exige dias maximos positivos")
+		}
+		pesos := make([]float64, p.DiasRetroativosMaximos)
+		for k := range pesos {
+			pesos[k] = 1 / math.Pow(float64(k+1), p.InclinacaoDosDias)
+		}
+		return &instantesRetroativos{
+			fonte:         fonte,
+			probabilidade: p.ProbabilidadeRetroativa,
+			atrasoMaximo:  p.AtrasoMaximo,
+			dias:          novaDistribuicaoPonderada(fonte, pesos),
+		}, nil
+	default:
+		return nil, fmt.Errorf("distribuicao de instantes desconhecida: %s", p.Tipo)
+	}
+}
+
+type instantesAtuais struct{}
+
+func (instantesAtuais) ProximoInstante(agora time.Time) time.Time { return agora }
+
+// Eventos chegam fora de ordem: cada instante recua um atraso aleatório.
+type instantesDesordenados struct {
+	fonte        *fonteAleatoria
+	atrasoMaximo time.Duration
+}
+
+func (d *instantesDesordenados) ProximoInstante(agora time.Time) time.Time {
+	return agora.Add(-time.Duration(d.fonte.Int63n(int64(d.atrasoMaximo) + 1)))
+}
+
+// Parte das escritas chega atrasada em dias anteriores, com viés para os dias mais recentes.
+type instantesRetroativos struct {
+	fonte         *fonteAleatoria
+	probabilidade float64
+	atrasoMaximo  time.Duration // atraso adicional opcional para as demais escritas
+	dias          *distribuicaoPonderada
+}
+
+func (d *instantesRetroativos) ProximoInstante(agora time.Time) time.Time {
+	if d.fonte.Float64() >= d.probabilidade {
+		if d.atrasoMaximo > 0 {
+			return agora.Add(-time.Duration(d.fonte.Int63n(int64(d.atrasoMaximo) + 1)))
+		}
+		return agora
+	}
+	diasAtras := d.dias.ProximoSensor() + 1
+	dia := time.Date(agora.Year(), agora.Month(), agora.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, -diasAtras)
+	return dia.Add(time.Duration(d.fonte.Int63n(int64(24 * time.Hour))))
+}
@@ -0,0 +1,100 @@
+package aplicacao
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Peso relativo de um tipo de operação na mistura de carga.
+type PesoDeOperacao struct {
+	Operacao portas.TipoDeOperacao
+	Peso     int
+}
+
+// Mistura padrão: somente escritas (comportamento original do go-stress).
+func MisturaSomenteEscrita() []PesoDeOperacao {
+	return []PesoDeOperacao{{Operacao: portas.OperacaoEscrita, Peso: 100}}
+}
+
+// Converte "escrita=70,ultimas=20,intervalo=10" em uma lista de pesos.
+func ParsearMisturaDeOperacoes(texto string) ([]PesoDeOperacao, error) {
+	texto = strings.TrimSpace(texto)
+	if texto == "" {
+		return MisturaSomenteEscrita(), nil
+	}
+	var mistura []PesoDeOperacao
+	soma := 0
+	for _, parte := range strings.Split(texto, ",") {
+		parte = strings.TrimSpace(parte)
+		if parte == "" {
+			continue
+		}
+		nome, pesoTexto, ok := strings.Cut(parte, "=")
+		if !ok {
+			return nil, fmt.Errorf("mistura invalida %q (use operacao=peso)", parte)
+		}
+		op := portas.TipoDeOperacao(strings.ToLower(strings.TrimSpace(nome)))
+		switch op {
+		case portas.OperacaoEscrita, portas.OperacaoLeituraUltimas, portas.OperacaoLeituraIntervalo:
+		default:
+			return nil, fmt.Errorf("operacao desconhecida na mistura: %s", nome)
+		}
+		for _, existente := range mistura {
+			if existente.Operacao == op {
+				return nil, fmt.Errorf("operacao repetida na mistura: %s", op)
+			}
+		}
+		peso, err := strconv.Atoi(strings.TrimSpace(pesoTexto))
+		if err != nil || peso < 0 {
+			return nil, fmt.Errorf("peso invalido para %s: %q", nome, pesoTexto)
+		}
+		soma += peso
+		mistura = append(mistura, PesoDeOperacao{Operacao: op, Peso: peso})
+	}
+	if soma == 0 {
+		return nil, fmt.Errorf("mistura sem pesos positivos: %q", texto)
+	}
+	return mistura, nil
+}
+
+// Indica se a mistura contém alguma operação de leitura com peso positivo.
+func MisturaContemLeituras(mistura []PesoDeOperacao) bool {
+	for _, m := range mistura {
+		if m.Operacao != portas.OperacaoEscrita && m.Peso > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Indica se a mistura contém escritas com peso positivo.
+func MisturaContemEscritas(mistura []PesoDeOperacao) bool {
+	for _, m := range mistura {
+		if m.Operacao == portas.OperacaoEscrita && m.Peso > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Sorteia uma operação proporcionalmente aos pesos.
+func sortearOperacao(mistura []PesoDeOperacao, sorteio func(n int) int) portas.TipoDeOperacao {
+	soma := 0
+	for _, m := range mistura {
+		soma += m.Peso
+	}
+	if soma <= 0 {
+		return portas.OperacaoEscrita
+	}
+	alvo := sorteio(soma)
+	for _, m := range mistura {
+		if alvo < m.Peso {
+			return m.Operacao
+		}
+		alvo -= m.Peso
+	}
+	return mistura[len(mistura)-1].Operacao
+}
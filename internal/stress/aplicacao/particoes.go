@@ -0,0 +1,71 @@
+package aplicacao
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/conteudo"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Escolhe a partição (sensor e dia) de cada leitura. Implementações são seguras para uso concorrente.
+type FonteDeParticoes interface {
+	ProximaParticao() conteudo.ParticaoDoManifesto
+}
+
+// Sorteio uniforme entre as partições do manifesto: as leituras consultam dados que foram escritos.
+type particoesDoManifesto struct {
+	fonte     *fonteAleatoria
+	particoes []conteudo.ParticaoDoManifesto
+}
+
+func NovaFonteDeParticoes(particoes []conteudo.ParticaoDoManifesto, semente int64) (FonteDeParticoes, error) {
+	if len(particoes) == 0 {
+		return nil, fmt.Errorf("manifesto sem particoes")
+	}
+	return &particoesDoManifesto{fonte: novaFonteAleatoria(semente), particoes: particoes}, nil
+}
+
+func (p *particoesDoManifesto) ProximaParticao() conteudo.ParticaoDoManifesto {
+	return p.particoes[p.fonte.Intn(len(p.particoes))]
+}
+
+// Alvo de uma leitura, sorteado no laço de despacho para que a mesma semente reproduza as consultas.
+type consultaSorteada struct {
+	sensor      string
+	dia         time.Time
+	inicio, fim time.Time // só em leituras por intervalo
+}
+
+// Sem fonte de partições: o sensor sorteado no dia atual, com a janela terminando agora.
+// Com fonte: a janela começa em um ponto sorteado entre a primeira e a última escrita da partição.
+func montarConsulta(operacao portas.TipoDeOperacao, id string, agora time.Time, cfg ConfiguracaoDoTesteDeStress, fonte *fonteAleatoria) consultaSorteada {
+	var janela time.Duration
+	if operacao == portas.OperacaoLeituraIntervalo {
+		janela = cfg.JanelaDeLeituraPorIntervalo
+		if len(cfg.JanelasDeLeituraPorIntervalo) > 0 {
+			janela = cfg.JanelasDeLeituraPorIntervalo[fonte.Intn(len(cfg.JanelasDeLeituraPorIntervalo))]
+		}
+		if janela <= 0 {
+			janela = 5 * time.Minute
+		}
+	}
+	if cfg.ParticoesDeLeitura == nil {
+		dia := time.Date(agora.Year(), agora.Month(), agora.Day(), 0, 0, 0, 0, time.UTC)
+		inicio := agora.Add(-janela)
+		if inicio.Before(dia) {
+			inicio = dia
+		}
+		return consultaSorteada{sensor: id, dia: dia, inicio: inicio, fim: agora}
+	}
+
+	p := cfg.ParticoesDeLeitura.ProximaParticao()
+	dia, _ := time.Parse("2006-01-02", p.Dia) // gravado pelo próprio manifesto
+	inicio := p.Primeiro
+	if folga := p.Ultimo.Sub(p.Primeiro) - janela; operacao == portas.OperacaoLeituraIntervalo && folga > 0 {
+		inicio = inicio.Add(time.Duration(fonte.Int63n(int64(folga))))
+	} else if p.Ultimo.Equal(p.Primeiro) {
+		inicio = p.Ultimo.Add(-janela) // uma única escrita: janela terminando nela
+	}
+	return consultaSorteada{sensor: p.Sensor, dia: dia, inicio: inicio.UTC(), fim: inicio.Add(janela).UTC()}
+}
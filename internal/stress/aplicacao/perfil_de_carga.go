@@ -0,0 +1,245 @@
+package aplicacao
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type TipoDeFase string
+
+const (
+	FaseConstante TipoDeFase = "constante"
+	FaseRampa     TipoDeFase = "rampa"
+	FaseDegrau    TipoDeFase = "degrau"
+	FaseSenoide   TipoDeFase = "seno"
+	FasePico      TipoDeFase = "pico"
+)
+
+// Fase do perfil de carga. A taxa "base" de cada fase é a taxa final da fase anterior.
+type FaseDeCarga struct {
+	Tipo          TipoDeFase
+	Duracao       time.Duration
+	TaxaAlvo      float64       // req/s ao final (constante/rampa/degrau), média (seno) ou durante o pico (pico)
+	Passos        int           // degrau: quantidade de degraus entre a base e a alvo
+	Amplitude     float64       // seno: amplitude em req/s
+	Periodo       time.Duration // seno: período da oscilação
+	DuracaoDoPico time.Duration // pico: duração do pico, centralizado na fase
+}
+
+type PerfilDeCarga struct {
+	TaxaInicial float64 // base da primeira fase
+	Fases       []FaseDeCarga
+}
+
+func (p PerfilDeCarga) DuracaoTotal() time.Duration {
+	var total time.Duration
+	for _, f := range p.Fases {
+		total += f.Duracao
+	}
+	return total
+}
+
+// Taxa desejada (req/s) após "decorrido" do início; fim=true quando todas as fases terminaram.
+func (p PerfilDeCarga) TaxaEm(decorrido time.Duration) (taxa float64, fim bool) {
+	base := p.TaxaInicial
+	for _, f := range p.Fases {
+		if decorrido < f.Duracao {
+			return f.taxaEm(base, decorrido), false
+		}
+		decorrido -= f.Duracao
+		base = f.taxaFinal(base)
+	}
+	return base, true
+}
+
+func (f FaseDeCarga) taxaEm(base float64, t time.Duration) float64 {
+	fracao := float64(t) / float64(f.Duracao)
+	switch f.Tipo {
+	case FaseRampa:
+		return base + (f.TaxaAlvo-base)*fracao
+	case FaseDegrau:
+		passos := f.Passos
+		if passos <= 0 {
+			passos = 1
+		}
+		degrau := math.Min(math.Floor(fracao*float64(passos))+1, float64(passos))
+		return base + (f.TaxaAlvo-base)*degrau/float64(passos)
+	case FaseSenoide:
+		return math.Max(0, f.TaxaAlvo+f.Amplitude*math.Sin(2*math.Pi*float64(t)/float64(f.Periodo)))
+	case FasePico:
+		inicioDoPico := (f.Duracao - f.DuracaoDoPico) / 2
+		if t >= inicioDoPico && t < inicioDoPico+f.DuracaoDoPico {
+			return f.TaxaAlvo
+		}
+		return base
+	default:
+		return f.TaxaAlvo
+	}
+}
+
+func (f FaseDeCarga) taxaFinal(base float64) float64 {
+	if f.Tipo == FasePico {
+		return base
+	}
+	return f.TaxaAlvo
+}
+
+func (f FaseDeCarga) validar() error {
+	if f.Duracao <= 0 {
+		return fmt.Errorf("fase %s com duracao invalida: %s", f.Tipo, f.Duracao)
+	}
+	if f.TaxaAlvo < 0 {
+		return fmt.Errorf("fase %s com taxa negativa: %.1f", f.Tipo, f.TaxaAlvo)
+	}
+	switch f.Tipo {
+	case FaseConstante, FaseRampa:
+	case FaseDegrau:
+		if f.Passos <= 0 {
+			return fmt.Errorf("fase degrau exige passos > 0")
+		}
+	case FaseSenoide:
+		if f.Periodo <= 0 {
+			return fmt.Errorf("fase seno exige periodo > 0")
+		}
+	case FasePico:
+		if f.DuracaoDoPico <= 0 || f.DuracaoDoPico > f.Duracao {
+			return fmt.Errorf("fase pico exige 0 < duracao do pico <= duracao da fase")
+		}
+	default:
+		return fmt.Errorf("tipo de fase desconhecido: %s", f.Tipo)
+	}
+	return nil
+}
+
+func (p PerfilDeCarga) Validar() error {
+	if len(p.Fases) == 0 {
+		return fmt.Errorf("perfil de carga sem fases")
+	}
+	for i, f := range p.Fases {
+		if err := f.validar(); err != nil {
+			return fmt.Errorf("fase %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// Sintaxe compacta, fases separadas por vírgula:
+//
+//	constante:30s:100  rampa:60s:1000  degrau:60s:2000:4  seno:120s:1000:500:30s  pico:30s:5000:5s
+func ParsearPerfilCompacto(texto string) (PerfilDeCarga, error) {
+	var perfil PerfilDeCarga
+	for _, parte := range strings.Split(texto, ",") {
+		parte = strings.TrimSpace(parte)
+		if parte == "" {
+			continue
+		}
+		campos := strings.Split(parte, ":")
+		if len(campos) < 3 {
+			return PerfilDeCarga{}, fmt.Errorf("fase invalida %q (use tipo:duracao:taxa[:extras])", parte)
+		}
+		fase := FaseDeCarga{Tipo: TipoDeFase(strings.ToLower(campos[0]))}
+		var err error
+		if fase.Duracao, err = time.ParseDuration(campos[1]); err != nil {
+			return PerfilDeCarga{}, fmt.Errorf("duracao invalida em %q: %w", parte, err)
+		}
+		if fase.TaxaAlvo, err = strconv.ParseFloat(campos[2], 64); err != nil {
+			return PerfilDeCarga{}, fmt.Errorf("taxa invalida em %q: %w", parte, err)
+		}
+		extras := campos[3:]
+		switch fase.Tipo {
+		case FaseDegrau:
+			if len(extras) != 1 {
+				return PerfilDeCarga{}, fmt.Errorf("degrau exige passos (degrau:duracao:taxa:passos): %q", parte)
+			}
+			if fase.Passos, err = strconv.Atoi(extras[0]); err != nil {
+				return PerfilDeCarga{}, fmt.Errorf("passos invalidos em %q: %w", parte, err)
+			}
+		case FaseSenoide:
+			if len(extras) != 2 {
+				return PerfilDeCarga{}, fmt.Errorf("seno exige amplitude e periodo (seno:duracao:media:amplitude:periodo): %q", parte)
+			}
+			if fase.Amplitude, err = strconv.ParseFloat(extras[0], 64); err != nil {
+				return PerfilDeCarga{}, fmt.Errorf("amplitude invalida em %q: %w", parte, err)
+			}
+			if fase.Periodo, err = time.ParseDuration(extras[1]); err != nil {
+				return PerfilDeCarga{}, fmt.Errorf("periodo invalido em %q: %w", parte, err)
+			}
+		case FasePico:
+			if len(extras) != 1 {
+				return PerfilDeCarga{}, fmt.Errorf("pico exige a duracao do pico (pico:duracao:taxa:duracao_pico): %q", parte)
+			}
+			if fase.DuracaoDoPico, err = time.ParseDuration(extras[0]); err != nil {
+				return PerfilDeCarga{}, fmt.Errorf("duracao do pico invalida em %q: %w", parte, err)
+			}
+		default:
+			if len(extras) != 0 {
+				return PerfilDeCarga{}, fmt.Errorf("parametros extras inesperados em %q", parte)
+			}
+		}
+		perfil.Fases = append(perfil.Fases, fase)
+	}
+	return perfil, perfil.Validar()
+}
+
+// Formato do perfil em arquivo (YAML ou JSON; durações como texto, ex.: "30s").
+type PerfilDeCargaEmArquivo struct {
+	TaxaInicial float64                `yaml:"taxa_inicial" json:"taxa_inicial"`
+	Fases       []FaseDeCargaEmArquivo `yaml:"fases" json:"fases"`
+}
+
+type FaseDeCargaEmArquivo struct {
+	Tipo          string  `yaml:"tipo" json:"tipo"`
+	Duracao       string  `yaml:"duracao" json:"duracao"`
+	Taxa          float64 `yaml:"taxa" json:"taxa"`
+	Passos        int     `yaml:"passos,omitempty" json:"passos,omitempty"`
+	Amplitude     float64 `yaml:"amplitude,omitempty" json:"amplitude,omitempty"`
+	Periodo       string  `yaml:"periodo,omitempty" json:"periodo,omitempty"`
+	DuracaoDoPico string  `yaml:"duracao_pico,omitempty" json:"duracao_pico,omitempty"`
+}
+
+func (a PerfilDeCargaEmArquivo) Converter() (PerfilDeCarga, error) {
+	perfil := PerfilDeCarga{TaxaInicial: a.TaxaInicial}
+	for i, fa := range a.Fases {
+		fase := FaseDeCarga{
+			Tipo:      TipoDeFase(strings.ToLower(strings.TrimSpace(fa.Tipo))),
+			TaxaAlvo:  fa.Taxa,
+			Passos:    fa.Passos,
+			Amplitude: fa.Amplitude,
+		}
+		var err error
+		if fase.Duracao, err = time.ParseDuration(fa.Duracao); err != nil {
+			return PerfilDeCarga{}, fmt.Errorf("fase %d: duracao invalida: %w", i+1, err)
+		}
+		if fa.Periodo != "" {
+			if fase.Periodo, err = time.ParseDuration(fa.Periodo); err != nil {
+				return PerfilDeCarga{}, fmt.Errorf("fase %d: periodo invalido: %w", i+1, err)
+			}
+		}
+		if fa.DuracaoDoPico != "" {
+			if fase.DuracaoDoPico, err = time.ParseDuration(fa.DuracaoDoPico); err != nil {
+				return PerfilDeCarga{}, fmt.Errorf("fase %d: duracao do pico invalida: %w", i+1, err)
+			}
+		}
+		perfil.Fases = append(perfil.Fases, fase)
+	}
+	return perfil, perfil.Validar()
+}
+
+// Carrega um perfil de arquivo YAML ou JSON (JSON é YAML válido).
+func CarregarPerfilDeCarga(caminho string) (PerfilDeCarga, error) {
+	conteudo, err := os.ReadFile(caminho)
+	if err != nil {
+		return PerfilDeCarga{}, err
+	}
+	var arquivo PerfilDeCargaEmArquivo
+	if err := yaml.Unmarshal(conteudo, &arquivo); err != nil {
+		return PerfilDeCarga{}, fmt.Errorf("perfil %s invalido: %w", caminho, err)
+	}
+	return arquivo.Converter()
+}
@@ -0,0 +1,173 @@
+package aplicacao
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pdrpinto/tcc-cassandra/internal/estatisticas"
+	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
+)
+
+// Critério da busca do ponto de saturação: a taxa sobe em degraus até que o p99,
+// a taxa de erro ou a vazão atingida violem os limites.
+type CriterioDeSaturacao struct {
+	TaxaInicial      float64
+	Incremento       float64
+	DuracaoDoDegrau  time.Duration
+	LimiteP99        time.Duration
+	LimiteTaxaDeErro float64       // fração (0.01 = 1%)
+	TaxaMaxima       float64       // 0 = sem teto
+	FracaoMinimaAlvo float64       // vazão atingida mínima em relação à alvo (padrão 0.9)
+	Aquecimento      time.Duration // período descartado no início de cada degrau
+}
+
+type MedicaoDeDegrau struct {
+	TaxaAlvo     float64
+	TaxaAtingida float64
+	P99          time.Duration
+	TaxaDeErro   float64
+	Aprovado     bool
+}
+
+type ResultadoDaSaturacao struct {
+	Consistencia          string
+	TaxaMaximaSustentavel float64 // vazão (ok/s) do último degrau aprovado
+	TaxaAlvoNaSaturacao   float64 // taxa alvo do primeiro degrau reprovado (0 se não saturou)
+	Motivo                string
+	Degraus               []MedicaoDeDegrau
+}
+
+func (c CriterioDeSaturacao) validar() error {
+	if c.TaxaInicial <= 0 || c.Incremento <= 0 {
+		return fmt.Errorf("taxa inicial e incremento devem ser positivos")
+	}
+	if c.DuracaoDoDegrau <= 0 {
+		return fmt.Errorf("duracao do degrau deve ser positiva")
+	}
+	if c.LimiteP99 <= 0 && c.LimiteTaxaDeErro <= 0 {
+		return fmt.Errorf("informe ao menos um limite (p99 ou taxa de erro)")
+	}
+	return nil
+}
+
+// Sobe a taxa em degraus até violar o critério e retorna a maior vazão sustentável.
+func (s *ServicoDeStress) BuscarPontoDeSaturacao(ctx context.Context, cfg ConfiguracaoDoTesteDeStress, criterio CriterioDeSaturacao) (ResultadoDaSaturacao, error) {
+	if err := criterio.validar(); err != nil {
+		return ResultadoDaSaturacao{}, err
+	}
+	fracaoMinima := criterio.FracaoMinimaAlvo
+	if fracaoMinima <= 0 {
+		fracaoMinima = 0.9
+	}
+
+	var taxaAlvo atomic.Uint64
+	taxaAlvo.Store(math.Float64bits(criterio.TaxaInicial))
+
+	// Janela de medição do degrau corrente
+	var mu sync.Mutex
+	histograma := estatisticas.NovoHistogramaDeLatencias()
+	var ok, erros int64
+	medindo := false
+	observador := func(_ portas.TipoDeOperacao, dur time.Duration, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !medindo {
+			return
+		}
+		if err != nil {
+			erros++
+			return
+		}
+		ok++
+		histograma.Registrar(dur)
+	}
+
+	ctxCarga, pararCarga := context.WithCancel(ctx)
+	defer pararCarga()
+	cargaTerminou := make(chan struct{})
+	go func() {
+		defer close(cargaTerminou)
+		s.executarCarga(ctxCarga, cfg, 0, func(time.Duration) (float64, bool) {
+			return math.Float64frombits(taxaAlvo.Load()), false
+		}, observador)
+	}()
+
+	resultado := ResultadoDaSaturacao{Consistencia: cfg.NivelDeConsistenciaTexto}
+	for taxa := criterio.TaxaInicial; criterio.TaxaMaxima <= 0 || taxa <= criterio.TaxaMaxima; taxa += criterio.Incremento {
+		taxaAlvo.Store(math.Float64bits(taxa))
+		if !esperar(ctx, criterio.Aquecimento) {
+			break
+		}
+		mu.Lock()
+		histograma.CopiarEZerar()
+		ok, erros, medindo = 0, 0, true
+		mu.Unlock()
+		inicioDaJanela := time.Now()
+		if !esperar(ctx, criterio.DuracaoDoDegrau) {
+			break
+		}
+		mu.Lock()
+		medindo = false
+		janela := histograma.CopiarEZerar()
+		okJanela, errosJanela := ok, erros
+		mu.Unlock()
+
+		medicao := MedicaoDeDegrau{
+			TaxaAlvo:     taxa,
+			TaxaAtingida: float64(okJanela) / time.Since(inicioDaJanela).Seconds(),
+			P99:          janela.Percentil(99),
+		}
+		if total := okJanela + errosJanela; total > 0 {
+			medicao.TaxaDeErro = float64(errosJanela) / float64(total)
+		}
+		motivo := ""
+		switch {
+		case criterio.LimiteP99 > 0 && medicao.P99 > criterio.LimiteP99:
+			motivo = fmt.Sprintf("p99 %s > limite %s", medicao.P99, criterio.LimiteP99)
+		case criterio.LimiteTaxaDeErro > 0 && medicao.TaxaDeErro > criterio.LimiteTaxaDeErro:
+			motivo = fmt.Sprintf("taxa de erro %.2f%% > limite %.2f%%", 100*medicao.TaxaDeErro, 100*criterio.LimiteTaxaDeErro)
+		case medicao.TaxaAtingida < fracaoMinima*taxa:
+			motivo = fmt.Sprintf("vazao atingida %.0f < %.0f%% da alvo %.0f", medicao.TaxaAtingida, 100*fracaoMinima, taxa)
+		}
+		medicao.Aprovado = motivo == ""
+		resultado.Degraus = append(resultado.Degraus, medicao)
+		fmt.Printf("[saturacao] cons=%s alvo=%.0f atingida=%.0f p99=%s erros=%.2f%% aprovado=%v\n",
+			resultado.Consistencia, taxa, medicao.TaxaAtingida, medicao.P99, 100*medicao.TaxaDeErro, medicao.Aprovado)
+		if !medicao.Aprovado {
+			resultado.TaxaAlvoNaSaturacao = taxa
+			resultado.Motivo = motivo
+			break
+		}
+		resultado.TaxaMaximaSustentavel = medicao.TaxaAtingida
+	}
+	if resultado.Motivo == "" {
+		if ctx.Err() != nil {
+			resultado.Motivo = "interrompido"
+		} else {
+			resultado.Motivo = "taxa maxima atingida sem saturar"
+		}
+	}
+
+	pararCarga()
+	<-cargaTerminou
+	return resultado, nil
+}
+
+// Espera a duração ou o cancelamento do contexto; retorna false se cancelado.
+func esperar(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
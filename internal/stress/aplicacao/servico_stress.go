@@ -2,59 +2,160 @@ package aplicacao
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"math/rand"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/pdrpinto/tcc-cassandra/internal/conteudo"
 	"github.com/pdrpinto/tcc-cassandra/internal/stress/portas"
 )
 
 type ConfiguracaoDoTesteDeStress struct {
-	ListaDeHostsCassandra         []string
-	NomeDoKeyspace                string
-	NivelDeConsistenciaTexto      string
-	DuracaoTotalDoTeste           time.Duration
-	TaxaDeRequisicoesPorSegundo   int
-	GrauDeConcorrencia            int
-	QuantidadeDeSensoresDistintos int
-	IntervaloDeLogDeProgresso     time.Duration // 0 desativa logs periódicos
+	ListaDeHostsCassandra             []string
+	NomeDoKeyspace                    string
+	NivelDeConsistenciaTexto          string // consistência das escritas
+	NivelDeConsistenciaUltimasTexto   string // consistência das leituras "ultimas N"
+	NivelDeConsistenciaIntervaloTexto string // consistência das leituras por intervalo
+	DuracaoTotalDoTeste               time.Duration
+	TaxaDeRequisicoesPorSegundo       int
+	GrauDeConcorrencia                int
+	QuantidadeDeSensoresDistintos     int
+	MisturaDeOperacoes                []PesoDeOperacao            // vazio = somente escrita
+	TamanhoDoLote                     int                         // leituras por escrita em lote (0 = uma, sem lote); exige portas.PortaDeEscritaEmLote
+	LimiteDeLeiturasUltimas           int                         // N das consultas "ultimas N"
+	JanelaDeLeituraPorIntervalo       time.Duration               // largura da janela das consultas por intervalo
+	JanelasDeLeituraPorIntervalo      []time.Duration             // larguras sorteadas por consulta; vazio = JanelaDeLeituraPorIntervalo
+	LimiteDeLeiturasPorIntervalo      int                         // limite das consultas por intervalo (0 = 1000)
+	ParticoesDeLeitura                FonteDeParticoes            // nil = sensor da distribuição no dia atual
+	DistribuicaoDeSensores            DistribuicaoDeSensores      // nil = uniforme
+	DistribuicaoDeInstantes           DistribuicaoDeInstantes     // nil = instante atual
+	SementeAleatoria                  int64                       // 0 = derivada do relógio (registrada no resultado)
+	PerfilDeCarga                     *PerfilDeCarga              // nil = taxa constante TaxaDeRequisicoesPorSegundo
+	GeradorDeConteudo                 *conteudo.GeradorDeConteudo // nil = unidade "C", status 0 e três etiquetas fixas
+	IntervaloDeLogDeProgresso         time.Duration               // 0 desativa logs periódicos
 }
 
 type ServicoDeStress struct {
 	Persistencia portas.PortaDeEscrita
+	Consultas    portas.PortaDeLeitura // obrigatório apenas se a mistura contiver leituras
 	Metricas     portas.PortaDeMetricas
+	Controle     *ControleDeExecucao // opcional: ajustes em tempo de execução via API de controle
 }
 
-func (s *ServicoDeStress) Executar(ctx context.Context, cfg ConfiguracaoDoTesteDeStress) (total int64, ok int64, duracao time.Duration) {
+// Contadores de uma operação ao final do teste.
+type ResultadoPorOperacao struct {
+	Total int64
+	Ok    int64
+}
+
+type ResultadoDoTesteDeStress struct {
+	Total       int64
+	Ok          int64
+	Duracao     time.Duration
+	Semente     int64
+	PorOperacao map[portas.TipoDeOperacao]ResultadoPorOperacao
+}
+
+type contadoresDeOperacao struct {
+	total, ok atomic.Int64
+}
+
+func (s *ServicoDeStress) Executar(ctx context.Context, cfg ConfiguracaoDoTesteDeStress) ResultadoDoTesteDeStress {
+	duracao := cfg.DuracaoTotalDoTeste
+	taxa := func(time.Duration) (float64, bool) { return float64(cfg.TaxaDeRequisicoesPorSegundo), false }
+	if cfg.PerfilDeCarga != nil {
+		perfil := *cfg.PerfilDeCarga
+		taxa = perfil.TaxaEm
+		if duracao <= 0 {
+			duracao = perfil.DuracaoTotal()
+		}
+	}
+	return s.executarCarga(ctx, cfg, duracao, taxa, nil)
+}
+
+// Motor de carga compartilhado: a taxa é consultada periodicamente (perfis e busca de saturação);
+// o observador, se presente, recebe o resultado de cada operação.
+func (s *ServicoDeStress) executarCarga(ctx context.Context, cfg ConfiguracaoDoTesteDeStress, duracao time.Duration,
+	taxaEm func(decorrido time.Duration) (float64, bool), observador func(op portas.TipoDeOperacao, dur time.Duration, err error)) ResultadoDoTesteDeStress {
 	var prazo context.Context
 	var cancelar context.CancelFunc
-	if cfg.DuracaoTotalDoTeste > 0 {
-		prazo, cancelar = context.WithTimeout(ctx, cfg.DuracaoTotalDoTeste)
+	if duracao > 0 {
+		prazo, cancelar = context.WithTimeout(ctx, duracao)
 	} else {
 		prazo, cancelar = context.WithCancel(ctx) // modo contínuo
 	}
 	defer cancelar()
 
+	semente := SementeOuAleatoria(cfg.SementeAleatoria)
+	fonte := novaFonteAleatoria(semente)
+	sensores := cfg.DistribuicaoDeSensores
+	if sensores == nil {
+		sensores = &distribuicaoUniforme{fonte: novaFonteAleatoria(semente + 1), quantidade: cfg.QuantidadeDeSensoresDistintos}
+	}
+	instantes := cfg.DistribuicaoDeInstantes
+	if instantes == nil {
+		instantes = instantesAtuais{}
+	}
+
+	mistura := cfg.MisturaDeOperacoes
+	if len(mistura) == 0 {
+		mistura = MisturaSomenteEscrita()
+	}
+	contadores := map[portas.TipoDeOperacao]*contadoresDeOperacao{}
+	for _, m := range mistura {
+		contadores[m.Operacao] = &contadoresDeOperacao{}
+	}
+
+	comResposta, _ := s.Metricas.(portas.PortaDeMetricasComResposta)
 	var totalContador, okContador atomic.Int64
-	tique := time.NewTicker(time.Second / time.Duration(cfg.TaxaDeRequisicoesPorSegundo))
+	inicio := time.Now()
+
+	sem := novoSemaforoAjustavel(cfg.GrauDeConcorrencia)
+	rotuloDeConsistencia := cfg.ConsistenciaDaOperacao
+	if s.Controle != nil {
+		controle := s.Controle
+		controle.vincular(cfg, cancelar, sem, &totalContador, &okContador, s.consistenciasAjustaveis())
+		defer controle.desvincular()
+		if rotulavel, ok := s.Metricas.(portas.PortaDeRotuloDeExecucao); ok {
+			rotulavel.DefinirExecucao(controle.NomeDaExecucao())
+		}
+		taxaConfigurada := taxaEm
+		taxaEm = func(decorrido time.Duration) (float64, bool) {
+			taxa, fim := taxaConfigurada(decorrido)
+			return controle.taxaEfetiva(taxa), fim
+		}
+		rotuloDeConsistencia = func(op portas.TipoDeOperacao) string {
+			return controle.consistenciaDaOperacao(op, cfg.ConsistenciaDaOperacao(op))
+		}
+	}
+
+	// Ritmo variável: o ticker é reajustado quando a taxa desejada muda; taxa <= 0 suspende o envio.
+	taxaAtual, fim := taxaEm(0)
+	if fim {
+		return ResultadoDoTesteDeStress{Semente: semente, PorOperacao: map[portas.TipoDeOperacao]ResultadoPorOperacao{}}
+	}
+	tique := time.NewTicker(intervaloParaTaxa(taxaAtual))
 	defer tique.Stop()
+	if taxaAtual <= 0 {
+		tique.Stop()
+	}
+	tiqueDeAjuste := time.NewTicker(100 * time.Millisecond)
+	defer tiqueDeAjuste.Stop()
 
-	sem := make(chan struct{}, cfg.GrauDeConcorrencia)
 	grupo := &sync.WaitGroup{}
 
-	rotuloCons := cfg.NivelDeConsistenciaTexto
-	inicio := time.Now()
-
 	// Contadores locais de erro para log periódico
 	var cntTimeout, cntUnavailable, cntOverloaded, cntOther atomic.Int64
 
 	// Log periódico de progresso (ops/s e erros)
-	var tiqueProgresso *time.Ticker
+	var canalProgresso <-chan time.Time
 	if cfg.IntervaloDeLogDeProgresso > 0 {
-		tiqueProgresso = time.NewTicker(cfg.IntervaloDeLogDeProgresso)
+		tiqueProgresso := time.NewTicker(cfg.IntervaloDeLogDeProgresso)
 		defer tiqueProgresso.Stop()
+		canalProgresso = tiqueProgresso.C
 	}
 
 	var ultimoTotal int64
@@ -63,39 +164,62 @@ func (s *ServicoDeStress) Executar(ctx context.Context, cfg ConfiguracaoDoTesteD
 		select {
 		case <-prazo.Done():
 			goto FIM
-		case <-tiqueProgresso.C:
+		case <-tiqueDeAjuste.C:
+			novaTaxa, terminou := taxaEm(time.Since(inicio))
+			if terminou {
+				goto FIM
+			}
+			if novaTaxa != taxaAtual {
+				taxaAtual = novaTaxa
+				if taxaAtual <= 0 {
+					tique.Stop()
+				} else {
+					tique.Reset(intervaloParaTaxa(taxaAtual))
+				}
+			}
+		case <-canalProgresso:
 			atual := totalContador.Load()
 			delta := atual - ultimoTotal
 			ultimoTotal = atual
-			janela := cfg.IntervaloDeLogDeProgresso
-			if janela <= 0 {
-				janela = 1 * time.Second
-			}
-			opss := float64(delta) / janela.Seconds()
-			fmt.Printf("[stress] progresso: total=%d ok=%d ops/s=%.0f erros{timeout=%d unavailable=%d overloaded=%d other=%d}\n",
-				atual, okContador.Load(), opss, cntTimeout.Load(), cntUnavailable.Load(), cntOverloaded.Load(), cntOther.Load())
+			opss := float64(delta) / cfg.IntervaloDeLogDeProgresso.Seconds()
+			fmt.Printf("[stress] progresso: total=%d ok=%d ops/s=%.0f alvo=%.0f %s erros{timeout=%d unavailable=%d overloaded=%d other=%d}\n",
+				atual, okContador.Load(), opss, taxaAtual, resumoPorOperacao(mistura, contadores), cntTimeout.Load(), cntUnavailable.Load(), cntOverloaded.Load(), cntOther.Load())
 		case <-tique.C:
-			sem <- struct{}{}
+			sem.Adquirir()
 			grupo.Add(1)
+			operacao := sortearOperacao(mistura, fonte.Intn)
+			id := fmt.Sprintf("stress-%d", sensores.ProximoSensor())
+			valor := fonte.Float64()*100 + 1
+			// Sorteados aqui (e não no worker) para que a mesma semente reproduza a sequência
+			var escritas []portas.LeituraDeSensor
+			var consulta consultaSorteada
+			if operacao == portas.OperacaoEscrita {
+				escritas = make([]portas.LeituraDeSensor, max(cfg.TamanhoDoLote, 1))
+				for i := range escritas {
+					if i > 0 { // cada leitura do lote tem o próprio sensor
+						id = fmt.Sprintf("stress-%d", sensores.ProximoSensor())
+						valor = fonte.Float64()*100 + 1
+					}
+					escritas[i] = *montarEscrita(id, valor, instantes.ProximoInstante(time.Now().UTC()).UTC(), cfg)
+				}
+			} else {
+				consulta = montarConsulta(operacao, id, time.Now().UTC(), cfg, fonte)
+			}
 			go func() {
 				defer grupo.Done()
-				defer func() { <-sem }()
-				id := fmt.Sprintf("stress-%d", rand.Intn(cfg.QuantidadeDeSensoresDistintos))
-				agora := time.Now().UTC()
-				dia := time.Date(agora.Year(), agora.Month(), agora.Day(), 0, 0, 0, 0, time.UTC)
-				leitura := portas.LeituraDeSensor{
-					IdentificadorDoSensor: id,
-					DiaDeAgrupamento:      dia,
-					InstanteDoEvento:      agora,
-					ValorMedido:           rand.Float64()*100 + 1,
-					UnidadeDeMedida:       "C",
-					EstadoDaLeitura:       0,
-					AtributosAdicionais:   map[string]string{"src": "go-stress", "site": "LAB", "tipo": "temperatura"},
-				}
+				defer sem.Liberar()
+				ctx, resposta := portas.ContextoComResposta(context.Background())
 				t0 := time.Now()
-				if err := s.Persistencia.GravarLeitura(context.Background(), leitura); err != nil {
+				err := s.executarOperacao(ctx, operacao, escritas, consulta, cfg)
+				latencia := time.Since(t0)
+				contador := contadores[operacao]
+				if err != nil {
 					motivo := classificarErro(err)
-					s.Metricas.RegistrarErro(motivo)
+					if comResposta != nil {
+						comResposta.RegistrarOperacao(operacao, latencia, motivo, *resposta)
+					} else {
+						s.Metricas.RegistrarErro(operacao, motivo)
+					}
 					switch motivo {
 					case "timeout":
 						cntTimeout.Add(1)
@@ -108,18 +232,138 @@ func (s *ServicoDeStress) Executar(ctx context.Context, cfg ConfiguracaoDoTesteD
 					}
 				} else {
 					okContador.Add(1)
-					s.Metricas.RegistrarLatenciaEmMs(rotuloCons, time.Since(t0))
+					contador.ok.Add(1)
+					if comResposta != nil {
+						comResposta.RegistrarOperacao(operacao, latencia, "", *resposta)
+					} else {
+						s.Metricas.RegistrarLatenciaEmMs(operacao, rotuloDeConsistencia(operacao), latencia)
+					}
+				}
+				if observador != nil {
+					observador(operacao, latencia, err)
 				}
 				totalContador.Add(1)
+				contador.total.Add(1)
 			}()
 		}
 	}
 FIM:
 	grupo.Wait()
-	return totalContador.Load(), okContador.Load(), time.Since(inicio)
+	resultado := ResultadoDoTesteDeStress{
+		Total:       totalContador.Load(),
+		Ok:          okContador.Load(),
+		Duracao:     time.Since(inicio),
+		Semente:     semente,
+		PorOperacao: map[portas.TipoDeOperacao]ResultadoPorOperacao{},
+	}
+	for op, c := range contadores {
+		resultado.PorOperacao[op] = ResultadoPorOperacao{Total: c.total.Load(), Ok: c.ok.Load()}
+	}
+	return resultado
+}
+
+func (s *ServicoDeStress) consistenciasAjustaveis() []portas.PortaDeConsistenciaAjustavel {
+	var ajustaveis []portas.PortaDeConsistenciaAjustavel
+	if a, ok := s.Persistencia.(portas.PortaDeConsistenciaAjustavel); ok {
+		ajustaveis = append(ajustaveis, a)
+	}
+	if a, ok := s.Consultas.(portas.PortaDeConsistenciaAjustavel); ok {
+		ajustaveis = append(ajustaveis, a)
+	}
+	return ajustaveis
+}
+
+func intervaloParaTaxa(taxa float64) time.Duration {
+	if taxa <= 0 {
+		return time.Second
+	}
+	intervalo := time.Duration(float64(time.Second) / taxa)
+	if intervalo <= 0 {
+		intervalo = 1
+	}
+	return intervalo
+}
+
+// Executa uma única operação da mistura; a quantidade de leituras gravadas ou devolvidas é anotada no contexto.
+func (s *ServicoDeStress) executarOperacao(ctx context.Context, operacao portas.TipoDeOperacao, escritas []portas.LeituraDeSensor, consulta consultaSorteada, cfg ConfiguracaoDoTesteDeStress) error {
+	var (
+		leituras []portas.LeituraDeSensor
+		err      error
+	)
+	switch operacao {
+	case portas.OperacaoLeituraUltimas:
+		limite := cfg.LimiteDeLeiturasUltimas
+		if limite <= 0 {
+			limite = 10
+		}
+		leituras, err = s.Consultas.ConsultarUltimasLeituras(ctx, consulta.sensor, consulta.dia, limite)
+	case portas.OperacaoLeituraIntervalo:
+		limite := cfg.LimiteDeLeiturasPorIntervalo
+		if limite <= 0 {
+			limite = 1000
+		}
+		leituras, err = s.Consultas.ConsultarLeiturasPorIntervalo(ctx, consulta.sensor, consulta.dia, consulta.inicio, consulta.fim, limite)
+	default:
+		if cfg.TamanhoDoLote <= 0 {
+			err = s.Persistencia.GravarLeitura(ctx, escritas[0])
+		} else if emLote, ok := s.Persistencia.(portas.PortaDeEscritaEmLote); ok {
+			err = emLote.GravarLote(ctx, escritas) // com falha parcial, a porta anota as quantidades
+		} else {
+			err = fmt.Errorf("porta de escrita sem suporte a lote")
+		}
+		leituras = escritas
+	}
+	if err == nil {
+		portas.AnotarItens(ctx, int64(len(leituras)), 0)
+	}
+	return err
+}
+
+// Leitura escrita pela operação de escrita, com instante e conteúdo já sorteados.
+func montarEscrita(id string, valor float64, instante time.Time, cfg ConfiguracaoDoTesteDeStress) *portas.LeituraDeSensor {
+	leitura := portas.LeituraDeSensor{
+		IdentificadorDoSensor: id,
+		DiaDeAgrupamento:      time.Date(instante.Year(), instante.Month(), instante.Day(), 0, 0, 0, 0, time.UTC),
+		InstanteDoEvento:      instante,
+		ValorMedido:           valor,
+		UnidadeDeMedida:       "C",
+		EstadoDaLeitura:       0,
+		AtributosAdicionais:   map[string]string{"src": "go-stress", "site": "LAB", "tipo": "temperatura"},
+	}
+	if cfg.GeradorDeConteudo != nil {
+		c := cfg.GeradorDeConteudo.Gerar(id)
+		leitura.UnidadeDeMedida, leitura.EstadoDaLeitura, leitura.AtributosAdicionais = c.UnidadeDeMedida, c.EstadoDaLeitura, c.AtributosAdicionais
+	}
+	return &leitura
 }
 
+// Nível de consistência (texto) configurado para o tipo de operação.
+func (cfg ConfiguracaoDoTesteDeStress) ConsistenciaDaOperacao(operacao portas.TipoDeOperacao) string {
+	switch operacao {
+	case portas.OperacaoLeituraUltimas:
+		return cfg.NivelDeConsistenciaUltimasTexto
+	case portas.OperacaoLeituraIntervalo:
+		return cfg.NivelDeConsistenciaIntervaloTexto
+	default:
+		return cfg.NivelDeConsistenciaTexto
+	}
+}
+
+func resumoPorOperacao(mistura []PesoDeOperacao, contadores map[portas.TipoDeOperacao]*contadoresDeOperacao) string {
+	partes := make([]string, 0, len(mistura))
+	for _, m := range mistura {
+		c := contadores[m.Operacao]
+		partes = append(partes, fmt.Sprintf("%s=%d/%d", m.Operacao, c.ok.Load(), c.total.Load()))
+	}
+	return "ops{" + strings.Join(partes, " ") + "}"
+}
+
+// Alvos HTTP (erro com StatusHTTP) são classificados pelo status da resposta.
 func classificarErro(err error) string {
+	var comStatus interface{ StatusHTTP() int }
+	if errors.As(err, &comStatus) {
+		return fmt.Sprintf("http_%d", comStatus.StatusHTTP())
+	}
 	msg := err.Error()
 	switch {
 	case containsFold(msg, "timeout"):
@@ -16,13 +16,79 @@ type LeituraDeSensor struct {
 	AtributosAdicionais   map[string]string
 }
 
+// Tipos de operação suportados pela mistura de carga do teste de stress.
+type TipoDeOperacao string
+
+const (
+	OperacaoEscrita          TipoDeOperacao = "escrita"
+	OperacaoLeituraUltimas   TipoDeOperacao = "ultimas"
+	OperacaoLeituraIntervalo TipoDeOperacao = "intervalo"
+)
+
 // Porta (interface) para persistencia de leituras (adaptador de banco implementa).
 type PortaDeEscrita interface {
 	GravarLeitura(ctx context.Context, leitura LeituraDeSensor) error
 }
 
-// Porta (interface) para registro de metricas.
+// Opcional: portas que gravam várias leituras em uma requisição (ex.: POST /ingest/lote).
+type PortaDeEscritaEmLote interface {
+	GravarLote(ctx context.Context, leituras []LeituraDeSensor) error
+}
+
+// Porta (interface) para consultas de leituras (ultimas N e intervalo de tempo).
+type PortaDeLeitura interface {
+	ConsultarUltimasLeituras(ctx context.Context, identificadorDoSensor string, dia time.Time, quantidade int) ([]LeituraDeSensor, error)
+	ConsultarLeiturasPorIntervalo(ctx context.Context, identificadorDoSensor string, dia, inicio, fim time.Time, quantidade int) ([]LeituraDeSensor, error)
+}
+
+// Porta (interface) para registro de metricas, separadas por tipo de operação.
 type PortaDeMetricas interface {
-	RegistrarLatenciaEmMs(rotuloConsistencia string, duracao time.Duration)
-	RegistrarErro(motivo string)
+	RegistrarLatenciaEmMs(operacao TipoDeOperacao, rotuloConsistencia string, duracao time.Duration)
+	RegistrarErro(operacao TipoDeOperacao, motivo string)
+}
+
+// Resposta do servidor a uma operação. Status: -1 = porta não HTTP (CQL), 0 = sem resposta (rede, timeout).
+type RespostaDoServidor struct {
+	Status            int
+	DuracaoNoServidor time.Duration // duracao_ms devolvido pelo servidor; < 0 = ausente
+	Itens             int64         // leituras gravadas (escrita) ou devolvidas (consulta)
+	ItensComFalha     int64         // lote: leituras recusadas pelo servidor
+}
+
+// Opcional: métricas que registram cada operação com a resposta do servidor, no lugar de
+// RegistrarLatenciaEmMs/RegistrarErro. Motivo vazio = sucesso.
+type PortaDeMetricasComResposta interface {
+	RegistrarOperacao(operacao TipoDeOperacao, latencia time.Duration, motivo string, resposta RespostaDoServidor)
+}
+
+type chaveDaResposta struct{}
+
+// Contexto da operação em que as portas remotas anotam a resposta do servidor.
+func ContextoComResposta(ctx context.Context) (context.Context, *RespostaDoServidor) {
+	resposta := &RespostaDoServidor{Status: -1, DuracaoNoServidor: -1}
+	return context.WithValue(ctx, chaveDaResposta{}, resposta), resposta
+}
+
+// Chamada pelas portas HTTP; sem ContextoComResposta não faz nada.
+func AnotarResposta(ctx context.Context, status int, duracaoNoServidor time.Duration) {
+	if resposta, ok := ctx.Value(chaveDaResposta{}).(*RespostaDoServidor); ok {
+		resposta.Status, resposta.DuracaoNoServidor = status, duracaoNoServidor
+	}
+}
+
+// Quantidade de leituras gravadas ou devolvidas pela operação; sem ContextoComResposta não faz nada.
+func AnotarItens(ctx context.Context, itens, itensComFalha int64) {
+	if resposta, ok := ctx.Value(chaveDaResposta{}).(*RespostaDoServidor); ok {
+		resposta.Itens, resposta.ItensComFalha = itens, itensComFalha
+	}
+}
+
+// Implementada por adaptadores que permitem trocar o nível de consistência durante a execução.
+type PortaDeConsistenciaAjustavel interface {
+	DefinirConsistencia(operacao TipoDeOperacao, nivel string) error
+}
+
+// Implementada por adaptadores de métricas que rotulam as séries com o nome da execução.
+type PortaDeRotuloDeExecucao interface {
+	DefinirExecucao(nome string)
 }